@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/audio"
+	"github.com/shellus/voiceWin/internal/capture"
+)
+
+const devicesUsage = `用法:
+  voiceWin devices list
+  voiceWin devices test [-device 名称] [-source mic|loopback|mix] [-loopback-device 名称] [-duration 5s]
+
+list 列出采集设备和可以采集系统声音的设备。
+
+test 录制一段音频（不发送给识别服务），报告峰值、RMS、削波比例、直流偏移，
+并检查麦克风是否被静音、音量是否过低。`
+
+// runDevices 执行 devices 子命令：列出采集设备、测试麦克风信号
+func runDevices(args []string) {
+	if len(args) == 0 {
+		fmt.Println(devicesUsage)
+		os.Exit(2)
+	}
+	sub, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("devices "+sub, flag.ExitOnError)
+	device := fs.String("device", "", "采集设备名称，为空时使用系统默认设备")
+	source := fs.String("source", capture.SourceMic, "采集来源：mic、loopback 或 mix")
+	loopback := fs.String("loopback-device", "", "采集系统声音的设备，为空时使用默认输出设备")
+	duration := fs.Duration("duration", 5*time.Second, "录制时长")
+	silence := fs.Float64("silence-threshold", audio.QuietPeak, "静音门限（dBFS），峰值低于该值时提示音量过低")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), devicesUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ac := capture.NewAudioCapture()
+	if ac == nil {
+		log.Fatalf("初始化音频设备失败")
+	}
+	defer ac.Close()
+
+	switch sub {
+	case "list":
+		devices, err := ac.ListDevices()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		printDevices(devices)
+		// 系统声音设备只是附带信息，不支持的平台不报错
+		if loopbacks, err := ac.ListLoopbackDevices(); err == nil && len(loopbacks) > 0 {
+			fmt.Println("\n系统声音（-source loopback 或 mix，-loopback-device）:")
+			printDevices(loopbacks)
+		}
+	case "test":
+		if err := ac.SetDevice(*device); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := ac.SetSource(*source, *loopback); err != nil {
+			log.Fatalf("%v", err)
+		}
+		meter := audio.DefaultMeterConfig()
+		meter.SilenceThreshold = *silence
+		ac.SetMeter(meter)
+		// 取走采集的数据，不发送给识别服务
+		ac.OnAudioData = func() error {
+			ac.GetPCMData()
+			return nil
+		}
+		fmt.Printf("正在录制 %s，请正常说话...\n", *duration)
+		if err := ac.Start(); err != nil {
+			log.Fatalf("%v", err)
+		}
+		time.Sleep(*duration)
+		if err := ac.Stop(); err != nil {
+			log.Fatalf("%v", err)
+		}
+		printSignal(ac.DeviceName(), ac.Signal(), ac.Problems())
+	default:
+		fmt.Println(devicesUsage)
+		os.Exit(2)
+	}
+}
+
+// printDevices 显示设备列表，默认设备前标记 *
+func printDevices(devices []capture.Device) {
+	for _, d := range devices {
+		mark := " "
+		if d.Default {
+			mark = "*"
+		}
+		fmt.Printf("%s %s\n", mark, d.Name)
+	}
+}
+
+// printSignal 显示信号统计和诊断结果
+func printSignal(device string, s audio.Stats, problems []audio.Problem) {
+	fmt.Printf("设备:     %s\n", device)
+	fmt.Printf("时长:     %.1f 秒\n", s.Seconds)
+	fmt.Printf("峰值:     %s\n", dbfs(s.Peak))
+	fmt.Printf("RMS:      %s\n", dbfs(s.RMS))
+	fmt.Printf("削波:     %.2f%%\n", s.Clipping*100)
+	fmt.Printf("直流偏移: %.2f%%\n", s.DCOffset*100)
+	if len(problems) == 0 {
+		fmt.Println("结论:     正常")
+		return
+	}
+	fmt.Println("结论:")
+	for _, p := range problems {
+		fmt.Printf("  - %s\n", p.Message)
+	}
+}
+
+func dbfs(v float64) string {
+	if math.IsInf(v, -1) {
+		return "-∞ dBFS"
+	}
+	return fmt.Sprintf("%.1f dBFS", v)
+}
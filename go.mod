@@ -1,23 +1,24 @@
 module github.com/shellus/voiceWin
 
-go 1.23.4
+go 1.24.0
 
 require (
 	github.com/aliyun/alibabacloud-nls-go-sdk v1.1.1
 	github.com/gen2brain/malgo v0.11.23
+	github.com/gorilla/websocket v1.4.2
+	github.com/hajimehoshi/go-mp3 v0.3.4
 	github.com/joho/godotenv v1.5.1
+	github.com/pion/opus v0.0.0-20260504155822-67f6be33ea99
 	github.com/smallnest/ringbuffer v0.0.0-20241129171057-356c688ba81d
 )
 
 require (
 	github.com/aliyun/alibaba-cloud-sdk-go v1.63.98 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/opentracing/opentracing-go v1.2.1-0.20220228012449-10b1cf09e00b // indirect
 	github.com/satori/go.uuid v1.2.0 // indirect
-	github.com/stretchr/testify v1.9.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 )
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/shellus/voiceWin/internal/history"
+)
+
+const historyUsage = `用法:
+  voiceWin history list   [-from 日期] [-to 日期] [-limit N]
+  voiceWin history search [-from 日期] [-to 日期] [-limit N] 关键词...
+  voiceWin history export [-from 日期] [-to 日期] [-format jsonl|json|csv|txt] [-o 文件] [关键词...]
+
+日期格式：2006-01-02、2006-01-02 15:04 或 RFC3339，-to 只写日期时包含当天`
+
+// runHistory 执行 history 子命令
+func runHistory(args []string) {
+	if len(args) == 0 {
+		fmt.Println(historyUsage)
+		os.Exit(2)
+	}
+	sub, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("history "+sub, flag.ExitOnError)
+	file := fs.String("file", history.DefaultPath(), "识别历史文件")
+	from := fs.String("from", "", "起始日期（包含）")
+	to := fs.String("to", "", "结束日期（包含）")
+	limit := fs.Int("limit", 0, "最多显示最近的多少条，0表示不限制")
+	format := fs.String("format", "jsonl", "导出格式: "+strings.Join(history.ExportFormats, "|"))
+	output := fs.String("o", "", "导出到文件，默认输出到标准输出")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), historyUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	filter := history.Filter{Limit: *limit}
+	var err error
+	if filter.From, err = history.ParseDate(*from, false); err != nil {
+		log.Fatalf("无效的 -from: %v", err)
+	}
+	if filter.To, err = history.ParseDate(*to, true); err != nil {
+		log.Fatalf("无效的 -to: %v", err)
+	}
+
+	switch sub {
+	case "list":
+	case "search":
+		if fs.NArg() == 0 {
+			log.Fatalf("search 需要关键词")
+		}
+		filter.Query = strings.Join(fs.Args(), " ")
+	case "export":
+		filter.Query = strings.Join(fs.Args(), " ")
+	default:
+		fmt.Println(historyUsage)
+		os.Exit(2)
+	}
+
+	utterances, err := history.Open(*file).Query(filter)
+	if err != nil {
+		log.Fatalf("查询识别历史失败: %v", err)
+	}
+
+	if sub != "export" {
+		for _, u := range utterances {
+			fmt.Println(history.FormatLine(u))
+		}
+		fmt.Printf("共 %d 条记录\n", len(utterances))
+		return
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("创建导出文件失败: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := history.Export(w, utterances, *format); err != nil {
+		log.Fatalf("导出识别历史失败: %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/audio"
+	"github.com/shellus/voiceWin/internal/logging"
+)
+
+var logger = logging.Component(logging.Archive)
+
+// Recorder 把发送给识别服务的 PCM 数据另存为每句话一个 WAV 文件，文件名为 task_id。
+// 所有文件操作都在后台 goroutine 中完成，Write 永远不会阻塞采集回调，队列满时丢弃数据并计数，
+// 保存的文件缺少了数据时记录警告日志。
+//
+// 生命周期：Begin -> Write... -> Finish(taskID) 或 Discard，Close 之后所有调用都直接返回
+
+// Config 录音归档配置
+type Config struct {
+	Dir        string        // 归档目录
+	SampleRate int           // 采样率
+	Channels   int           // 通道数
+	MaxCount   int           // 最多保留的文件数，0表示不限制
+	MaxBytes   int64         // 最多占用的字节数，0表示不限制
+	MaxAge     time.Duration // 最长保留时间，0表示不限制
+	QueueSize  int           // 写入队列长度
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig(dir string) Config {
+	return Config{
+		Dir:        dir,
+		SampleRate: 16000,
+		Channels:   1,
+		MaxCount:   500,
+		MaxBytes:   500 * 1024 * 1024,
+		MaxAge:     30 * 24 * time.Hour,
+		QueueSize:  256,
+	}
+}
+
+type opKind int
+
+const (
+	opBegin opKind = iota
+	opData
+	opFinish
+	opDiscard
+)
+
+type op struct {
+	kind opKind
+	data []byte
+	name string
+	done chan error
+}
+
+// Recorder 录音归档器
+type Recorder struct {
+	cfg     Config
+	queue   chan op
+	closed  chan struct{}
+	dropped atomic.Int64
+
+	// mutex 保护 stopped，发送到 queue 时持有读锁，Close 持有写锁关闭 queue，
+	// 所以 Close 之后的调用不会向已关闭的 queue 发送
+	mutex   sync.RWMutex
+	stopped bool
+
+	// 以下字段只在后台 goroutine 中访问
+	file      *os.File
+	size      uint32
+	droppedAt int64 // 开始录制这句话时的 dropped，保存时用于判断是否丢过数据
+}
+
+// errClosed 归档器已关闭
+var errClosed = errors.New("录音归档已关闭")
+
+// tempPrefix 正在录制的临时文件前缀，清理时会跳过
+const tempPrefix = ".recording-"
+
+// NewRecorder 创建录音归档器并启动后台写入
+func NewRecorder(cfg Config) (*Recorder, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 256
+	}
+	r := &Recorder{
+		cfg:    cfg,
+		queue:  make(chan op, cfg.QueueSize),
+		closed: make(chan struct{}),
+	}
+	go r.loop()
+	return r, nil
+}
+
+// Begin 开始录制新的一句话，未结束的上一句会被丢弃
+func (r *Recorder) Begin() {
+	r.send(op{kind: opBegin}, true)
+}
+
+// Write 追加 PCM 数据，不阻塞，队列满时丢弃
+func (r *Recorder) Write(pcm []byte) {
+	data := make([]byte, len(pcm))
+	copy(data, pcm)
+	if !r.send(op{kind: opData, data: data}, false) {
+		r.dropped.Add(int64(len(pcm)))
+	}
+}
+
+// send 把操作放入队列，block 为 false 时队列满直接返回 false，已关闭时返回 false
+func (r *Recorder) send(o op, block bool) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.stopped {
+		return false
+	}
+	if block {
+		r.queue <- o
+		return true
+	}
+	select {
+	case r.queue <- o:
+		return true
+	default:
+		return false
+	}
+}
+
+// Finish 结束当前这句话，保存为 taskID.wav 并返回文件路径
+func (r *Recorder) Finish(taskID string) (string, error) {
+	if taskID == "" {
+		taskID = time.Now().Format("20060102-150405.000")
+	}
+	name := filepath.Join(r.cfg.Dir, sanitize(taskID)+".wav")
+	done := make(chan error, 1)
+	if !r.send(op{kind: opFinish, name: name, done: done}, true) {
+		return "", errClosed
+	}
+	if err := <-done; err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Discard 丢弃当前这句话
+func (r *Recorder) Discard() {
+	r.send(op{kind: opDiscard}, true)
+}
+
+// Dropped 返回因队列满而丢弃的字节数
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close 丢弃未完成的录音并停止后台写入，可以重复调用
+func (r *Recorder) Close() {
+	r.mutex.Lock()
+	if !r.stopped {
+		r.stopped = true
+		r.queue <- op{kind: opDiscard}
+		close(r.queue)
+	}
+	r.mutex.Unlock()
+	<-r.closed
+}
+
+func (r *Recorder) loop() {
+	defer close(r.closed)
+	for o := range r.queue {
+		switch o.kind {
+		case opBegin:
+			r.discard()
+			if err := r.begin(); err != nil {
+				logger.Warn("开始录音归档失败", "error", err)
+			}
+		case opData:
+			if r.file == nil {
+				continue
+			}
+			if _, err := r.file.Write(o.data); err != nil {
+				logger.Warn("写入录音归档失败", "error", err)
+				r.discard()
+				continue
+			}
+			r.size += uint32(len(o.data))
+		case opFinish:
+			err := r.finish(o.name)
+			if err == nil {
+				if lost := r.dropped.Load() - r.droppedAt; lost > 0 {
+					logger.Warn("写入队列已满，录音归档缺少部分音频", "file", o.name, "dropped_bytes", lost)
+				}
+				r.cleanup()
+			}
+			o.done <- err
+		case opDiscard:
+			r.discard()
+		}
+	}
+}
+
+func (r *Recorder) begin() error {
+	f, err := os.CreateTemp(r.cfg.Dir, tempPrefix+"*.wav")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(audio.WAVHeader(0, r.cfg.SampleRate, r.cfg.Channels)); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	r.file = f
+	r.size = 0
+	r.droppedAt = r.dropped.Load()
+	return nil
+}
+
+func (r *Recorder) finish(name string) error {
+	if r.file == nil {
+		return fmt.Errorf("没有正在录制的音频")
+	}
+	f := r.file
+	r.file = nil
+
+	// 回填 WAV 头中的数据长度
+	_, err := f.WriteAt(audio.WAVHeader(r.size, r.cfg.SampleRate, r.cfg.Channels), 0)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("保存录音归档失败: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) discard() {
+	if r.file == nil {
+		return
+	}
+	r.file.Close()
+	os.Remove(r.file.Name())
+	r.file = nil
+}
+
+// cleanup 按数量、大小和时间限制清理旧的归档文件
+func (r *Recorder) cleanup() {
+	entries, err := os.ReadDir(r.cfg.Dir)
+	if err != nil {
+		return
+	}
+	type archived struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []archived
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), tempPrefix) || filepath.Ext(e.Name()) != ".wav" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, archived{filepath.Join(r.cfg.Dir, e.Name()), info.Size(), info.ModTime()})
+	}
+	// 新的在前
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	var total int64
+	for i, f := range files {
+		total += f.size
+		expired := r.cfg.MaxAge > 0 && time.Since(f.modTime) > r.cfg.MaxAge
+		overCount := r.cfg.MaxCount > 0 && i >= r.cfg.MaxCount
+		overSize := r.cfg.MaxBytes > 0 && total > r.cfg.MaxBytes
+		if expired || overCount || overSize {
+			os.Remove(f.path)
+		}
+	}
+}
+
+// sanitize 去掉文件名中不安全的字符
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorder_Finish(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(DefaultConfig(dir))
+	if err != nil {
+		t.Fatalf("创建归档器失败: %v", err)
+	}
+	defer r.Close()
+
+	r.Begin()
+	r.Write([]byte{1, 2, 3, 4})
+	r.Write([]byte{5, 6})
+	path, err := r.Finish("task/1")
+	if err != nil {
+		t.Fatalf("保存归档失败: %v", err)
+	}
+	if path != filepath.Join(dir, "task_1.wav") {
+		t.Errorf("归档文件名不符合预期: %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取归档文件失败: %v", err)
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		t.Errorf("WAV 文件头错误: %v", data[:12])
+	}
+	if size := binary.LittleEndian.Uint32(data[40:44]); size != 6 {
+		t.Errorf("期望数据长度为6，实际为%d", size)
+	}
+	if !bytes.Equal(data[44:], []byte{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("PCM 数据不匹配: %v", data[44:])
+	}
+
+	// 没有 Begin 时 Finish 应返回错误
+	if _, err := r.Finish("task2"); err == nil {
+		t.Error("没有正在录制的音频时应返回错误")
+	}
+}
+
+func TestRecorder_Discard(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := NewRecorder(DefaultConfig(dir))
+
+	r.Begin()
+	r.Write([]byte{1, 2})
+	r.Discard()
+	r.Close()
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("丢弃后目录应为空，实际有%d个文件", len(entries))
+	}
+}
+
+func TestRecorder_Closed(t *testing.T) {
+	r, _ := NewRecorder(DefaultConfig(t.TempDir()))
+	r.Close()
+
+	// 关闭后的调用直接返回，不应向已关闭的队列发送
+	r.Begin()
+	r.Write([]byte{1, 2})
+	r.Discard()
+	if _, err := r.Finish("task"); err == nil {
+		t.Error("关闭后 Finish 应返回错误")
+	}
+	if r.Dropped() != 2 {
+		t.Errorf("关闭后写入的数据应计为丢弃，实际 %d", r.Dropped())
+	}
+	r.Close()
+}
+
+func TestRecorder_Retention(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig(dir)
+	cfg.MaxCount = 2
+	r, _ := NewRecorder(cfg)
+	defer r.Close()
+
+	// 一个更早的旧文件
+	old := filepath.Join(dir, "old.wav")
+	os.WriteFile(old, []byte("x"), 0644)
+	os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+
+	for i, id := range []string{"a", "b", "c"} {
+		r.Begin()
+		r.Write([]byte{1, 2})
+		path, err := r.Finish(id)
+		if err != nil {
+			t.Fatalf("保存归档失败: %v", err)
+		}
+		// 保证修改时间按保存顺序递增
+		ts := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(path, ts, ts)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 {
+		t.Fatalf("期望保留2个文件，实际为%v", names)
+	}
+	for _, gone := range []string{"old.wav", "a.wav"} {
+		if _, err := os.Stat(filepath.Join(dir, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s 应被清理", gone)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package audio
+
+import "errors"
+
+// Format 音频文件格式
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatWAV     Format = "wav"
+	FormatOgg     Format = "ogg"
+	FormatMP3     Format = "mp3"
+)
+
+// ErrNoDecoder 文件格式正确，但没有可用的解码器，如超过两个声道的 Opus
+var ErrNoDecoder = errors.New("没有可用的解码器")
+
+// DetectFormat 按文件头判断格式
+func DetectFormat(data []byte) Format {
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return FormatWAV
+	case len(data) >= 4 && string(data[0:4]) == "OggS":
+		return FormatOgg
+	case id3Size(data) > 0:
+		return FormatMP3
+	}
+	if _, err := parseMP3Header(data); err == nil {
+		return FormatMP3
+	}
+	return FormatUnknown
+}
+
+// Decode 把音频文件内容解码为 sampleRate 采样率的 16位单声道 PCM
+// 支持各种位深的 WAV、MP3 和 Ogg Opus
+func Decode(data []byte, sampleRate int) ([]byte, error) {
+	switch DetectFormat(data) {
+	case FormatWAV:
+		pcm, rate, channels, err := DecodeWAV(data)
+		if err != nil {
+			return nil, err
+		}
+		return Convert(pcm, rate, channels, sampleRate), nil
+	case FormatOgg:
+		o, err := ParseOggOpus(data)
+		if err != nil {
+			return nil, err
+		}
+		pcm, err := o.Decode()
+		if err != nil {
+			return nil, err
+		}
+		return Convert(pcm, opusRate, o.Channels, sampleRate), nil
+	case FormatMP3:
+		m, err := ParseMP3(data)
+		if err != nil {
+			return nil, err
+		}
+		pcm, err := m.Decode()
+		if err != nil {
+			return nil, err
+		}
+		return Convert(pcm, m.SampleRate, 2, sampleRate), nil
+	}
+	return nil, errors.New("无法识别的音频格式，支持 WAV、Ogg Opus、MP3")
+}
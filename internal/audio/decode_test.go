@@ -0,0 +1,262 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+// sineAtRate 生成 seconds 秒采样率为 rate、频率为 freq 的正弦波
+func sineAtRate(rate int, freq, amp, seconds float64) []float64 {
+	x := make([]float64, int(seconds*float64(rate)))
+	for i := range x {
+		x[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+	}
+	return x
+}
+
+func rmsOf(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
+
+func TestResample(t *testing.T) {
+	// 通带内的信号幅度不变，并且与直接按目标采样率生成的信号一致
+	for _, rates := range [][2]int{{48000, 16000}, {44100, 16000}, {8000, 16000}} {
+		out := Resample(sineAtRate(rates[0], 1000, 10000, 1), rates[0], rates[1])
+		if len(out) != rates[1] {
+			t.Fatalf("%v: 输出长度 %d", rates, len(out))
+		}
+		want := sineAtRate(rates[1], 1000, 10000, 1)
+		// 两端的插值核不完整，只比较中间部分
+		var maxErr float64
+		for i := rates[1] / 10; i < len(out)-rates[1]/10; i++ {
+			maxErr = math.Max(maxErr, math.Abs(out[i]-want[i]))
+		}
+		if maxErr > 100 {
+			t.Errorf("%v: 最大误差 %.1f", rates, maxErr)
+		}
+	}
+
+	// 降采样时高于目标奈奎斯特频率的信号被滤除，不会混叠到低频
+	alias := Resample(sineAtRate(48000, 12000, 10000, 1), 48000, 16000)
+	if g := DBFS(rmsOf(alias[1600:14400])) - DBFS(rmsOf(sineAtRate(16000, 1000, 10000, 1))); g > -40 {
+		t.Errorf("12kHz 应衰减超过 40dB，实际 %.1fdB", g)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	// 左右声道反相，混为单声道后为 0
+	pcm := make([]byte, 8)
+	binary.LittleEndian.PutUint16(pcm[0:], uint16(1000))
+	binary.LittleEndian.PutUint16(pcm[2:], uint16(0xFFFF-999)) // -1000
+	binary.LittleEndian.PutUint16(pcm[4:], uint16(300))
+	binary.LittleEndian.PutUint16(pcm[6:], uint16(100))
+	out := Convert(pcm, 16000, 2, 16000)
+	if len(out) != 4 || int16(binary.LittleEndian.Uint16(out[0:])) != 0 || int16(binary.LittleEndian.Uint16(out[2:])) != 200 {
+		t.Errorf("混音结果错误: %v", out)
+	}
+	if mono := []byte{1, 2}; &Convert(mono, 16000, 1, 16000)[0] != &mono[0] {
+		t.Error("格式相同时应直接返回")
+	}
+}
+
+// oggPage 构造一个 Ogg 页，packets 中的每个包都在本页结束
+func oggPage(flags byte, granule int64, seq uint32, packets ...[]byte) []byte {
+	var segments, body []byte
+	for _, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			segments = append(segments, 255)
+		}
+		segments = append(segments, byte(n))
+		body = append(body, p...)
+	}
+	page := make([]byte, 27, 27+len(segments)+len(body))
+	copy(page, "OggS")
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], 1234)
+	binary.LittleEndian.PutUint32(page[18:], seq)
+	page[26] = byte(len(segments))
+	page = append(append(page, segments...), body...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+	return page
+}
+
+// oggOpusFile 构造 2 秒单声道的 Ogg Opus 文件，音频包为 20ms 的空包
+func oggOpusFile() []byte {
+	head := []byte("OpusHead\x01\x01\x38\x01\x80\x3e\x00\x00\x00\x00\x00")
+	data := oggPage(oggFirst, 0, 0, head)
+	data = append(data, oggPage(0, 0, 1, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))...)
+	for i := 0; i < 2; i++ {
+		packets := make([][]byte, 50)
+		for j := range packets {
+			packets[j] = []byte{0xF8} // 20ms 的 CELT 帧
+		}
+		// 第一个包跨越 255 字节
+		if i == 0 {
+			packets[0] = make([]byte, 300)
+		}
+		data = append(data, oggPage(0, int64(i+1)*48000+312, uint32(i+2), packets...)...)
+	}
+	return data
+}
+
+func TestParseOggOpus(t *testing.T) {
+	if crc := oggCRC([]byte("123456789")); crc != 0x89A1897F {
+		t.Fatalf("校验和错误: %08x", crc)
+	}
+
+	o, err := ParseOggOpus(oggOpusFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Channels != 1 || o.PreSkip != 312 || o.InputRate != 16000 || len(o.Pages) != 4 || o.HeaderPages != 2 || len(o.Packets) != 100 || o.Duration != 2*time.Second {
+		t.Errorf("解析结果错误: %d 声道 %d %d Hz %d 页 %d 包 %v", o.Channels, o.PreSkip, o.InputRate, len(o.Pages), len(o.Packets), o.Duration)
+	}
+	if len(o.Packets[0]) != 300 {
+		t.Errorf("跨段的包长度错误: %d", len(o.Packets[0]))
+	}
+
+	corrupt := oggOpusFile()
+	corrupt[len(corrupt)-1] ^= 1
+	vorbis := oggPage(oggFirst, 0, 0, []byte("\x01vorbis\x00\x00\x00\x00"))
+	vorbis = append(vorbis, oggPage(0, 0, 1, []byte("\x03vorbis"))...)
+	for name, data := range map[string][]byte{
+		"校验和错误":  corrupt,
+		"页不完整":   oggOpusFile()[:100],
+		"Vorbis": vorbis,
+	} {
+		if _, err := ParseOggOpus(data); err == nil {
+			t.Errorf("%s 应返回错误", name)
+		}
+	}
+}
+
+// mp3Frame 构造一个 MPEG-1 Layer III 128kbps 44.1kHz 立体声帧，帧体为 0
+func mp3Frame(padding bool) []byte {
+	size := 417
+	h := []byte{0xFF, 0xFB, 0x90, 0x00}
+	if padding {
+		size++
+		h[2] |= 0x02
+	}
+	frame := make([]byte, size)
+	copy(frame, h)
+	return frame
+}
+
+func TestParseMP3(t *testing.T) {
+	id3 := []byte("ID3\x04\x00\x00\x00\x00\x00\x05abcde")
+	data := append([]byte{}, id3...)
+	for i := 0; i < 100; i++ {
+		data = append(data, mp3Frame(i%3 == 0)...)
+	}
+	data = append(data, "TAG..."...) // 末尾的 ID3v1 标签
+
+	if f := DetectFormat(data); f != FormatMP3 {
+		t.Errorf("格式识别错误: %q", f)
+	}
+	m, err := ParseMP3(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SampleRate != 44100 || m.Channels != 2 || m.FrameSamples != 1152 || len(m.Frames) != 100 || len(m.Frames[0]) != 418 {
+		t.Errorf("解析结果错误: %d Hz %d 声道 %d 帧", m.SampleRate, m.Channels, len(m.Frames))
+	}
+	if m.Duration.Round(time.Millisecond) != 2612*time.Millisecond || math.Abs(float64(m.Bitrate-128000)) > 500 {
+		t.Errorf("时长或码率错误: %v %d", m.Duration, m.Bitrate)
+	}
+
+	layer2 := mp3Frame(false)
+	layer2[1] = 0xFD
+	if _, err := ParseMP3(append(layer2, layer2...)); err == nil {
+		t.Error("Layer II 应返回错误")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	pcm := make([]byte, 3200)
+	if out, err := Decode(EncodeWAV(pcm, 8000, 1), 16000); err != nil || len(out) != 6400 {
+		t.Errorf("WAV 应转换为 16kHz: %d %v", len(out), err)
+	}
+	// 100 帧 44.1kHz 立体声 MP3，约 2.6 秒，混为单声道并转为 16kHz
+	var mp3 []byte
+	for i := 0; i < 100; i++ {
+		mp3 = append(mp3, mp3Frame(i%3 == 0)...)
+	}
+	if out, err := Decode(mp3, 16000); err != nil || math.Abs(float64(len(out)-100*1152*16000/44100*2)) > 4 {
+		t.Errorf("MP3 应解码为 16kHz 单声道: %d %v", len(out), err)
+	}
+	if out, err := Decode(oggOpusFile(), 16000); err != nil || len(out) == 0 {
+		t.Errorf("Opus 应解码为 PCM: %d %v", len(out), err)
+	}
+	surround := oggOpusFile()
+	surround = append(oggPage(oggFirst, 0, 0, []byte("OpusHead\x01\x06\x38\x01\x80\x3e\x00\x00\x00\x00\x01\x04\x02\x00\x04\x01\x02\x03\x05")), surround[len(oggPage(oggFirst, 0, 0, make([]byte, 19))):]...)
+	if _, err := Decode(surround, 16000); !errors.Is(err, ErrNoDecoder) {
+		t.Errorf("6 声道 Opus 应返回 ErrNoDecoder，实际 %v", err)
+	}
+	if _, err := Decode([]byte("ftypM4A "), 16000); err == nil || errors.Is(err, ErrNoDecoder) {
+		t.Errorf("无法识别的格式应返回错误，实际 %v", err)
+	}
+}
+
+// TestDecodeOpus 解码 libopus 编码的 Ogg Opus 文件（testdata/tiny.ogg：单声道，一个 20ms 的 SILK 包，
+// pre-skip 312，最后一页位置 591），以及把这个包重复 50 次得到的 1 秒音频（含 pre-skip）
+func TestDecodeOpus(t *testing.T) {
+	data, err := os.ReadFile("testdata/tiny.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := ParseOggOpus(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm, err := o.Decode()
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	if len(pcm) != (591-312)*2 {
+		t.Errorf("期望去掉 pre-skip 和末尾填充后 279 个 48kHz 采样，实际 %d 个", len(pcm)/2)
+	}
+	if peakOf(pcm) < 1000 {
+		t.Errorf("解码结果不应是静音，峰值 %d", peakOf(pcm))
+	}
+
+	head := oggPage(oggFirst, 0, 0, o.Pages[0].Body)
+	tags := oggPage(0, 0, 1, o.Pages[1].Body)
+	packets := make([][]byte, 50)
+	for i := range packets {
+		packets[i] = o.Packets[0]
+	}
+	second := append(append(head, tags...), oggPage(0, 48000, 2, packets...)...)
+	for _, rate := range []int{16000, 8000} {
+		out, err := Decode(second, rate)
+		if err != nil {
+			t.Fatalf("解码失败: %v", err)
+		}
+		// 1 秒减去 pre-skip
+		if want := rate * (48000 - 312) / 48000; math.Abs(float64(len(out)/2-want)) > 2 {
+			t.Errorf("期望 %dHz 单声道的 PCM %d 个采样，实际 %d 个", rate, want, len(out)/2)
+		}
+		if peakOf(out) < 1000 {
+			t.Errorf("%dHz 的解码结果不应是静音，峰值 %d", rate, peakOf(out))
+		}
+	}
+}
+
+func peakOf(pcm []byte) int {
+	peak := 0
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := int(int16(binary.LittleEndian.Uint16(pcm[i:])))
+		peak = max(peak, v, -v)
+	}
+	return peak
+}
@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Endpointer 按静音门限检测说话结束，用于没有服务端语音检测的识别后端：
+// 说话之后持续 maxEnd 没有达到门限的采样，或者开始后 maxStart 内一直没有说话，视为结束。
+// 门限通常为 MeterConfig.SilenceThreshold，与电平表的 Level.Active 一致。不是并发安全的
+type Endpointer struct {
+	threshold int // 静音门限对应的采样幅度
+	maxStart  int // 开始静音的最长采样数，0 表示不检测
+	maxEnd    int // 结束静音的最长采样数，0 表示不检测
+	heard     bool
+	samples   int // 累计的采样数
+	quiet     int // 连续低于门限的采样数
+}
+
+// NewEndpointer 创建检测 sampleRate 采样率单声道 16位音频的 Endpointer，threshold 为静音门限（dBFS）
+func NewEndpointer(sampleRate int, threshold float64, maxStart, maxEnd time.Duration) *Endpointer {
+	return &Endpointer{
+		threshold: int(math.Ceil(math.Pow(10, threshold/20) * 32768)),
+		maxStart:  int(maxStart * time.Duration(sampleRate) / time.Second),
+		maxEnd:    int(maxEnd * time.Duration(sampleRate) / time.Second),
+	}
+}
+
+// Add 累计一段 16位小端 PCM，返回是否已经检测到说话结束
+func (e *Endpointer) Add(pcm []byte) bool {
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := int(int16(binary.LittleEndian.Uint16(pcm[i:])))
+		if v < 0 {
+			v = -v
+		}
+		e.samples++
+		if v >= e.threshold {
+			e.heard = true
+			e.quiet = 0
+		} else {
+			e.quiet++
+		}
+	}
+	return e.Done()
+}
+
+// Done 是否已经检测到说话结束
+func (e *Endpointer) Done() bool {
+	if e.heard {
+		return e.maxEnd > 0 && e.quiet >= e.maxEnd
+	}
+	return e.maxStart > 0 && e.samples >= e.maxStart
+}
+
+// Heard 是否检测到过声音
+func (e *Endpointer) Heard() bool {
+	return e.heard
+}
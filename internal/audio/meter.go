@@ -0,0 +1,153 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// 电平表：按声道计算 RMS 和峰值（dBFS），按音频时长每隔 Interval 输出一次，
+// 与采集回调每次给出多少帧无关。RMS 经过 attack/release 平滑，
+// 峰值保持 PeakHold 后按 Release 下降，和常见的音频软件的电平表一致。
+
+// MinDBFS 电平的下限，16位采样的动态范围约 96dB，静音时报告该值而不是 -Inf
+const MinDBFS = -96.0
+
+// MeterConfig 电平表参数
+type MeterConfig struct {
+	Interval time.Duration // 输出间隔（按音频时长）
+	Attack   time.Duration // RMS 上升的时间常数
+	Release  time.Duration // RMS 和保持峰值下降的时间常数
+	PeakHold time.Duration // 峰值保持时间
+	// SilenceThreshold 静音门限（dBFS），峰值低于该值视为没有声音：Level.Active 为 false，
+	// 整段录音都低于该值时信号诊断提示音量过低，见 Stats.Problems
+	SilenceThreshold float64
+}
+
+// DefaultMeterConfig 默认参数：每 50ms 输出一次
+func DefaultMeterConfig() MeterConfig {
+	return MeterConfig{
+		Interval:         50 * time.Millisecond,
+		Attack:           10 * time.Millisecond,
+		Release:          300 * time.Millisecond,
+		PeakHold:         time.Second,
+		SilenceThreshold: QuietPeak,
+	}
+}
+
+// Level 一个声道的电平
+type Level struct {
+	RMS      float64 `json:"rms"`       // 平滑后的 RMS（dBFS）
+	Peak     float64 `json:"peak"`      // 本次输出间隔内的峰值（dBFS）
+	PeakHold float64 `json:"peak_hold"` // 保持的峰值（dBFS）
+	Active   bool    `json:"active"`    // 峰值是否达到静音门限
+}
+
+// Meter 多声道电平表，不是并发安全的
+type Meter struct {
+	cfg      MeterConfig
+	channels int
+	period   int     // 每次输出的帧数
+	attack   float64 // 每次输出时 RMS 向新值靠近的比例
+	release  float64
+	holdFor  int // 峰值保持的输出次数
+
+	frames     int
+	sumSquares []float64
+	peaks      []int
+	rms        []float64 // 平滑后的 RMS 幅度（相对满幅）
+	holds      []float64 // 保持的峰值（dBFS）
+	holdLeft   []int     // 峰值还要保持的输出次数
+}
+
+// NewMeter 创建电平表，pcm 为 sampleRate 采样率、channels 声道交错排列的 16位小端 PCM
+func NewMeter(cfg MeterConfig, sampleRate, channels int) *Meter {
+	period := max(int(cfg.Interval.Seconds()*float64(sampleRate)), 1)
+	interval := float64(period) / float64(sampleRate)
+	coefficient := func(tau time.Duration) float64 {
+		if tau <= 0 {
+			return 1
+		}
+		return 1 - math.Exp(-interval/tau.Seconds())
+	}
+	m := &Meter{
+		cfg:        cfg,
+		channels:   channels,
+		period:     period,
+		attack:     coefficient(cfg.Attack),
+		release:    coefficient(cfg.Release),
+		holdFor:    int(cfg.PeakHold.Seconds() / interval),
+		sumSquares: make([]float64, channels),
+		peaks:      make([]int, channels),
+		rms:        make([]float64, channels),
+		holds:      make([]float64, channels),
+		holdLeft:   make([]int, channels),
+	}
+	m.Reset()
+	return m
+}
+
+// Reset 清空电平，开始新的一段录音时调用
+func (m *Meter) Reset() {
+	m.frames = 0
+	for c := 0; c < m.channels; c++ {
+		m.sumSquares[c], m.peaks[c], m.rms[c], m.holdLeft[c] = 0, 0, 0, 0
+		m.holds[c] = MinDBFS
+	}
+}
+
+// Add 累计一段 PCM，每凑满一个输出间隔调用一次 emit
+func (m *Meter) Add(pcm []byte, emit func(levels []Level)) {
+	frameSize := m.channels * 2
+	for i := 0; i+frameSize <= len(pcm); i += frameSize {
+		for c := 0; c < m.channels; c++ {
+			v := int(int16(binary.LittleEndian.Uint16(pcm[i+c*2:])))
+			m.sumSquares[c] += float64(v * v)
+			if v < 0 {
+				v = -v
+			}
+			if v > m.peaks[c] {
+				m.peaks[c] = v
+			}
+		}
+		m.frames++
+		if m.frames == m.period {
+			emit(m.levels())
+		}
+	}
+}
+
+// levels 结束一个输出间隔，计算各声道的电平
+func (m *Meter) levels() []Level {
+	levels := make([]Level, m.channels)
+	for c := range levels {
+		rms := math.Sqrt(m.sumSquares[c]/float64(m.frames)) / 32768
+		k := m.release
+		if rms > m.rms[c] {
+			k = m.attack
+		}
+		m.rms[c] += (rms - m.rms[c]) * k
+
+		peak := clampDBFS(DBFS(float64(m.peaks[c])))
+		if peak >= m.holds[c] {
+			m.holds[c], m.holdLeft[c] = peak, m.holdFor
+		} else if m.holdLeft[c] > 0 {
+			m.holdLeft[c]--
+		} else {
+			m.holds[c] += (peak - m.holds[c]) * m.release
+		}
+
+		db := clampDBFS(20 * math.Log10(m.rms[c]))
+		levels[c] = Level{RMS: db, Peak: peak, PeakHold: m.holds[c], Active: peak >= m.cfg.SilenceThreshold}
+		m.sumSquares[c], m.peaks[c] = 0, 0
+	}
+	m.frames = 0
+	return levels
+}
+
+func clampDBFS(v float64) float64 {
+	if math.IsNaN(v) || v < MinDBFS {
+		return MinDBFS
+	}
+	return v
+}
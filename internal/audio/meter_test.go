@@ -0,0 +1,122 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// tone 生成 seconds 秒交错排列的多声道正弦波，amps 为每个声道的幅度
+func tone(seconds float64, amps ...float64) []byte {
+	n := int(seconds * 16000)
+	pcm := make([]byte, n*len(amps)*2)
+	for i := 0; i < n; i++ {
+		for c, amp := range amps {
+			v := int16(amp * math.Sin(2*math.Pi*440*float64(i)/16000))
+			binary.LittleEndian.PutUint16(pcm[(i*len(amps)+c)*2:], uint16(v))
+		}
+	}
+	return pcm
+}
+
+// feed 按 chunk 字节一段写入，返回所有输出
+func feed(m *Meter, pcm []byte, chunk int) [][]Level {
+	var out [][]Level
+	for i := 0; i < len(pcm); i += chunk {
+		m.Add(pcm[i:min(i+chunk, len(pcm))], func(levels []Level) {
+			out = append(out, levels)
+		})
+	}
+	return out
+}
+
+func TestMeter_Interval(t *testing.T) {
+	pcm := tone(1, 10000)
+	// 输出次数只取决于音频时长，与每次回调的长度无关
+	for _, chunk := range []int{2, 320, 640, 2000, len(pcm)} {
+		if got := feed(NewMeter(DefaultMeterConfig(), 16000, 1), pcm, chunk); len(got) != 20 {
+			t.Errorf("每段 %d 字节时输出 %d 次，期望 20 次", chunk, len(got))
+		}
+	}
+}
+
+func TestMeter_Channels(t *testing.T) {
+	cfg := DefaultMeterConfig()
+	cfg.Attack, cfg.Release = 0, 0 // 不平滑，直接比较每次的值
+	out := feed(NewMeter(cfg, 16000, 2), tone(0.5, 16384, 0), 640)
+	levels := out[len(out)-1]
+	if len(levels) != 2 {
+		t.Fatalf("期望 2 个声道，实际 %d", len(levels))
+	}
+	// 幅度为满幅一半的正弦波：峰值 -6dBFS，RMS 再低 3dB
+	if l := levels[0]; math.Abs(l.Peak+6) > 0.1 || math.Abs(l.RMS+9) > 0.1 || !l.Active {
+		t.Errorf("左声道电平错误: %+v", l)
+	}
+	if l := levels[1]; l.RMS != MinDBFS || l.Peak != MinDBFS || l.Active {
+		t.Errorf("右声道静音时应为 %v: %+v", MinDBFS, l)
+	}
+}
+
+func TestMeter_Ballistics(t *testing.T) {
+	cfg := DefaultMeterConfig()
+	m := NewMeter(cfg, 16000, 1)
+	loud := feed(m, tone(0.5, 16384), 320)
+	if rms := loud[len(loud)-1][0].RMS; math.Abs(rms+9) > 0.1 {
+		t.Errorf("attack 为 10ms 时一个输出间隔后应达到稳定值，实际 %.1f", rms)
+	}
+
+	quiet := feed(m, tone(2, 0), 320)
+	// 峰值保持 1 秒，之后按 release 下降
+	holdFor := int(cfg.PeakHold / cfg.Interval)
+	if l := quiet[holdFor-1][0]; math.Abs(l.PeakHold+6) > 0.1 {
+		t.Errorf("保持期间峰值应不变，实际 %+v", l)
+	}
+	if l := quiet[holdFor+1][0]; l.PeakHold > -7 {
+		t.Errorf("保持结束后峰值应下降，实际 %+v", l)
+	}
+	// RMS 按 300ms 的 release 逐渐下降，而不是立刻跳到静音
+	if rms := quiet[0][0].RMS; rms < -12 || rms > -9 {
+		t.Errorf("release 期间 RMS 应缓慢下降，实际 %.1f", rms)
+	}
+	if l := quiet[len(quiet)-1][0]; l.Active || l.RMS > -50 {
+		t.Errorf("静音 2 秒后应低于门限: %+v", l)
+	}
+
+	m.Reset()
+	if out := feed(m, tone(0.05, 0), 320); out[0][0].PeakHold != MinDBFS || out[0][0].RMS != MinDBFS {
+		t.Errorf("Reset 后应清空电平: %+v", out[0][0])
+	}
+}
+
+func TestEndpointer(t *testing.T) {
+	// 100ms 一段，门限 -45 dBFS
+	e := NewEndpointer(16000, QuietPeak, time.Second, 500*time.Millisecond)
+	for i := 0; i < 9; i++ {
+		if e.Add(tone(0.1, 50)) {
+			t.Fatalf("开始静音 %dms 不应结束", (i+1)*100)
+		}
+	}
+	if e.Add(tone(0.1, 8000)) || !e.Heard() {
+		t.Fatal("说话后应等待结束静音")
+	}
+	for i := 0; i < 4; i++ {
+		if e.Add(tone(0.1, 50)) {
+			t.Fatalf("结束静音 %dms 不应结束", (i+1)*100)
+		}
+	}
+	if !e.Add(tone(0.1, 50)) {
+		t.Error("说话后静音 500ms 应结束")
+	}
+
+	e = NewEndpointer(16000, QuietPeak, time.Second, 500*time.Millisecond)
+	if !e.Add(tone(1, 50)) || e.Heard() {
+		t.Error("开始后 1 秒没有说话应结束")
+	}
+	// 为 0 时不检测
+	e = NewEndpointer(16000, QuietPeak, 0, 0)
+	e.Add(tone(0.1, 8000))
+	if e.Add(tone(10, 0)) {
+		t.Error("结束静音为 0 时不应结束")
+	}
+}
@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// MPEG 音频（MP3）帧解析：跳过 ID3 标签，按帧头切分出每一帧，校验帧头的一致性。
+// 解码使用纯 Go 实现的 go-mp3，不需要外部工具。
+
+// mp3Bitrates Layer III 的码率表（kbps），[0] 为 MPEG-1，[1] 为 MPEG-2/2.5
+var mp3Bitrates = [2][15]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// mp3SampleRates 按版本号（帧头中的 2 位）的采样率表，版本 1 保留
+var mp3SampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG-2.5
+	{},                    // 保留
+	{22050, 24000, 16000}, // MPEG-2
+	{44100, 48000, 32000}, // MPEG-1
+}
+
+// mp3Header MPEG 音频帧头
+type mp3Header struct {
+	version    int // 3 为 MPEG-1，2 为 MPEG-2，0 为 MPEG-2.5
+	sampleRate int
+	channels   int
+	size       int // 帧长度（字节，含帧头）
+	samples    int // 每帧的采样数
+}
+
+// parseMP3Header 解析 4 字节帧头，不是 Layer III 的有效帧头时返回错误
+func parseMP3Header(b []byte) (mp3Header, error) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mp3Header{}, errors.New("缺少帧同步")
+	}
+	h := mp3Header{version: int(b[1]>>3) & 3}
+	if h.version == 1 {
+		return mp3Header{}, errors.New("保留的 MPEG 版本")
+	}
+	if layer := int(b[1]>>1) & 3; layer != 1 {
+		return mp3Header{}, fmt.Errorf("只支持 MPEG Layer III，实际为 Layer %d", 4-layer)
+	}
+	bitrateIndex, rateIndex := int(b[2]>>4), int(b[2]>>2)&3
+	if bitrateIndex == 0 || bitrateIndex == 15 {
+		return mp3Header{}, errors.New("不支持自由码率或无效码率")
+	}
+	if rateIndex == 3 {
+		return mp3Header{}, errors.New("无效的采样率")
+	}
+	padding := int(b[2]>>1) & 1
+	h.sampleRate = mp3SampleRates[h.version][rateIndex]
+	h.channels = 2
+	if b[3]>>6 == 3 {
+		h.channels = 1
+	}
+	if h.version == 3 {
+		h.samples = 1152
+		h.size = 144*mp3Bitrates[0][bitrateIndex]*1000/h.sampleRate + padding
+	} else {
+		h.samples = 576
+		h.size = 72*mp3Bitrates[1][bitrateIndex]*1000/h.sampleRate + padding
+	}
+	return h, nil
+}
+
+// MP3 MPEG Layer III 音频
+type MP3 struct {
+	SampleRate   int
+	Channels     int
+	FrameSamples int      // 每帧的采样数
+	Frames       [][]byte // 每一帧的完整数据（含帧头）
+	Duration     time.Duration
+	Bitrate      int // 平均码率（bps）
+}
+
+// id3Size 文件开头 ID3v2 标签的长度，没有标签时为 0
+func id3Size(data []byte) int {
+	if len(data) < 10 || string(data[0:3]) != "ID3" {
+		return 0
+	}
+	size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+	if data[5]&0x10 != 0 { // 有标签尾
+		size += 10
+	}
+	return min(10+size, len(data))
+}
+
+// ParseMP3 解析 MP3 文件的所有帧
+// 第一帧需要和紧随其后的帧头一致才认为找到了帧同步，末尾的 ID3v1 标签和不完整的帧被忽略
+func ParseMP3(data []byte) (*MP3, error) {
+	pos := id3Size(data)
+	var first mp3Header
+	for ; pos+4 <= len(data); pos++ {
+		h, err := parseMP3Header(data[pos:])
+		if err != nil {
+			continue
+		}
+		// 只有一帧的文件没有后续帧可以对照
+		if pos+h.size == len(data) {
+			first = h
+			break
+		}
+		if next, err := parseMP3Header(data[min(pos+h.size, len(data)):]); err == nil && next.version == h.version && next.sampleRate == h.sampleRate {
+			first = h
+			break
+		}
+	}
+	if first.size == 0 {
+		return nil, errors.New("不是 MP3 文件：找不到有效的 MPEG Layer III 帧")
+	}
+
+	m := &MP3{SampleRate: first.sampleRate, Channels: first.channels, FrameSamples: first.samples}
+	var bytes int
+	for pos+4 <= len(data) {
+		h, err := parseMP3Header(data[pos:])
+		if err != nil || pos+h.size > len(data) {
+			break
+		}
+		if h.version != first.version || h.sampleRate != first.sampleRate {
+			return nil, fmt.Errorf("MP3 文件在 %d 字节处的帧格式与第一帧不一致", pos)
+		}
+		m.Frames = append(m.Frames, data[pos:pos+h.size])
+		bytes += h.size
+		pos += h.size
+	}
+	m.Duration = time.Duration(len(m.Frames)*m.FrameSamples) * time.Second / time.Duration(m.SampleRate)
+	if m.Duration > 0 {
+		m.Bitrate = int(float64(bytes*8) / m.Duration.Seconds())
+	}
+	return m, nil
+}
+
+// Decode 解码所有帧，返回原采样率的 16位立体声 PCM（单声道的文件两个声道相同）
+func (m *MP3) Decode() ([]byte, error) {
+	if len(m.Frames) == 0 {
+		return nil, errors.New("MP3 文件没有完整的帧")
+	}
+	d, err := mp3.NewDecoder(bytes.NewReader(bytes.Join(m.Frames, nil)))
+	if err != nil {
+		return nil, fmt.Errorf("MP3 解码失败: %w", err)
+	}
+	pcm, err := io.ReadAll(d)
+	if err != nil {
+		return nil, fmt.Errorf("MP3 解码失败: %w", err)
+	}
+	return pcm, nil
+}
@@ -0,0 +1,224 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pion/opus"
+)
+
+// Ogg 容器（RFC 3533）和 Ogg 封装的 Opus（RFC 7845）。
+// 解析容器时校验页校验和、取出 Opus 的头信息和音频包；解码使用纯 Go 实现的 pion/opus（RFC 6716），不需要外部工具。
+
+// oggFirst 页头标志：逻辑流的第一页
+const oggFirst = 0x02
+
+// opusRate Opus 的时间基准和解码采样率
+const opusRate = 48000
+
+// opusMaxFrame 一个 Opus 包最多的采样数（120ms，48kHz）
+const opusMaxFrame = opusRate * 120 / 1000
+
+// OggPage Ogg 页
+type OggPage struct {
+	Flags   byte
+	Granule int64  // 页中最后一个完整包结束时的位置，Opus 为 48kHz 采样数
+	Serial  uint32 // 逻辑流编号
+	Data    []byte // 完整的页数据（含页头），转发时原样发送
+	// Segments 页中的各段长度，255 表示包延续到下一段
+	Segments []byte
+	Body     []byte
+}
+
+// ReadOggPages 按顺序解析所有 Ogg 页，校验页头和校验和
+func ReadOggPages(data []byte) ([]OggPage, error) {
+	var pages []OggPage
+	for pos := 0; pos < len(data); {
+		h := data[pos:]
+		if len(h) < 27 || string(h[0:4]) != "OggS" {
+			return nil, fmt.Errorf("Ogg 文件在 %d 字节处缺少页头", pos)
+		}
+		if h[4] != 0 {
+			return nil, fmt.Errorf("不支持的 Ogg 版本: %d", h[4])
+		}
+		n := int(h[26])
+		if len(h) < 27+n {
+			return nil, fmt.Errorf("Ogg 文件在 %d 字节处的页不完整", pos)
+		}
+		segments := h[27 : 27+n]
+		size := 27 + n
+		for _, s := range segments {
+			size += int(s)
+		}
+		if len(h) < size {
+			return nil, fmt.Errorf("Ogg 文件在 %d 字节处的页不完整", pos)
+		}
+		page := h[:size]
+		if want, got := binary.LittleEndian.Uint32(page[22:26]), oggCRC(page); want != got {
+			return nil, fmt.Errorf("Ogg 文件在 %d 字节处的页校验和错误", pos)
+		}
+		pages = append(pages, OggPage{
+			Flags:    page[5],
+			Granule:  int64(binary.LittleEndian.Uint64(page[6:14])),
+			Serial:   binary.LittleEndian.Uint32(page[14:18]),
+			Data:     page,
+			Segments: segments,
+			Body:     page[27+n:],
+		})
+		pos += size
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("Ogg 文件为空")
+	}
+	return pages, nil
+}
+
+// oggPackets 按段表把页拼成包，page 必须属于同一个逻辑流
+func oggPackets(pages []OggPage) [][]byte {
+	var packets [][]byte
+	var cur []byte
+	for _, p := range pages {
+		body := p.Body
+		for _, s := range p.Segments {
+			cur = append(cur, body[:s]...)
+			body = body[s:]
+			if s < 255 {
+				packets = append(packets, cur)
+				cur = nil
+			}
+		}
+	}
+	return packets
+}
+
+// oggCRC 计算页的校验和（多项式 0x04c11db7，不反转，校验和字段按 0 计算）
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for i, b := range page {
+		if i >= 22 && i < 26 {
+			b = 0
+		}
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+var oggCRCTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+// OggOpus Ogg 封装的 Opus 音频
+type OggOpus struct {
+	Channels  int
+	PreSkip   int // 解码后需要丢弃的开头采样数（48kHz）
+	InputRate int // 编码前的采样率，仅供参考，Opus 总是按 48kHz 计时
+	Pages     []OggPage
+	// HeaderPages 开头只包含 OpusHead 和 OpusTags 的页数，之后的页只包含音频包
+	HeaderPages int
+	Packets     [][]byte // 音频包，不含 OpusHead 和 OpusTags
+	Duration    time.Duration
+}
+
+// ParseOggOpus 解析 Ogg Opus 文件，只取第一个逻辑流
+func ParseOggOpus(data []byte) (*OggOpus, error) {
+	all, err := ReadOggPages(data)
+	if err != nil {
+		return nil, err
+	}
+	if all[0].Flags&oggFirst == 0 {
+		return nil, errors.New("Ogg 文件的第一页不是流的开始")
+	}
+	serial := all[0].Serial
+	var pages []OggPage
+	for _, p := range all {
+		if p.Serial == serial {
+			pages = append(pages, p)
+		}
+	}
+	packets := oggPackets(pages)
+	if len(packets) < 2 {
+		return nil, errors.New("Ogg 文件缺少头信息")
+	}
+	head := packets[0]
+	if len(head) < 8 || string(head[0:8]) != "OpusHead" {
+		if len(head) >= 7 && string(head[1:7]) == "vorbis" {
+			return nil, errors.New("不支持 Ogg Vorbis，只支持 Ogg Opus")
+		}
+		return nil, errors.New("不支持的 Ogg 编码，只支持 Opus")
+	}
+	if len(head) < 19 {
+		return nil, errors.New("OpusHead 长度错误")
+	}
+	if head[8]>>4 != 0 {
+		return nil, fmt.Errorf("不支持的 Opus 版本: %d", head[8])
+	}
+	if len(packets[1]) < 8 || string(packets[1][0:8]) != "OpusTags" {
+		return nil, errors.New("Ogg Opus 文件缺少 OpusTags")
+	}
+	o := &OggOpus{
+		Channels:  int(head[9]),
+		PreSkip:   int(binary.LittleEndian.Uint16(head[10:12])),
+		InputRate: int(binary.LittleEndian.Uint32(head[12:16])),
+		Pages:     pages,
+		Packets:   packets[2:],
+	}
+	if o.Channels == 0 {
+		return nil, errors.New("OpusHead 声道数为 0")
+	}
+	// 头信息包之后的音频从新的一页开始（RFC 7845 第 3 节）
+	for headers := 0; headers < 2; o.HeaderPages++ {
+		for _, s := range pages[o.HeaderPages].Segments {
+			if s < 255 {
+				headers++
+			}
+		}
+	}
+	if last := pages[len(pages)-1].Granule; last > int64(o.PreSkip) {
+		o.Duration = time.Duration(last-int64(o.PreSkip)) * time.Second / 48000
+	}
+	return o, nil
+}
+
+// Decode 解码所有音频包，返回 48kHz、Channels 声道交错排列的 16位 PCM
+// 按 RFC 7845 去掉开头 PreSkip 个采样，并按最后一页的位置去掉末尾的填充；只支持单声道和立体声
+func (o *OggOpus) Decode() ([]byte, error) {
+	if o.Channels > 2 {
+		return nil, fmt.Errorf("%d 声道的 Opus 音频%w，只支持单声道和立体声", o.Channels, ErrNoDecoder)
+	}
+	d, err := opus.NewDecoderWithOutput(opusRate, o.Channels)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Opus 解码器失败: %w", err)
+	}
+	buf := make([]int16, opusMaxFrame*o.Channels)
+	var pcm []byte
+	for i, p := range o.Packets {
+		n, err := d.DecodeToInt16(p, buf)
+		if err != nil {
+			return nil, fmt.Errorf("Opus 第 %d 个音频包解码失败: %w", i+1, err)
+		}
+		for _, v := range buf[:n*o.Channels] {
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(v))
+		}
+	}
+
+	frame := 2 * o.Channels
+	end := len(pcm) / frame
+	if last := o.Pages[len(o.Pages)-1].Granule; last >= 0 && last < int64(end) {
+		end = int(last)
+	}
+	start := min(o.PreSkip, end)
+	return pcm[start*frame : end*frame], nil
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// 采样率转换：带 Blackman 窗的 sinc 插值，降采样时按目标采样率的奈奎斯特频率低通，避免混叠。
+// 用于把文件中任意采样率、声道数的音频转为识别需要的单声道 PCM，不用于实时采集。
+
+const (
+	sincZeros = 8   // 插值核每侧的过零点数
+	sincSteps = 256 // 相邻过零点之间的查表点数
+)
+
+// sincTable 插值核 sinc(x)·blackman(x) 在 [0, sincZeros] 上的取值
+var sincTable = func() []float64 {
+	t := make([]float64, sincZeros*sincSteps+1)
+	for i := range t {
+		x := float64(i) / sincSteps
+		w := 0.42 + 0.5*math.Cos(math.Pi*x/sincZeros) + 0.08*math.Cos(2*math.Pi*x/sincZeros)
+		s := 1.0
+		if x != 0 {
+			s = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		t[i] = s * w
+	}
+	return t
+}()
+
+func sincKernel(x float64) float64 {
+	x = math.Abs(x) * sincSteps
+	i := int(x)
+	if i >= len(sincTable)-1 {
+		return 0
+	}
+	return sincTable[i] + (sincTable[i+1]-sincTable[i])*(x-float64(i))
+}
+
+// Resample 把采样率为 from 的采样转为采样率 to
+func Resample(x []float64, from, to int) []float64 {
+	if from == to || len(x) == 0 {
+		return x
+	}
+	scale := math.Min(1, float64(to)/float64(from)) // 低通截止频率相对输入奈奎斯特频率的比例
+	step := float64(from) / float64(to)
+	half := sincZeros / scale
+	out := make([]float64, int(int64(len(x))*int64(to)/int64(from)))
+	for i := range out {
+		t := float64(i) * step
+		lo := max(int(math.Ceil(t-half)), 0)
+		hi := min(int(t+half), len(x)-1)
+		var sum float64
+		for j := lo; j <= hi; j++ {
+			sum += x[j] * sincKernel((t-float64(j))*scale)
+		}
+		out[i] = sum * scale
+	}
+	return out
+}
+
+// Convert 把 sampleRate 采样率、channels 声道交错排列的 16位 PCM 混为单声道并转为 targetRate 采样率
+func Convert(pcm []byte, sampleRate, channels, targetRate int) []byte {
+	if sampleRate == targetRate && channels == 1 {
+		return pcm
+	}
+	frames := len(pcm) / 2 / channels
+	x := make([]float64, frames)
+	for i := range x {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			sum += float64(int16(binary.LittleEndian.Uint16(pcm[(i*channels+c)*2:])))
+		}
+		x[i] = sum / float64(channels)
+	}
+	x = Resample(x, sampleRate, targetRate)
+	out := make([]byte, len(x)*2)
+	for i, v := range x {
+		v = math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(v)))
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(v)))
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// 信号质量诊断：麦克风被静音、音量过低、削波、直流偏移都会让识别服务返回
+// 40270002（没有有效的识别结果），用户只能看到笼统的错误。
+// Analyzer 累计统计 16位 PCM 采样，Stats.Problems 给出可以直接提示给用户的问题。
+
+// 诊断阈值
+const (
+	ClipLevel     = 32767 - 64 // 绝对值达到该值的采样视为削波
+	MaxClipRatio  = 0.001      // 削波采样超过该比例时提示
+	MaxDCOffset   = 0.03       // 直流偏移（均值占满幅的比例）超过该值时提示
+	QuietPeak     = -45.0      // 默认的静音门限（dBFS），峰值低于该值时视为音量过低，见 MeterConfig.SilenceThreshold
+	QuietDuration = 3.0        // 音量过低需要持续的秒数，避免说话前的停顿被误判
+)
+
+// Stats 一段音频的信号统计
+type Stats struct {
+	Seconds  float64 `json:"seconds"`   // 时长
+	Peak     float64 `json:"peak"`      // 峰值（dBFS），全部为 0 时为 -Inf
+	RMS      float64 `json:"rms"`       // 均方根（dBFS），全部为 0 时为 -Inf
+	Clipping float64 `json:"clipping"`  // 削波采样的比例
+	DCOffset float64 `json:"dc_offset"` // 直流偏移，均值占满幅的比例
+	Zero     bool    `json:"zero"`      // 所有采样都为 0
+}
+
+// Problem 信号问题
+type Problem struct {
+	Code    string // muted、quiet、clipping、dc
+	Message string
+}
+
+// Analyzer 累计统计信号质量，不是并发安全的
+type Analyzer struct {
+	sampleRate int
+	count      int
+	sum        float64
+	sumSquares float64
+	peak       int
+	clipped    int
+}
+
+// NewAnalyzer 创建统计 sampleRate 采样率单声道音频的分析器
+func NewAnalyzer(sampleRate int) *Analyzer {
+	return &Analyzer{sampleRate: sampleRate}
+}
+
+// Add 累计一段 16位小端 PCM
+func (a *Analyzer) Add(pcm []byte) {
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := int(int16(binary.LittleEndian.Uint16(pcm[i:])))
+		a.sum += float64(v)
+		a.sumSquares += float64(v * v)
+		if v < 0 {
+			v = -v
+		}
+		if v > a.peak {
+			a.peak = v
+		}
+		if v >= ClipLevel {
+			a.clipped++
+		}
+		a.count++
+	}
+}
+
+// Reset 清空统计
+func (a *Analyzer) Reset() {
+	*a = Analyzer{sampleRate: a.sampleRate}
+}
+
+// Stats 返回累计的统计
+func (a *Analyzer) Stats() Stats {
+	if a.count == 0 {
+		return Stats{Peak: math.Inf(-1), RMS: math.Inf(-1)}
+	}
+	n := float64(a.count)
+	return Stats{
+		Seconds:  n / float64(a.sampleRate),
+		Peak:     DBFS(float64(a.peak)),
+		RMS:      DBFS(math.Sqrt(a.sumSquares / n)),
+		Clipping: float64(a.clipped) / n,
+		DCOffset: a.sum / n / 32768,
+		Zero:     a.peak == 0,
+	}
+}
+
+// DBFS 把 16位采样的幅度转为相对满幅的分贝数，0 返回 -Inf
+func DBFS(amplitude float64) float64 {
+	return 20 * math.Log10(amplitude/32768)
+}
+
+// Problems 返回这段音频的信号问题，没有问题时为空
+// quiet 为静音门限（dBFS），通常为 MeterConfig.SilenceThreshold，峰值低于该值时视为音量过低，
+// 需要至少 QuietDuration 秒的音频才能判断
+func (s Stats) Problems(quiet float64) []Problem {
+	if s.Seconds == 0 {
+		return nil
+	}
+	if s.Zero {
+		return []Problem{{"muted", "麦克风可能处于静音状态：采集到的音频全部为 0，请检查系统的麦克风开关和权限"}}
+	}
+	var problems []Problem
+	if s.Seconds >= QuietDuration && s.Peak < quiet {
+		problems = append(problems, Problem{"quiet", fmt.Sprintf("音量过低：峰值只有 %.0f dBFS，请靠近麦克风或调高输入音量", s.Peak)})
+	}
+	if s.Clipping > MaxClipRatio {
+		problems = append(problems, Problem{"clipping", fmt.Sprintf("音频削波：%.1f%% 的采样达到满幅，请调低输入音量", s.Clipping*100)})
+	}
+	if math.Abs(s.DCOffset) > MaxDCOffset {
+		problems = append(problems, Problem{"dc", fmt.Sprintf("直流偏移过大（%.1f%%），可能是设备或驱动问题，可以开启 -hpf 去除", s.DCOffset*100)})
+	}
+	return problems
+}
@@ -0,0 +1,79 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// pcm16 生成 seconds 秒 16kHz 的采样，f 返回第 i 个采样
+func pcm16(seconds float64, f func(i int) int16) []byte {
+	n := int(seconds * 16000)
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(f(i)))
+	}
+	return pcm
+}
+
+func sineAt(amp float64) func(i int) int16 {
+	return func(i int) int16 { return int16(amp * math.Sin(2*math.Pi*440*float64(i)/16000)) }
+}
+
+func codes(problems []Problem) []string {
+	var c []string
+	for _, p := range problems {
+		c = append(c, p.Code)
+	}
+	return c
+}
+
+func TestAnalyzer(t *testing.T) {
+	a := NewAnalyzer(16000)
+	a.Add(pcm16(1, sineAt(16384)))
+	s := a.Stats()
+	// 半满幅的正弦波：峰值 -6dBFS，RMS 低 3dB
+	if s.Seconds != 1 || math.Abs(s.Peak+6.02) > 0.1 || math.Abs(s.RMS+9.03) > 0.1 || s.Clipping != 0 || math.Abs(s.DCOffset) > 1e-3 {
+		t.Errorf("统计错误: %+v", s)
+	}
+	if p := s.Problems(QuietPeak); len(p) != 0 {
+		t.Errorf("正常信号不应有问题: %v", p)
+	}
+
+	a.Reset()
+	if s := a.Stats(); s.Seconds != 0 || s.Problems(QuietPeak) != nil {
+		t.Errorf("Reset 后应为空: %+v", s)
+	}
+}
+
+func TestStats_Problems(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds float64
+		f       func(i int) int16
+		want    []string
+	}{
+		{"静音", 0.5, func(int) int16 { return 0 }, []string{"muted"}},
+		{"音量过低但时间太短", 1, sineAt(100), nil},
+		{"音量过低", 3, sineAt(100), []string{"quiet"}},
+		{"削波", 1, func(i int) int16 {
+			// 超过满幅的正弦波被截断
+			return int16(math.Max(-32768, math.Min(32767, 40000*math.Sin(2*math.Pi*440*float64(i)/16000))))
+		}, []string{"clipping"}},
+		{"直流偏移", 1, func(i int) int16 { return 2000 + sineAt(8000)(i) }, []string{"dc"}},
+	}
+	for _, tt := range tests {
+		a := NewAnalyzer(16000)
+		a.Add(pcm16(tt.seconds, tt.f))
+		got := codes(a.Stats().Problems(QuietPeak))
+		if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("%s: 期望 %v，实际 %v", tt.name, tt.want, got)
+		}
+	}
+	// 调低静音门限后，同样的小信号不再提示音量过低
+	a := NewAnalyzer(16000)
+	a.Add(pcm16(3, sineAt(100)))
+	if got := codes(a.Stats().Problems(-60)); got != nil {
+		t.Errorf("门限 -60dBFS 时不应提示音量过低: %v", got)
+	}
+}
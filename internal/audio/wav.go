@@ -0,0 +1,197 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// WAVHeaderSize PCM WAV 文件头长度
+const WAVHeaderSize = 44
+
+// WAVHeader 返回 16位 PCM 的 WAV 文件头
+// dataSize 为 PCM 数据的字节数，流式写入时可先写 0，结束后再回填
+func WAVHeader(dataSize uint32, sampleRate, channels int) []byte {
+	const bitsPerSample = 16
+	blockAlign := channels * bitsPerSample / 8
+
+	h := make([]byte, WAVHeaderSize)
+	copy(h[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:8], 36+dataSize)
+	copy(h[8:12], "WAVE")
+	copy(h[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:20], 16) // fmt 块长度
+	binary.LittleEndian.PutUint16(h[20:22], 1)  // PCM
+	binary.LittleEndian.PutUint16(h[22:24], uint16(channels))
+	binary.LittleEndian.PutUint32(h[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(h[28:32], uint32(sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(h[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(h[34:36], bitsPerSample)
+	copy(h[36:40], "data")
+	binary.LittleEndian.PutUint32(h[40:44], dataSize)
+	return h
+}
+
+// EncodeWAV 将 16位 PCM 数据封装为 WAV 文件内容
+func EncodeWAV(pcm []byte, sampleRate, channels int) []byte {
+	out := make([]byte, 0, WAVHeaderSize+len(pcm))
+	out = append(out, WAVHeader(uint32(len(pcm)), sampleRate, channels)...)
+	return append(out, pcm...)
+}
+
+// WAV 编码格式（fmt 块的 wFormatTag）
+const (
+	wavPCM        = 1
+	wavFloat      = 3
+	wavALaw       = 6
+	wavMuLaw      = 7
+	wavExtensible = 0xFFFE // 实际格式在 SubFormat GUID 的前两个字节
+)
+
+// wavFormat fmt 块的内容
+type wavFormat struct {
+	tag        int
+	channels   int
+	sampleRate int
+	blockAlign int
+	bits       int
+}
+
+// parseWAVFormat 解析并校验 fmt 块
+func parseWAVFormat(body []byte) (wavFormat, error) {
+	if len(body) < 16 {
+		return wavFormat{}, errors.New("WAV 文件 fmt 块长度错误")
+	}
+	f := wavFormat{
+		tag:        int(binary.LittleEndian.Uint16(body[0:2])),
+		channels:   int(binary.LittleEndian.Uint16(body[2:4])),
+		sampleRate: int(binary.LittleEndian.Uint32(body[4:8])),
+		blockAlign: int(binary.LittleEndian.Uint16(body[12:14])),
+		bits:       int(binary.LittleEndian.Uint16(body[14:16])),
+	}
+	if f.tag == wavExtensible {
+		if len(body) < 40 {
+			return wavFormat{}, errors.New("WAV 文件 fmt 块长度错误")
+		}
+		f.tag = int(binary.LittleEndian.Uint16(body[24:26]))
+	}
+	if f.channels == 0 || f.sampleRate == 0 {
+		return wavFormat{}, fmt.Errorf("WAV 文件格式错误: %d Hz %d 声道", f.sampleRate, f.channels)
+	}
+	supported := false
+	switch f.tag {
+	case wavPCM:
+		supported = f.bits == 8 || f.bits == 16 || f.bits == 24 || f.bits == 32
+	case wavFloat:
+		supported = f.bits == 32 || f.bits == 64
+	case wavALaw, wavMuLaw:
+		supported = f.bits == 8
+	default:
+		return wavFormat{}, fmt.Errorf("不支持的 WAV 编码格式: %d，只支持 PCM、浮点和 G.711", f.tag)
+	}
+	if !supported {
+		return wavFormat{}, fmt.Errorf("不支持的 WAV 位深: 编码格式 %d 的 %d 位", f.tag, f.bits)
+	}
+	if f.blockAlign != f.channels*f.bits/8 {
+		return wavFormat{}, fmt.Errorf("WAV 文件块对齐错误: %d，%d 声道 %d 位应为 %d", f.blockAlign, f.channels, f.bits, f.channels*f.bits/8)
+	}
+	return f, nil
+}
+
+// toPCM16 把 data 块的采样转为 16位小端 PCM
+func (f wavFormat) toPCM16(data []byte) []byte {
+	if f.tag == wavPCM && f.bits == 16 {
+		return data
+	}
+	size := f.bits / 8
+	out := make([]byte, len(data)/size*2)
+	for i := 0; i < len(data)/size; i++ {
+		b := data[i*size:]
+		var v int16
+		switch {
+		case f.tag == wavALaw:
+			v = aLaw(b[0])
+		case f.tag == wavMuLaw:
+			v = muLaw(b[0])
+		case f.tag == wavFloat && size == 4:
+			v = floatToInt16(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		case f.tag == wavFloat:
+			v = floatToInt16(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		case size == 1:
+			// 8 位 PCM 是无符号的
+			v = int16(int(b[0])-128) << 8
+		case size == 3:
+			v = int16(uint16(b[1]) | uint16(b[2])<<8)
+		case size == 4:
+			v = int16(binary.LittleEndian.Uint32(b) >> 16)
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(v))
+	}
+	return out
+}
+
+func floatToInt16(v float64) int16 {
+	return int16(math.Round(math.Max(-1, math.Min(1, v)) * 32767))
+}
+
+// aLaw G.711 A 律解码
+func aLaw(a byte) int16 {
+	a ^= 0x55
+	t := int(a&0x0F)<<4 + 8
+	if seg := int(a&0x70) >> 4; seg > 0 {
+		t = (t + 0x100) << (seg - 1)
+	}
+	if a&0x80 == 0 {
+		t = -t
+	}
+	return int16(t)
+}
+
+// muLaw G.711 μ 律解码
+func muLaw(u byte) int16 {
+	u = ^u
+	t := (int(u&0x0F)<<3 + 0x84) << (int(u&0x70) >> 4)
+	if u&0x80 != 0 {
+		return int16(0x84 - t)
+	}
+	return int16(t - 0x84)
+}
+
+// DecodeWAV 解析 WAV 文件，返回 16位 PCM 数据、采样率和声道数
+// 支持 8/16/24/32 位整数 PCM、32/64 位浮点和 G.711 A 律/μ 律，其他位深统一转为 16 位
+func DecodeWAV(data []byte) (pcm []byte, sampleRate, channels int, err error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, errors.New("不是 WAV 文件")
+	}
+	var format *wavFormat
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		if size > len(body) {
+			if id != "data" {
+				return nil, 0, 0, fmt.Errorf("WAV 文件 %s 块不完整", id)
+			}
+			// 流式写入的文件可能没有回填长度，取到文件末尾
+			size = len(body)
+		}
+		body = body[:size]
+		switch id {
+		case "fmt ":
+			f, err := parseWAVFormat(body)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			format = &f
+		case "data":
+			if format == nil {
+				return nil, 0, 0, errors.New("WAV 文件缺少 fmt 块")
+			}
+			return format.toPCM16(body), format.sampleRate, format.channels, nil
+		}
+		// 块长度为奇数时有一个填充字节
+		pos += 8 + size + size%2
+	}
+	return nil, 0, 0, errors.New("WAV 文件缺少 data 块")
+}
@@ -0,0 +1,105 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDecodeWAV(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4, 5, 6}
+	got, rate, channels, err := DecodeWAV(EncodeWAV(pcm, 16000, 1))
+	if err != nil || !bytes.Equal(got, pcm) || rate != 16000 || channels != 1 {
+		t.Errorf("解析结果错误: %v %d %d %v", got, rate, channels, err)
+	}
+
+	// data 块之前有其他块，长度为奇数
+	wav := EncodeWAV(pcm, 8000, 2)
+	extra := append([]byte("LIST\x03\x00\x00\x00abc\x00"), wav[36:]...)
+	wav = append(wav[:36:36], extra...)
+	if got, rate, channels, err := DecodeWAV(wav); err != nil || !bytes.Equal(got, pcm) || rate != 8000 || channels != 2 {
+		t.Errorf("跳过其他块后解析结果错误: %v %d %d %v", got, rate, channels, err)
+	}
+
+	if _, _, _, err := DecodeWAV(pcm); err == nil {
+		t.Error("不是 WAV 文件时应返回错误")
+	}
+	float := EncodeWAV(pcm, 16000, 1)
+	float[20] = 3
+	if _, _, _, err := DecodeWAV(float); err == nil {
+		t.Error("不支持的编码格式应返回错误")
+	}
+}
+
+// wavOf 构造指定编码格式和位深的单声道 WAV 文件
+func wavOf(tag, bits int, data []byte) []byte {
+	wav := EncodeWAV(data, 16000, 1)
+	binary.LittleEndian.PutUint16(wav[20:22], uint16(tag))
+	binary.LittleEndian.PutUint16(wav[32:34], uint16(bits/8))
+	binary.LittleEndian.PutUint16(wav[34:36], uint16(bits))
+	return wav
+}
+
+func TestDecodeWAV_Formats(t *testing.T) {
+	float32s := make([]byte, 12)
+	for i, v := range []float32{0.5, -1, 2} {
+		binary.LittleEndian.PutUint32(float32s[i*4:], math.Float32bits(v))
+	}
+	float64s := make([]byte, 8)
+	binary.LittleEndian.PutUint64(float64s, math.Float64bits(-0.25))
+
+	tests := []struct {
+		name      string
+		tag, bits int
+		data      []byte
+		want      []int16
+	}{
+		{"8位", wavPCM, 8, []byte{0x80, 0xFF, 0x00}, []int16{0, 127 << 8, -32768}},
+		{"24位", wavPCM, 24, []byte{0xFF, 0x34, 0x12, 0x00, 0x00, 0x80}, []int16{0x1234, -32768}},
+		{"32位", wavPCM, 32, []byte{0xFF, 0xFF, 0x34, 0x12}, []int16{0x1234}},
+		{"32位浮点", wavFloat, 32, float32s, []int16{16384, -32767, 32767}},
+		{"64位浮点", wavFloat, 64, float64s, []int16{-8192}},
+		{"A律", wavALaw, 8, []byte{0xD5, 0x55, 0xAA}, []int16{8, -8, 32256}},
+		{"μ律", wavMuLaw, 8, []byte{0xFF, 0x00}, []int16{0, -32124}},
+	}
+	for _, tt := range tests {
+		pcm, _, _, err := DecodeWAV(wavOf(tt.tag, tt.bits, tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []int16
+		for i := 0; i+1 < len(pcm); i += 2 {
+			got = append(got, int16(binary.LittleEndian.Uint16(pcm[i:])))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: 期望 %v，实际 %v", tt.name, tt.want, got)
+		}
+	}
+
+	// WAVE_FORMAT_EXTENSIBLE：实际格式在 SubFormat 中
+	ext := EncodeWAV(nil, 48000, 2)
+	fmtChunk := make([]byte, 40)
+	copy(fmtChunk, ext[20:36])
+	binary.LittleEndian.PutUint16(fmtChunk[0:2], wavExtensible)
+	binary.LittleEndian.PutUint16(fmtChunk[16:18], 22)
+	binary.LittleEndian.PutUint16(fmtChunk[24:26], wavPCM)
+	wav := append([]byte("RIFF\x00\x00\x00\x00WAVEfmt \x28\x00\x00\x00"), fmtChunk...)
+	wav = append(wav, "data\x04\x00\x00\x00\x01\x00\x02\x00"...)
+	if pcm, rate, channels, err := DecodeWAV(wav); err != nil || rate != 48000 || channels != 2 || len(pcm) != 4 {
+		t.Errorf("解析 WAVE_FORMAT_EXTENSIBLE 错误: %v %d %d %v", pcm, rate, channels, err)
+	}
+
+	for name, wav := range map[string][]byte{
+		"12位":   wavOf(wavPCM, 12, nil),
+		"16位浮点": wavOf(wavFloat, 16, nil),
+		"块对齐错误": func() []byte { w := wavOf(wavPCM, 24, nil); w[32] = 4; return w }(),
+		"声道数为0": func() []byte { w := wavOf(wavPCM, 16, nil); w[22] = 0; return w }(),
+	} {
+		if _, _, _, err := DecodeWAV(wav); err == nil {
+			t.Errorf("%s 应返回错误", name)
+		}
+	}
+}
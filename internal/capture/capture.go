@@ -2,31 +2,44 @@ package capture
 
 import (
 	"fmt"
-	"math"
+	"runtime"
 	"time"
 
 	"github.com/gen2brain/malgo"
+	"github.com/shellus/voiceWin/internal/audio"
+	"github.com/shellus/voiceWin/internal/dsp"
 )
 
 // AudioCapture 音频捕获器
 type AudioCapture struct {
-	config         *Config
-	context        *malgo.AllocatedContext
-	device         *malgo.Device
-	processor      *AudioProcessor
-	OnVolumeChange func(volume float64)
-	OnAudioData    func()
-	OnError        func(err error)
-	lastDataCall   time.Time // 上次数据回调的时间
-	lastVolume     float64   // 上次音量值
+	config       *Config
+	context      *malgo.AllocatedContext
+	input        Source // 首次 Start 时按 source 打开，切换设备、来源或声道数时关闭
+	processor    *AudioProcessor
+	OnLevel      func(levels []audio.Level) // 每个声道的电平，按 Config.Meter.Interval 固定间隔回调
+	OnAudioData  func() error               // 节流后的数据回调，返回的错误交给 OnError
+	OnError      func(err error)
+	lastDataCall time.Time   // 上次数据回调的时间
+	timing       frameTiming // 回调时间，用于丢帧和抖动指标
+	deviceName   string      // 选择的采集设备名称，为空时使用系统默认设备
+	source       string      // 采集来源，见 SourceMic 等，为空时为麦克风
+	loopbackName string      // 采集系统声音的设备名称，为空时使用默认输出设备
+	custom       bool        // input 由 SetInput 指定，不按 source 打开
+}
+
+// Device 采集设备信息
+type Device struct {
+	Name    string `json:"name"`
+	Default bool   `json:"default"` // 是否为系统默认设备
 }
 
 // NewAudioCapture 创建新的音频捕获器
-func NewAudioCapture() *AudioCapture {
+// backends 为空时按平台的默认顺序选择音频后端，没有声卡的测试环境可以使用 malgo.BackendNull
+func NewAudioCapture(backends ...malgo.Backend) *AudioCapture {
 	config := DefaultConfig()
 	config.SampleRate = 16000 // 设置采样率为16kHz
 
-	context, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	context, err := malgo.InitContext(backends, malgo.ContextConfig{}, nil)
 	if err != nil {
 		return nil
 	}
@@ -38,55 +51,83 @@ func NewAudioCapture() *AudioCapture {
 	}
 }
 
-// Start 开始捕获音频
+// Start 开始捕获音频，Stop 之后可以再次调用
 func (ac *AudioCapture) Start() error {
-	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
-	deviceConfig.Capture.Format = malgo.FormatS16
-	deviceConfig.Capture.Channels = ac.config.Channels
-	deviceConfig.SampleRate = ac.config.SampleRate
-	deviceConfig.Alsa.NoMMap = 1
-
-	onRecvFrames := func(pSample2, pSample []byte, framecount uint32) {
-		volume := ac.processor.ProcessAudio(pSample, framecount)
-
-		// 只在音量变化时触发回调
-		if ac.OnVolumeChange != nil && math.Abs(volume-ac.lastVolume) > 20 {
-			ac.OnVolumeChange(volume)
-			ac.lastVolume = volume
-		}
+	// 停止期间没有回调，重新开始计算回调间隔
+	ac.timing = frameTiming{}
+	ac.processor.Reset()
 
-		// 节流处理数据回调
-		if ac.OnAudioData != nil {
-			now := time.Now()
-			if now.Sub(ac.lastDataCall) >= ac.config.CallbackInterval {
-				ac.OnAudioData()
-				ac.lastDataCall = now
-			}
+	// 已打开过设备（Stop 之后再次 Start）时直接启动
+	if ac.input == nil {
+		input, err := ac.openInput()
+		if err != nil {
+			return err
 		}
+		ac.input = input
 	}
+	return ac.input.Start(ac.onData)
+}
 
-	device, err := malgo.InitDevice(ac.context.Context, deviceConfig, malgo.DeviceCallbacks{
-		Data: onRecvFrames,
+// onData 处理来源的一次回调：计算电平、预处理后写入缓冲区，按 CallbackInterval 节流回调 OnAudioData
+func (ac *AudioCapture) onData(pcm []byte) {
+	frames := uint32(len(pcm)) / (ac.config.Channels * 2)
+	ac.timing.observe(time.Now(), frames, ac.config.SampleRate)
+	ac.processor.ProcessAudio(pcm, func(levels []audio.Level) {
+		if ac.OnLevel != nil {
+			ac.OnLevel(levels)
+		}
 	})
-	if err != nil {
-		return fmt.Errorf("初始化设备失败: %w", err)
-	}
 
-	ac.device = device
-	if err := device.Start(); err != nil {
-		return fmt.Errorf("启动设备失败: %w", err)
+	// 节流处理数据回调
+	if ac.OnAudioData != nil {
+		now := time.Now()
+		if now.Sub(ac.lastDataCall) >= ac.config.CallbackInterval {
+			if err := ac.OnAudioData(); err != nil && ac.OnError != nil {
+				ac.OnError(err)
+			}
+			ac.lastDataCall = now
+		}
 	}
+}
 
-	return nil
+// openInput 按采集来源打开设备
+func (ac *AudioCapture) openInput() (Source, error) {
+	if ac.context == nil {
+		return nil, fmt.Errorf("音频上下文已关闭")
+	}
+	var mic, loopback Source
+	if ac.source != SourceLoopback {
+		var id *malgo.DeviceID
+		if ac.deviceName != "" {
+			found, err := ac.findDevice(ac.deviceName)
+			if err != nil {
+				return nil, err
+			}
+			id = &found
+		}
+		mic = newDeviceSource(ac.context, malgo.Capture, id, ac.config)
+	}
+	if ac.source == SourceLoopback || ac.source == SourceMix {
+		kind, id, err := loopbackDevice(ac.context, ac.loopbackName)
+		if err != nil {
+			return nil, err
+		}
+		loopback = newDeviceSource(ac.context, kind, id, ac.config)
+	}
+	switch {
+	case mic == nil:
+		return loopback, nil
+	case loopback == nil:
+		return mic, nil
+	}
+	return newMixSource(mic, loopback, ac.config), nil
 }
 
 // Stop 停止音频捕获，但保持资源不释放，可以再次Start
 func (ac *AudioCapture) Stop() error {
-	if ac.device != nil {
+	if ac.input != nil {
 		// 只停止设备，不释放
-		if err := ac.device.Stop(); err != nil {
-			return fmt.Errorf("停止设备失败: %w", err)
-		}
+		return ac.input.Stop()
 	}
 	return nil
 }
@@ -99,9 +140,9 @@ func (ac *AudioCapture) Close() error {
 	}
 
 	// 释放设备资源
-	if ac.device != nil {
-		ac.device.Uninit()
-		ac.device = nil
+	if ac.input != nil {
+		ac.input.Close()
+		ac.input = nil
 	}
 
 	// 释放上下文资源
@@ -114,7 +155,7 @@ func (ac *AudioCapture) Close() error {
 	}
 
 	// 清空回调和状态
-	ac.OnVolumeChange = nil
+	ac.OnLevel = nil
 	ac.OnAudioData = nil
 	ac.OnError = nil
 	ac.processor = nil
@@ -122,6 +163,179 @@ func (ac *AudioCapture) Close() error {
 	return nil
 }
 
+// ListDevices 列出所有采集设备
+func (ac *AudioCapture) ListDevices() ([]Device, error) {
+	if ac.context == nil {
+		return nil, fmt.Errorf("音频上下文已关闭")
+	}
+	infos, err := ac.context.Devices(malgo.Capture)
+	if err != nil {
+		return nil, fmt.Errorf("枚举采集设备失败: %w", err)
+	}
+	devices := make([]Device, 0, len(infos))
+	for _, info := range infos {
+		devices = append(devices, Device{Name: info.Name(), Default: info.IsDefault != 0})
+	}
+	return devices, nil
+}
+
+// SetDevice 选择采集设备，name 为空表示使用系统默认设备
+// 已初始化的设备会被释放，下次 Start 时使用新设备
+func (ac *AudioCapture) SetDevice(name string) error {
+	if name != "" {
+		if _, err := ac.findDevice(name); err != nil {
+			return err
+		}
+	}
+	ac.closeInput()
+	ac.deviceName = name
+	return nil
+}
+
+// SetSource 选择采集来源（SourceMic、SourceLoopback 或 SourceMix），为空时为麦克风
+// loopback 为采集系统声音的设备：Windows 上为播放设备名称，Linux 上为监听设备或输出设备名称，为空时使用默认输出设备
+// 已打开的设备会被释放，下次 Start 时使用新的来源
+func (ac *AudioCapture) SetSource(source, loopback string) error {
+	switch source {
+	case "", SourceMic, SourceLoopback, SourceMix:
+	default:
+		return fmt.Errorf("未知的采集来源: %s", source)
+	}
+	ac.closeInput()
+	ac.source, ac.loopbackName, ac.custom = source, loopback, false
+	return nil
+}
+
+// SetInput 使用自定义的音频来源代替设备，如回放录音的 FileSource，来源的格式需与采集配置相同
+// 之后 SetDevice、SetSource 不再生效，直到再次 SetSource
+func (ac *AudioCapture) SetInput(input Source) {
+	ac.closeInput()
+	ac.input, ac.custom = input, true
+}
+
+// ListLoopbackDevices 列出可以采集系统声音的设备：Windows 上为播放设备，Linux 上为监听设备
+func (ac *AudioCapture) ListLoopbackDevices() ([]Device, error) {
+	if ac.context == nil {
+		return nil, fmt.Errorf("音频上下文已关闭")
+	}
+	var infos []malgo.DeviceInfo
+	var err error
+	if runtime.GOOS == "windows" {
+		if infos, err = ac.context.Devices(malgo.Playback); err != nil {
+			return nil, fmt.Errorf("枚举播放设备失败: %w", err)
+		}
+	} else if infos, err = monitorDevices(ac.context); err != nil {
+		return nil, err
+	}
+	devices := make([]Device, 0, len(infos))
+	for _, info := range infos {
+		devices = append(devices, Device{Name: info.Name(), Default: info.IsDefault != 0})
+	}
+	return devices, nil
+}
+
+// closeInput 释放已打开的设备，SetInput 指定的来源保留
+func (ac *AudioCapture) closeInput() {
+	if ac.input != nil && !ac.custom {
+		ac.input.Close()
+		ac.input = nil
+	}
+}
+
+// findDevice 按名称查找采集设备ID
+func (ac *AudioCapture) findDevice(name string) (malgo.DeviceID, error) {
+	if ac.context == nil {
+		return malgo.DeviceID{}, fmt.Errorf("音频上下文已关闭")
+	}
+	infos, err := ac.context.Devices(malgo.Capture)
+	if err != nil {
+		return malgo.DeviceID{}, fmt.Errorf("枚举采集设备失败: %w", err)
+	}
+	for _, info := range infos {
+		if info.Name() == name {
+			return info.ID, nil
+		}
+	}
+	return malgo.DeviceID{}, fmt.Errorf("找不到采集设备: %s", name)
+}
+
+// Signal 返回本次 Start 以来采集到的原始信号（预处理之前）的统计
+func (ac *AudioCapture) Signal() audio.Stats {
+	return ac.processor.Signal()
+}
+
+// Problems 按电平表的静音门限诊断本次 Start 以来采集到的信号问题
+func (ac *AudioCapture) Problems() []audio.Problem {
+	return ac.processor.Signal().Problems(ac.config.Meter.SilenceThreshold)
+}
+
+// SetMeter 设置电平表参数和静音门限，只能在停止采集时调用
+func (ac *AudioCapture) SetMeter(cfg audio.MeterConfig) {
+	ac.processor.SetMeter(cfg)
+}
+
+// SetDSP 设置预处理，只能在停止采集时调用，下次 Start 时生效
+// 配置保存在 ac.config 中，SetChannels 重建处理器后仍然生效
+func (ac *AudioCapture) SetDSP(cfg dsp.Config) {
+	ac.config.DSP = cfg
+	ac.processor.SetDSP(cfg)
+}
+
+// SetChannels 设置采集的声道数，只能在停止采集时调用
+// 已初始化的设备会被释放，下次 Start 时按新的声道数打开设备，采集的 PCM 按声道交错排列
+func (ac *AudioCapture) SetChannels(n int) error {
+	if n < 1 {
+		return fmt.Errorf("无效的声道数: %d", n)
+	}
+	ac.closeInput()
+	ac.config.Channels = uint32(n)
+	ac.processor = NewAudioProcessor(ac.config)
+	return nil
+}
+
+// Channels 返回采集的声道数
+func (ac *AudioCapture) Channels() int {
+	return int(ac.config.Channels)
+}
+
+// DeviceName 返回当前使用的采集设备名称，采集系统声音时带有来源说明
+func (ac *AudioCapture) DeviceName() string {
+	switch {
+	case ac.custom:
+		return "custom"
+	case ac.source == SourceLoopback:
+		return ac.loopbackDeviceName()
+	case ac.source == SourceMix:
+		return ac.micName() + " + " + ac.loopbackDeviceName()
+	}
+	return ac.micName()
+}
+
+// loopbackDeviceName 采集系统声音的设备名称
+func (ac *AudioCapture) loopbackDeviceName() string {
+	if ac.loopbackName != "" {
+		return "loopback: " + ac.loopbackName
+	}
+	return "loopback"
+}
+
+// micName 麦克风的设备名称
+func (ac *AudioCapture) micName() string {
+	if ac.deviceName != "" {
+		return ac.deviceName
+	}
+	devices, err := ac.ListDevices()
+	if err != nil {
+		return "default"
+	}
+	for _, d := range devices {
+		if d.Default {
+			return d.Name
+		}
+	}
+	return "default"
+}
+
 func (ac *AudioCapture) GetPCMData() []byte {
 	return ac.processor.GetPCMData()
 }
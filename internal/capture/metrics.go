@@ -0,0 +1,58 @@
+package capture
+
+import (
+	"math"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/metrics"
+)
+
+// 采集指标，见 metrics.Default
+var (
+	capturedFrames = metrics.Default.NewCounter("voicewin_capture_frames_total",
+		"采集到的音频帧数（每帧包含所有声道的一个采样）")
+	captureCallbacks = metrics.Default.NewCounter("voicewin_capture_callbacks_total",
+		"采集设备的数据回调次数")
+	droppedFrames = metrics.Default.NewCounter("voicewin_capture_dropped_frames_total",
+		"按经过时间估算的丢失帧数：实际收到的帧数比采样率对应的帧数少出 droppedSlack 的部分")
+	callbackJitter = metrics.Default.NewHistogram("voicewin_capture_callback_jitter_seconds",
+		"相邻两次数据回调的间隔与回调中音频时长之差的绝对值",
+		[]float64{0.001, 0.0025, 0.005, 0.01, 0.02, 0.05, 0.1, 0.25})
+	ringOverwrites = metrics.Default.NewCounter("voicewin_capture_ringbuffer_overwrites_total",
+		"环形缓冲区已满、覆盖未读取数据的次数")
+	ringOverwriteBytes = metrics.Default.NewCounter("voicewin_capture_ringbuffer_overwrite_bytes_total",
+		"环形缓冲区被覆盖的未读取数据字节数")
+)
+
+// droppedSlack 设备缓冲造成的正常延迟，收到的帧数落后不超过这个时长时不算丢帧
+const droppedSlack = 100 * time.Millisecond
+
+// frameTiming 记录一次采集中回调的时间，在设备回调中使用，不需要加锁
+type frameTiming struct {
+	start    time.Time // 第一次回调的时间
+	last     time.Time // 上次回调的时间
+	received uint64    // 第一次回调之后收到的帧数
+	dropped  uint64    // 已计入指标的丢失帧数
+}
+
+// observe 记录一次回调
+func (t *frameTiming) observe(now time.Time, frames uint32, sampleRate uint32) {
+	capturedFrames.Add(float64(frames))
+	captureCallbacks.Inc()
+	dur := time.Duration(frames) * time.Second / time.Duration(sampleRate)
+	if t.start.IsZero() {
+		t.start, t.last = now, now
+		return
+	}
+	// 本次回调的数据在上次回调之后采集，间隔应等于本次的音频时长
+	callbackJitter.Observe(math.Abs((now.Sub(t.last) - dur).Seconds()))
+	t.last = now
+
+	expected := uint64(now.Sub(t.start).Seconds() * float64(sampleRate))
+	slack := uint64(droppedSlack.Seconds() * float64(sampleRate))
+	t.received += uint64(frames)
+	if missing := int64(expected) - int64(t.received+t.dropped+slack); missing > 0 {
+		t.dropped += uint64(missing)
+		droppedFrames.Add(float64(missing))
+	}
+}
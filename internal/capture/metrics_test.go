@@ -0,0 +1,37 @@
+package capture
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrameTiming_Dropped(t *testing.T) {
+	before := droppedFrames.Value()
+	var timing frameTiming
+	start := time.Now()
+	// 16kHz 每 20ms 回调一次，每次 320 帧
+	for i := 0; i < 10; i++ {
+		timing.observe(start.Add(time.Duration(i)*20*time.Millisecond), 320, 16000)
+	}
+	if d := droppedFrames.Value() - before; d != 0 {
+		t.Fatalf("回调均匀时不应丢帧，实际 %v", d)
+	}
+	// 回调间隔 300ms，比音频时长多出 280ms，其中超过 droppedSlack 的 180ms 计为丢帧
+	timing.observe(start.Add(480*time.Millisecond), 320, 16000)
+	if d := droppedFrames.Value() - before; d != 2880 {
+		t.Errorf("期望丢失 2880 帧，实际 %v", d)
+	}
+}
+
+func TestRingBuffer_Overwrites(t *testing.T) {
+	before, beforeBytes := ringOverwrites.Value(), ringOverwriteBytes.Value()
+	rb := NewRingBuffer(10)
+	rb.Write([]byte("12345678"))
+	rb.Write([]byte("abcd"))
+	if n := ringOverwrites.Value() - before; n != 1 {
+		t.Errorf("期望覆盖 1 次，实际 %v", n)
+	}
+	if n := ringOverwriteBytes.Value() - beforeBytes; n != 2 {
+		t.Errorf("期望覆盖 2 字节，实际 %v", n)
+	}
+}
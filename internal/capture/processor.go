@@ -1,16 +1,20 @@
 package capture
 
 import (
-	"math"
+	"sync"
 	"time"
+
+	"github.com/shellus/voiceWin/internal/audio"
+	"github.com/shellus/voiceWin/internal/dsp"
 )
 // Config 音频捕获配置
 type Config struct {
 	SampleRate       uint32
 	Channels         uint32
-	SilenceThreshold float64
-	BufferDuration   time.Duration // 音频缓冲区时长
-	CallbackInterval time.Duration // 数据回调间隔
+	BufferDuration   time.Duration     // 音频缓冲区时长
+	CallbackInterval time.Duration     // 数据回调间隔
+	DSP              dsp.Config        // 写入缓冲区前的预处理（高通、降噪、自动增益）
+	Meter            audio.MeterConfig // 电平表，静音门限为 dBFS
 }
 
 // DefaultConfig 返回默认配置
@@ -18,19 +22,22 @@ func DefaultConfig() *Config {
 	return &Config{
 		SampleRate:       44100,
 		Channels:         1,
-		SilenceThreshold: 500,
 		BufferDuration:   time.Second,           // 默认1秒缓冲
 		CallbackInterval: 20 * time.Millisecond, // 默认20ms回调一次
+		Meter:            audio.DefaultMeterConfig(),
 	}
 }
 
 // AudioProcessor 处理音频数据
 type AudioProcessor struct {
-	config           *Config
-	ringBuffer       *RingBuffer // 环形缓冲区
-	smoothedVolume   float64
-	silenceThreshold float64
-	bufferSize       int
+	config     *Config
+	ringBuffer *RingBuffer // 环形缓冲区
+	bufferSize int
+	dsp        []*dsp.Pipeline // 每个声道一个，没有开启预处理时为 nil
+	meter      *audio.Meter    // 原始信号的电平，只在采集回调中使用
+
+	signalMutex sync.Mutex
+	signal      *audio.Analyzer // 预处理之前的原始信号统计
 }
 
 // NewAudioProcessor 创建新的音频处理器
@@ -38,33 +45,86 @@ func NewAudioProcessor(config *Config) *AudioProcessor {
 	// 计算缓冲区大小：采样率 * 通道数 * 采样大小(字节) * 缓冲时长(秒)
 	bufferSize := int(config.SampleRate * config.Channels * 2 * uint32(config.BufferDuration.Seconds()))
 
-	return &AudioProcessor{
-		config:           config,
-		ringBuffer:       NewRingBuffer(bufferSize),
-		silenceThreshold: config.SilenceThreshold,
-		bufferSize:       bufferSize,
+	ap := &AudioProcessor{
+		config:     config,
+		ringBuffer: NewRingBuffer(bufferSize),
+		bufferSize: bufferSize,
+		meter:      audio.NewMeter(config.Meter, int(config.SampleRate), int(config.Channels)),
+		// 多声道时各声道的采样交错排列，按总采样率统计整体的信号
+		signal: audio.NewAnalyzer(int(config.SampleRate * config.Channels)),
 	}
+	ap.SetDSP(config.DSP)
+	return ap
 }
 
-// ProcessAudio 处理音频数据并返回音量
-func (ap *AudioProcessor) ProcessAudio(samples []byte, frameCount uint32) float64 {
-	// 写入环形缓冲区
-	ap.ringBuffer.Write(samples)
+// SetMeter 修改电平表参数，不能和 ProcessAudio 同时调用
+func (ap *AudioProcessor) SetMeter(cfg audio.MeterConfig) {
+	ap.config.Meter = cfg
+	ap.meter = audio.NewMeter(cfg, int(ap.config.SampleRate), int(ap.config.Channels))
+}
 
-	// 计算音量
-	var sum float64
-	for i := 0; i < len(samples); i += 2 {
-		if i+1 >= len(samples) {
-			break
+// SetDSP 修改预处理配置，不能和 ProcessAudio 同时调用
+func (ap *AudioProcessor) SetDSP(cfg dsp.Config) {
+	ap.config.DSP = cfg
+	ap.dsp = nil
+	if cfg.Enabled() {
+		for range ap.config.Channels {
+			ap.dsp = append(ap.dsp, dsp.New(cfg, int(ap.config.SampleRate)))
 		}
-		sample := int16(samples[i]) | (int16(samples[i+1]) << 8)
-		sum += math.Abs(float64(sample))
 	}
+}
 
-	currentVolume := sum / float64(frameCount)
-	ap.smoothedVolume = ap.smoothedVolume*0.7 + currentVolume*0.3
+// Reset 开始新的一段录音前清空预处理中残留的音频、电平和信号统计
+func (ap *AudioProcessor) Reset() {
+	for _, p := range ap.dsp {
+		p.Reset()
+	}
+	ap.meter.Reset()
+	ap.signalMutex.Lock()
+	ap.signal.Reset()
+	ap.signalMutex.Unlock()
+}
+
+// Signal 返回上次 Reset 以来原始信号的统计
+func (ap *AudioProcessor) Signal() audio.Stats {
+	ap.signalMutex.Lock()
+	defer ap.signalMutex.Unlock()
+	return ap.signal.Stats()
+}
 
-	return ap.smoothedVolume
+// ProcessAudio 处理音频数据，每凑满一个电平表输出间隔调用一次 onLevel
+// 电平按预处理之前的原始信号计算，反映麦克风实际的输入音量，不受自动增益影响
+func (ap *AudioProcessor) ProcessAudio(samples []byte, onLevel func(levels []audio.Level)) {
+	ap.signalMutex.Lock()
+	ap.signal.Add(samples)
+	ap.signalMutex.Unlock()
+	ap.meter.Add(samples, onLevel)
+	if ap.dsp != nil {
+		samples = ap.process(samples)
+	}
+	// 写入环形缓冲区
+	ap.ringBuffer.Write(samples)
+}
+
+// process 对每个声道分别预处理，各声道的增益和噪声估计互不影响
+func (ap *AudioProcessor) process(samples []byte) []byte {
+	if len(ap.dsp) == 1 {
+		return ap.dsp[0].Process(samples)
+	}
+	n := len(ap.dsp)
+	frames := len(samples) / 2 / n
+	out := make([]byte, frames*n*2)
+	mono := make([]byte, frames*2)
+	for c, p := range ap.dsp {
+		for i := 0; i < frames; i++ {
+			copy(mono[i*2:i*2+2], samples[(i*n+c)*2:])
+		}
+		processed := p.Process(mono)
+		for i := 0; i < frames; i++ {
+			copy(out[(i*n+c)*2:(i*n+c)*2+2], processed[i*2:])
+		}
+	}
+	return out
 }
 
 // GetPCMData 获取PCM数据
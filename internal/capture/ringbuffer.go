@@ -32,6 +32,8 @@ func (rb *RingBuffer) Write(data []byte) int {
 	if free < len(data) {
 		// 需要读取的数据量
 		needToRead := len(data) - free
+		ringOverwrites.Inc()
+		ringOverwriteBytes.Add(float64(needToRead))
 		// 读取数据以腾出空间
 		rb.Read(needToRead)
 	}
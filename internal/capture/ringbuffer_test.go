@@ -29,8 +29,8 @@ func TestNewAudioCapture(t *testing.T) {
 	}
 
 	// 测试回调函数初始化为nil
-	if ac.OnVolumeChange != nil {
-		t.Error("OnVolumeChange应初始化为nil")
+	if ac.OnLevel != nil {
+		t.Error("OnLevel应初始化为nil")
 	}
 	if ac.OnAudioData != nil {
 		t.Error("OnAudioData应初始化为nil")
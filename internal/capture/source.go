@@ -0,0 +1,341 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gen2brain/malgo"
+	"github.com/shellus/voiceWin/internal/audio"
+)
+
+// 采集来源：麦克风、系统播放的声音（用于给视频会议等加实时字幕），或者两者混合。
+// 系统声音在 Windows 上使用 WASAPI 的 loopback 模式采集播放设备，
+// 在 Linux 上使用 PulseAudio/PipeWire 为每个输出设备提供的 Monitor 采集设备。
+// 混合时以麦克风的回调为时钟，系统声音先缓存，每次麦克风回调时取出同样长度的数据相加。
+
+const (
+	SourceMic      = "mic"      // 麦克风（默认）
+	SourceLoopback = "loopback" // 系统播放的声音
+	SourceMix      = "mix"      // 麦克风和系统播放的声音混合
+)
+
+// monitorPrefix PulseAudio/PipeWire 监听设备的名称前缀
+const monitorPrefix = "Monitor of "
+
+// mixMaxLag 混合时系统声音最多缓存的时长，超过时丢弃最早的数据，避免两个设备时钟不同步时延迟越来越大
+const mixMaxLag = 200 * time.Millisecond
+
+// Source 音频来源，按采集配置的采样率和声道数输出交错排列的 16位 PCM
+// Stop 之后可以再次 Start，Close 之后不能再使用
+type Source interface {
+	Start(onData func(pcm []byte)) error
+	Stop() error
+	Close()
+}
+
+// deviceSource malgo 采集设备，首次 Start 时初始化
+type deviceSource struct {
+	context *malgo.AllocatedContext
+	config  malgo.DeviceConfig
+	id      *malgo.DeviceID // 为 nil 时使用系统默认设备
+	device  *malgo.Device
+	onData  func(pcm []byte)
+}
+
+// newDeviceSource 创建采集设备，kind 为 malgo.Capture 或 malgo.Loopback（id 为播放设备）
+func newDeviceSource(ctx *malgo.AllocatedContext, kind malgo.DeviceType, id *malgo.DeviceID, cfg *Config) *deviceSource {
+	deviceConfig := malgo.DefaultDeviceConfig(kind)
+	deviceConfig.Capture.Format = malgo.FormatS16
+	deviceConfig.Capture.Channels = cfg.Channels
+	deviceConfig.SampleRate = cfg.SampleRate
+	deviceConfig.Alsa.NoMMap = 1
+	return &deviceSource{context: ctx, config: deviceConfig, id: id}
+}
+
+func (s *deviceSource) Start(onData func(pcm []byte)) error {
+	// 设备停止时没有回调，可以直接替换
+	s.onData = onData
+	if s.device == nil {
+		if s.id != nil {
+			s.config.Capture.DeviceID = s.id.Pointer()
+		}
+		device, err := malgo.InitDevice(s.context.Context, s.config, malgo.DeviceCallbacks{
+			Data: func(_, pSample []byte, _ uint32) { s.onData(pSample) },
+		})
+		if err != nil {
+			return fmt.Errorf("初始化设备失败: %w", err)
+		}
+		s.device = device
+	}
+	if err := s.device.Start(); err != nil {
+		return fmt.Errorf("启动设备失败: %w", err)
+	}
+	return nil
+}
+
+func (s *deviceSource) Stop() error {
+	if s.device == nil {
+		return nil
+	}
+	if err := s.device.Stop(); err != nil {
+		return fmt.Errorf("停止设备失败: %w", err)
+	}
+	return nil
+}
+
+func (s *deviceSource) Close() {
+	if s.device != nil {
+		s.device.Uninit()
+		s.device = nil
+	}
+}
+
+// mixSource 以 primary 的回调为时钟，把 secondary 的音频混入 primary
+type mixSource struct {
+	primary, secondary Source
+	frameSize          int // 一帧（所有声道）的字节数
+	maxPending         int
+
+	mutex   sync.Mutex
+	pending []byte // secondary 中还没有混入的音频
+}
+
+func newMixSource(primary, secondary Source, cfg *Config) *mixSource {
+	frameSize := int(cfg.Channels) * 2
+	return &mixSource{
+		primary:    primary,
+		secondary:  secondary,
+		frameSize:  frameSize,
+		maxPending: int(mixMaxLag.Seconds()*float64(cfg.SampleRate)) * frameSize,
+	}
+}
+
+func (s *mixSource) Start(onData func(pcm []byte)) error {
+	s.mutex.Lock()
+	s.pending = nil
+	s.mutex.Unlock()
+	if err := s.secondary.Start(s.add); err != nil {
+		return err
+	}
+	if err := s.primary.Start(func(pcm []byte) { onData(s.mix(pcm)) }); err != nil {
+		s.secondary.Stop()
+		return err
+	}
+	return nil
+}
+
+// add 缓存 secondary 的音频，超过 maxPending 时按整帧丢弃最早的数据
+func (s *mixSource) add(pcm []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending = append(s.pending, pcm...)
+	if over := len(s.pending) - s.maxPending; over > 0 {
+		over = (over + s.frameSize - 1) / s.frameSize * s.frameSize
+		s.pending = append(s.pending[:0], s.pending[over:]...)
+	}
+}
+
+// mix 取出与 pcm 等长的 secondary 音频相加，secondary 不够时按静音处理
+func (s *mixSource) mix(pcm []byte) []byte {
+	out := make([]byte, len(pcm))
+	copy(out, pcm)
+	s.mutex.Lock()
+	n := min(len(s.pending), len(out)) / 2 * 2
+	for i := 0; i < n; i += 2 {
+		v := int32(int16(binary.LittleEndian.Uint16(out[i:]))) + int32(int16(binary.LittleEndian.Uint16(s.pending[i:])))
+		binary.LittleEndian.PutUint16(out[i:], uint16(int16(max(-32768, min(32767, v)))))
+	}
+	s.pending = s.pending[n:]
+	s.mutex.Unlock()
+	return out
+}
+
+func (s *mixSource) Stop() error {
+	err := s.primary.Stop()
+	if err2 := s.secondary.Stop(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+func (s *mixSource) Close() {
+	s.primary.Close()
+	s.secondary.Close()
+}
+
+// FileSource 按实时速度输出一段 PCM 的音频来源，放完之后输出静音，
+// 像一个一直在录音的设备，用于没有声卡的环境中测试和回放录音
+type FileSource struct {
+	pcm      []byte
+	chunk    int // 每次回调的字节数
+	interval time.Duration
+
+	mutex sync.Mutex
+	pos   int
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// fileSourceInterval FileSource 的回调间隔
+const fileSourceInterval = 10 * time.Millisecond
+
+// NewFileSource 使用交错排列的 16位 PCM 创建音频来源，格式需与采集配置相同
+func NewFileSource(pcm []byte, sampleRate, channels int) *FileSource {
+	return &FileSource{
+		pcm:      pcm,
+		chunk:    sampleRate * int(fileSourceInterval) / int(time.Second) * channels * 2,
+		interval: fileSourceInterval,
+	}
+}
+
+// OpenFileSource 读取 WAV 或 PCM 文件创建音频来源
+// WAV 的采样率或声道数与采集配置不同时，单声道采集会转换格式，多声道采集需要文件的格式相同
+func OpenFileSource(path string, sampleRate, channels int) (*FileSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".pcm") {
+		return NewFileSource(data, sampleRate, channels), nil
+	}
+	pcm, rate, ch, err := audio.DecodeWAV(data)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s: %w", path, err)
+	}
+	if rate != sampleRate || ch != channels {
+		if channels != 1 {
+			return nil, fmt.Errorf("%s 为 %d Hz %d 声道，与采集配置的 %d Hz %d 声道不同", path, rate, ch, sampleRate, channels)
+		}
+		pcm = audio.Convert(pcm, rate, ch, sampleRate)
+	}
+	return NewFileSource(pcm, sampleRate, channels), nil
+}
+
+func (s *FileSource) Start(onData func(pcm []byte)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stop != nil {
+		return fmt.Errorf("音频来源已经开始")
+	}
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	go s.run(onData, s.stop, s.done)
+	return nil
+}
+
+func (s *FileSource) run(onData func(pcm []byte), stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	buf := make([]byte, s.chunk)
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		clear(buf)
+		s.mutex.Lock()
+		s.pos += copy(buf, s.pcm[min(s.pos, len(s.pcm)):])
+		s.mutex.Unlock()
+		onData(buf)
+	}
+}
+
+// Stop 停止输出，再次 Start 时从停止的位置继续
+func (s *FileSource) Stop() error {
+	s.mutex.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mutex.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return nil
+}
+
+func (s *FileSource) Close() {
+	s.Stop()
+}
+
+// loopbackDevice 返回采集系统声音的设备类型和设备ID，name 为空时使用默认输出设备
+// Windows 上 name 为播放设备名称，Linux 上为监听设备或它所监听的输出设备的名称
+func loopbackDevice(ctx *malgo.AllocatedContext, name string) (malgo.DeviceType, *malgo.DeviceID, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if name == "" {
+			return malgo.Loopback, nil, nil
+		}
+		infos, err := ctx.Devices(malgo.Playback)
+		if err != nil {
+			return 0, nil, fmt.Errorf("枚举播放设备失败: %w", err)
+		}
+		for _, info := range infos {
+			if info.Name() == name {
+				id := info.ID
+				return malgo.Loopback, &id, nil
+			}
+		}
+		return 0, nil, fmt.Errorf("找不到播放设备: %s", name)
+	case "linux":
+		monitors, err := monitorDevices(ctx)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(monitors) == 0 {
+			return 0, nil, fmt.Errorf("找不到系统声音的监听设备，需要 PulseAudio 或 PipeWire（Monitor of 开头的采集设备）")
+		}
+		want := name
+		if want == "" {
+			want = defaultPlayback(ctx)
+		}
+		for _, m := range monitors {
+			if m.Name() == want || m.Name() == monitorPrefix+want {
+				id := m.ID
+				return malgo.Capture, &id, nil
+			}
+		}
+		if name != "" {
+			return 0, nil, fmt.Errorf("找不到监听设备: %s", name)
+		}
+		// 默认输出设备没有对应的监听设备时使用第一个
+		id := monitors[0].ID
+		return malgo.Capture, &id, nil
+	}
+	return 0, nil, fmt.Errorf("%s 不支持采集系统声音，可以安装虚拟声卡（如 BlackHole）后作为麦克风选择", runtime.GOOS)
+}
+
+// monitorDevices 列出 PulseAudio/PipeWire 的监听设备
+func monitorDevices(ctx *malgo.AllocatedContext) ([]malgo.DeviceInfo, error) {
+	infos, err := ctx.Devices(malgo.Capture)
+	if err != nil {
+		return nil, fmt.Errorf("枚举采集设备失败: %w", err)
+	}
+	var monitors []malgo.DeviceInfo
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), monitorPrefix) {
+			monitors = append(monitors, info)
+		}
+	}
+	return monitors, nil
+}
+
+// defaultPlayback 默认输出设备的名称，找不到时为空
+func defaultPlayback(ctx *malgo.AllocatedContext) string {
+	infos, err := ctx.Devices(malgo.Playback)
+	if err != nil {
+		return ""
+	}
+	for _, info := range infos {
+		if info.IsDefault != 0 {
+			return info.Name()
+		}
+	}
+	return ""
+}
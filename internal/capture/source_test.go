@@ -0,0 +1,167 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/malgo"
+	"github.com/shellus/voiceWin/internal/dsp"
+)
+
+// manualSource 由测试调用 push 产生回调的音频来源
+type manualSource struct {
+	onData func(pcm []byte)
+}
+
+func (s *manualSource) Start(onData func(pcm []byte)) error { s.onData = onData; return nil }
+func (s *manualSource) Stop() error                         { return nil }
+func (s *manualSource) Close()                              {}
+func (s *manualSource) push(samples ...int16) {
+	pcm := make([]byte, len(samples)*2)
+	for i, v := range samples {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(v))
+	}
+	s.onData(pcm)
+}
+
+func samplesOf(pcm []byte) []int16 {
+	out := make([]int16, len(pcm)/2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	return out
+}
+
+func TestMixSource(t *testing.T) {
+	mic, loopback := &manualSource{}, &manualSource{}
+	cfg := DefaultConfig()
+	cfg.SampleRate = 100 // mixMaxLag 对应 20 个采样
+	m := newMixSource(mic, loopback, cfg)
+	var got [][]int16
+	m.Start(func(pcm []byte) { got = append(got, samplesOf(pcm)) })
+
+	// 系统声音不够时按静音处理，相加超出范围时截断
+	loopback.push(100, 30000)
+	mic.push(1, 2, 3)
+	mic.push(4)
+	loopback.push(-30000)
+	mic.push(-30000)
+	want := [][]int16{{101, 30002, 3}, {4}, {-32768}}
+	if len(got) != len(want) {
+		t.Fatalf("回调次数 %d", len(got))
+	}
+	for i := range want {
+		if !slices.Equal(got[i], want[i]) {
+			t.Errorf("第 %d 次回调 %v，期望 %v", i, got[i], want[i])
+		}
+	}
+
+	// 系统声音积压超过 mixMaxLag 时丢弃最早的部分
+	backlog := make([]int16, 30)
+	for i := range backlog {
+		backlog[i] = int16(i)
+	}
+	loopback.push(backlog...)
+	mic.push(0)
+	if v := got[len(got)-1][0]; v != 10 {
+		t.Errorf("应丢弃最早的 10 个采样，实际混入 %d", v)
+	}
+}
+
+func TestAudioCapture_FileSource(t *testing.T) {
+	ac := NewAudioCapture()
+	if ac == nil {
+		t.Skip("无法初始化音频上下文")
+	}
+	defer ac.Close()
+
+	pcm := make([]byte, 16000/10*2) // 100ms
+	for i := range pcm {
+		pcm[i] = byte(i)
+	}
+	ac.SetInput(NewFileSource(pcm, 16000, 1))
+	if err := ac.SetSource("speaker", ""); err == nil {
+		t.Error("未知的采集来源应返回错误")
+	}
+
+	var mutex sync.Mutex
+	var got []byte
+	ac.OnAudioData = func() error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		got = append(got, ac.GetPCMData()...)
+		return nil
+	}
+	if err := ac.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mutex.Lock()
+		n := len(got)
+		mutex.Unlock()
+		if n >= len(pcm) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("只收到 %d 字节", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ac.Stop()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !bytes.Equal(got[:len(pcm)], pcm) {
+		t.Error("采集的数据与文件不一致")
+	}
+	if ac.DeviceName() != "custom" {
+		t.Errorf("设备名称 %q", ac.DeviceName())
+	}
+}
+
+func TestAudioCapture_NullBackend(t *testing.T) {
+	ac := NewAudioCapture(malgo.BackendNull)
+	if ac == nil {
+		t.Skip("音频库没有编译 null 后端")
+	}
+	defer ac.Close()
+
+	var mutex sync.Mutex
+	var n int
+	ac.OnAudioData = func() error {
+		mutex.Lock()
+		n += len(ac.GetPCMData())
+		mutex.Unlock()
+		return nil
+	}
+	if err := ac.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	ac.Stop()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if n == 0 {
+		t.Error("null 后端应产生静音数据")
+	}
+}
+
+func TestAudioCapture_SetChannelsKeepsDSP(t *testing.T) {
+	ac := NewAudioCapture()
+	if ac == nil {
+		t.Skip("无法初始化音频上下文")
+	}
+	defer ac.Close()
+
+	ac.SetDSP(dsp.Config{HighPass: true, HighPassCutoff: 80})
+	if err := ac.SetChannels(2); err != nil {
+		t.Fatal(err)
+	}
+	if len(ac.processor.dsp) != 2 {
+		t.Errorf("重建处理器后应保留预处理配置，得到 %d 条流水线", len(ac.processor.dsp))
+	}
+}
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeKeyboard 记录收到的键盘操作
+type fakeKeyboard struct {
+	ops []string
+}
+
+func (k *fakeKeyboard) TypeText(text string) error {
+	k.ops = append(k.ops, "type:"+text)
+	return nil
+}
+
+func (k *fakeKeyboard) PressKey(key string) error {
+	k.ops = append(k.ops, "key:"+key)
+	return nil
+}
+
+func (k *fakeKeyboard) PressKeyWithModifiers(key string, modifiers ...string) error {
+	k.ops = append(k.ops, "key:"+strings.Join(append(modifiers, key), "+"))
+	return nil
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`
+# 注释
+换行|回车 = key:Enter
+句号 = text:。
+撤销 = key:ctrl+z
+删除上一句 = delete_last
+`))
+	if err != nil {
+		t.Fatalf("解析规则失败: %v", err)
+	}
+	if len(rules) != 4 {
+		t.Fatalf("期望4条规则，实际为%d", len(rules))
+	}
+	if !reflect.DeepEqual(rules[0].Phrases, []string{"换行", "回车"}) {
+		t.Errorf("口令解析错误: %v", rules[0].Phrases)
+	}
+	if rules[2].Action.Key != "z" || !reflect.DeepEqual(rules[2].Action.Modifiers, []string{"ctrl"}) {
+		t.Errorf("修饰键解析错误: %+v", rules[2].Action)
+	}
+	if rules[3].Action.Type != ActionDeleteLast {
+		t.Errorf("期望 delete_last 动作，实际为%v", rules[3].Action)
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	cases := []string{
+		"换行",
+		" = key:Enter",
+		"换行 = jump",
+		"换行 = key:ctrl+",
+		"句号 = text:",
+		"删除上一句 = delete_last:xyz",
+	}
+	for _, c := range cases {
+		if _, err := ParseRules(strings.NewReader(c)); err == nil {
+			t.Errorf("规则 %q 应解析失败", c)
+		}
+	}
+}
+
+func TestInterpret(t *testing.T) {
+	it := NewInterpreter(DefaultRules())
+
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"你好世界。", []string{`输入文本 "你好世界。"`}},
+		{"你好，换行，世界。", []string{`输入文本 "你好"`, "按键 Enter（口令: 换行）", `输入文本 "世界。"`}},
+		{"撤销。", []string{"按键 ctrl+z（口令: 撤销）"}},
+		{"今天天气不错，句号", []string{`输入文本 "今天天气不错"`, `输入文本 "。"（口令: 句号）`}},
+		{"句号", []string{`输入文本 "。"（口令: 句号）`}},
+		// 相邻的口令互为边界
+		{"好的，句号换行", []string{`输入文本 "好的"`, `输入文本 "。"（口令: 句号）`, "按键 Enter（口令: 换行）"}},
+		// 词语中间的口令是普通文本
+		{"请问号码是多少", []string{`输入文本 "请问号码是多少"`}},
+		{"他在句号前停顿了一下。", []string{`输入文本 "他在句号前停顿了一下。"`}},
+		{"换行符很重要，回车站到了", []string{`输入文本 "换行符很重要，回车站到了"`}},
+		{"今天天气不错句号", []string{`输入文本 "今天天气不错句号"`}},
+		{"句号换行符", []string{`输入文本 "句号换行符"`}},
+		{"删除上一句。", []string{"删除上一次输入（口令: 删除上一句）"}},
+		// 只去掉紧挨着口令的标点，前面文本的句末标点和后面文本的引号保留
+		{"真的吗？换行。“好的”", []string{`输入文本 "真的吗？"`, "按键 Enter（口令: 换行）", `输入文本 "“好的”"`}},
+		{"你好 ， 换行 ！！", []string{`输入文本 "你好"`, "按键 Enter（口令: 换行）", `输入文本 "！"`}},
+		{"", nil},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, a := range it.Interpret(c.text) {
+			got = append(got, a.String())
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Interpret(%q)\n期望: %v\n实际: %v", c.text, c.want, got)
+		}
+	}
+}
+
+func TestInterpret_LongestMatch(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("删除 = key:Delete\n删除上一句 = delete_last"))
+	if err != nil {
+		t.Fatalf("解析规则失败: %v", err)
+	}
+	actions := NewInterpreter(rules).Interpret("删除上一句")
+	if len(actions) != 1 || actions[0].Type != ActionDeleteLast {
+		t.Errorf("应匹配最长口令，实际为%v", actions)
+	}
+}
+
+func TestExecutor(t *testing.T) {
+	it := NewInterpreter(DefaultRules())
+	kb := &fakeKeyboard{}
+	ex := NewExecutor(kb)
+
+	if err := ex.Execute(it.Interpret("第一句")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ex.Execute(it.Interpret("你好，句号")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ex.Execute(it.Interpret("删除上一句")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ex.Execute(it.Interpret("撤销")); err != nil {
+		t.Fatal(err)
+	}
+	// 撤销已经移除了 "第一句"，没有可以删除的文本
+	if err := ex.Execute(it.Interpret("删除上一句")); err != nil {
+		t.Fatal(err)
+	}
+	// 按键之后不再删除按键之前输入的文本
+	if err := ex.Execute(it.Interpret("第二句，换行")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ex.Execute(it.Interpret("删除上一句")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"type:第一句",
+		"type:你好", "type:。",
+		"key:Backspace", "key:Backspace", "key:Backspace",
+		"key:ctrl+z",
+		"type:第二句", "key:Enter",
+	}
+	if !reflect.DeepEqual(kb.ops, want) {
+		t.Errorf("键盘操作不符合预期\n期望: %v\n实际: %v", want, kb.ops)
+	}
+}
@@ -0,0 +1,259 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ActionType 动作类型
+type ActionType int
+
+const (
+	ActionText       ActionType = iota // 输入文本
+	ActionKey                          // 按键（可带修饰键）
+	ActionDeleteLast                   // 删除上一次输入的文本
+)
+
+// Action 解释后得到的一个动作
+type Action struct {
+	Type      ActionType
+	Text      string   // ActionText 要输入的文本
+	Key       string   // ActionKey 的按键
+	Modifiers []string // ActionKey 的修饰键
+	Phrase    string   // 触发该动作的口令，普通文本为空
+}
+
+// String 返回动作的可读描述，用于 dry-run 输出
+func (a Action) String() string {
+	var s string
+	switch a.Type {
+	case ActionText:
+		s = fmt.Sprintf("输入文本 %q", a.Text)
+	case ActionKey:
+		if len(a.Modifiers) > 0 {
+			s = fmt.Sprintf("按键 %s+%s", strings.Join(a.Modifiers, "+"), a.Key)
+		} else {
+			s = fmt.Sprintf("按键 %s", a.Key)
+		}
+	case ActionDeleteLast:
+		s = "删除上一次输入"
+	default:
+		s = "未知动作"
+	}
+	if a.Phrase != "" {
+		s += fmt.Sprintf("（口令: %s）", a.Phrase)
+	}
+	return s
+}
+
+// Interpreter 语音命令解释器，扫描识别结果中的口令并转换为动作
+type Interpreter struct {
+	phrases []phraseRule // 按口令长度降序排列，保证最长匹配
+}
+
+type phraseRule struct {
+	phrase []rune
+	action Action
+}
+
+// NewInterpreter 创建新的语音命令解释器
+func NewInterpreter(rules []Rule) *Interpreter {
+	it := &Interpreter{}
+	for _, rule := range rules {
+		for _, p := range rule.Phrases {
+			action := rule.Action
+			action.Phrase = p
+			it.phrases = append(it.phrases, phraseRule{phrase: []rune(p), action: action})
+		}
+	}
+	sort.SliceStable(it.phrases, func(i, j int) bool {
+		return len(it.phrases[i].phrase) > len(it.phrases[j].phrase)
+	})
+	return it
+}
+
+// Interpret 将一次最终识别结果转换为动作列表
+// 识别服务会把口令当作一个词加上标点，如 "你好，换行，世界。"，只去掉紧挨着口令的标点：
+// 口令前的一个停顿标点（，、；：），以及口令后的一个标点；句末标点属于前面的文本，原样保留。
+// 标点两侧的空白一起去掉，其余文本原样保留。
+// 口令只在两侧都是句子边界（开头结尾、标点、空白或相邻的口令）时生效，"请问号码" 中的 "问号" 是普通文本
+func (it *Interpreter) Interpret(text string) []Action {
+	var actions []Action
+	runes := []rune(text)
+	var pending []rune // 尚未输出的普通文本
+
+	flush := func() {
+		if len(pending) > 0 {
+			actions = append(actions, Action{Type: ActionText, Text: string(pending)})
+			pending = nil
+		}
+	}
+
+	after := -1 // 上一个口令结束的位置，紧接着的口令也算在边界上
+	for i := 0; i < len(runes); {
+		var pr phraseRule
+		ok := false
+		if i == 0 || i == after || isBoundary(runes[i-1]) {
+			pr, ok = it.match(runes, i)
+		}
+		if !ok {
+			pending = append(pending, runes[i])
+			i++
+			continue
+		}
+
+		// 去掉口令前的停顿标点，例如 "你好，换行" 中的 "，"，"真的吗？换行" 中的 "？" 保留
+		pending = trimSpaceRight(pending)
+		if n := len(pending); n > 0 && isPause(pending[n-1]) {
+			pending = trimSpaceRight(pending[:n-1])
+		}
+		flush()
+		actions = append(actions, pr.action)
+
+		// 跳过口令以及口令后的一个标点
+		i = skipSpace(runes, i+len(pr.phrase))
+		after = i
+		if i < len(runes) && unicode.IsPunct(runes[i]) && !isOpening(runes[i]) {
+			i = skipSpace(runes, i+1)
+		}
+	}
+	flush()
+	return actions
+}
+
+func trimSpaceRight(runes []rune) []rune {
+	for len(runes) > 0 && unicode.IsSpace(runes[len(runes)-1]) {
+		runes = runes[:len(runes)-1]
+	}
+	return runes
+}
+
+func skipSpace(runes []rune, i int) int {
+	for i < len(runes) && unicode.IsSpace(runes[i]) {
+		i++
+	}
+	return i
+}
+
+// match 返回在 runes[i:] 开头匹配到的最长口令，口令之后需要是句子边界或者另一个口令
+func (it *Interpreter) match(runes []rune, i int) (phraseRule, bool) {
+	for _, pr := range it.phrases {
+		end := i + len(pr.phrase)
+		if end > len(runes) || string(runes[i:end]) != string(pr.phrase) {
+			continue
+		}
+		if end == len(runes) || isBoundary(runes[end]) {
+			return pr, true
+		}
+		if _, ok := it.match(runes, end); ok {
+			return pr, true
+		}
+	}
+	return phraseRule{}, false
+}
+
+// isBoundary 是否为句子边界：标点或空白
+func isBoundary(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSpace(r)
+}
+
+// isPause 是否为句中的停顿标点
+func isPause(r rune) bool {
+	return strings.ContainsRune("，、；：,;:", r)
+}
+
+// isOpening 是否为引号、括号等开始的标点，属于后面的文本
+func isOpening(r rune) bool {
+	return unicode.Is(unicode.Ps, r) || unicode.Is(unicode.Pi, r)
+}
+
+// Keyboard 执行动作所需的键盘能力，hotkey.KeyboardInput 实现了该接口
+type Keyboard interface {
+	TypeText(text string) error
+	PressKey(key string) error
+	PressKeyWithModifiers(key string, modifiers ...string) error
+}
+
+// Executor 在键盘上执行动作，并记录已输入的文本以支持删除上一句
+// 撤销按键（ctrl+z 或 cmd+z）由编辑器撤销上一次输入，记录中的上一句也一起移除，
+// 之后的删除上一句不会再删除已经撤销的文本；其他按键（如回车）之后光标和内容可能已经改变，
+// 清空记录，删除上一句不会越过按键删除之前的文本
+type Executor struct {
+	kb       Keyboard
+	inserted []string // 已输入文本的栈
+}
+
+// NewExecutor 创建新的动作执行器
+func NewExecutor(kb Keyboard) *Executor {
+	return &Executor{kb: kb}
+}
+
+// Execute 依次执行动作列表
+// 同一批中连续输入的文本（如 "你好" 和句号口令产生的 "。"）记为一句，删除上一句时一起删除
+func (e *Executor) Execute(actions []Action) error {
+	prevText := false
+	for _, a := range actions {
+		if err := e.execute(a, prevText); err != nil {
+			return fmt.Errorf("执行动作失败 [%s]: %w", a, err)
+		}
+		prevText = a.Type == ActionText
+	}
+	return nil
+}
+
+func (e *Executor) execute(a Action, merge bool) error {
+	switch a.Type {
+	case ActionText:
+		if err := e.kb.TypeText(a.Text); err != nil {
+			return err
+		}
+		if merge && len(e.inserted) > 0 {
+			e.inserted[len(e.inserted)-1] += a.Text
+		} else {
+			e.inserted = append(e.inserted, a.Text)
+		}
+	case ActionKey:
+		var err error
+		if len(a.Modifiers) > 0 {
+			err = e.kb.PressKeyWithModifiers(a.Key, a.Modifiers...)
+		} else {
+			err = e.kb.PressKey(a.Key)
+		}
+		if err != nil {
+			return err
+		}
+		if !isUndo(a) {
+			e.inserted = nil
+		} else if len(e.inserted) > 0 {
+			e.inserted = e.inserted[:len(e.inserted)-1]
+		}
+	case ActionDeleteLast:
+		if len(e.inserted) == 0 {
+			return nil
+		}
+		last := e.inserted[len(e.inserted)-1]
+		e.inserted = e.inserted[:len(e.inserted)-1]
+		for range []rune(last) {
+			if err := e.kb.PressKey("Backspace"); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("未知动作类型: %d", a.Type)
+	}
+	return nil
+}
+
+// isUndo 是否为撤销按键：ctrl+z，macOS 上为 cmd+z
+func isUndo(a Action) bool {
+	if !strings.EqualFold(a.Key, "z") || len(a.Modifiers) != 1 {
+		return false
+	}
+	switch strings.ToLower(a.Modifiers[0]) {
+	case "ctrl", "control", "cmd", "command":
+		return true
+	}
+	return false
+}
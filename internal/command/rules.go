@@ -0,0 +1,128 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 规则文件格式（UTF-8 文本，每行一条规则）：
+//
+//	# 以 # 开头的行是注释，空行会被忽略
+//	口令[|口令...] = 动作
+//
+// 动作支持：
+//
+//	text:<文本>      输入指定文本，例如 text:。
+//	key:<按键>       按下按键，修饰键用 + 连接，例如 key:Enter、key:ctrl+z
+//	delete_last      删除上一次输入的文本（通过退格键）
+//
+// 示例：
+//
+//	换行|回车 = key:Enter
+//	句号 = text:。
+//	删除上一句 = delete_last
+//	撤销 = key:ctrl+z
+
+// Rule 一条语音命令规则
+type Rule struct {
+	Phrases []string // 触发口令，任意一个匹配即可
+	Action  Action   // 匹配后执行的动作
+}
+
+// DefaultRules 返回内置的默认规则
+func DefaultRules() []Rule {
+	rules, _ := ParseRules(strings.NewReader(`
+换行|回车 = key:Enter
+句号 = text:。
+逗号 = text:，
+问号 = text:？
+感叹号 = text:！
+删除上一句 = delete_last
+撤销 = key:ctrl+z
+`))
+	return rules
+}
+
+// LoadRules 从规则文件加载规则
+func LoadRules(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开规则文件失败: %w", err)
+	}
+	defer f.Close()
+	return ParseRules(f)
+}
+
+// ParseRules 解析规则文本
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		left, right, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("第%d行: 缺少 '='", lineNo)
+		}
+
+		var phrases []string
+		for _, p := range strings.Split(left, "|") {
+			if p = strings.TrimSpace(p); p != "" {
+				phrases = append(phrases, p)
+			}
+		}
+		if len(phrases) == 0 {
+			return nil, fmt.Errorf("第%d行: 口令为空", lineNo)
+		}
+
+		action, err := parseAction(strings.TrimSpace(right))
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %v", lineNo, err)
+		}
+		rules = append(rules, Rule{Phrases: phrases, Action: action})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// parseAction 解析规则右侧的动作
+func parseAction(s string) (Action, error) {
+	name, arg, hasArg := strings.Cut(s, ":")
+	switch strings.TrimSpace(name) {
+	case "text":
+		if arg == "" {
+			return Action{}, fmt.Errorf("text 动作缺少文本")
+		}
+		return Action{Type: ActionText, Text: arg}, nil
+	case "key":
+		parts := strings.Split(arg, "+")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+			if parts[i] == "" {
+				return Action{}, fmt.Errorf("无效的按键: %q", arg)
+			}
+		}
+		action := Action{Type: ActionKey, Key: parts[len(parts)-1]}
+		if len(parts) > 1 {
+			action.Modifiers = parts[:len(parts)-1]
+		}
+		return action, nil
+	case "delete_last":
+		if hasArg {
+			return Action{}, fmt.Errorf("delete_last 动作不需要参数: %q", s)
+		}
+		return Action{Type: ActionDeleteLast}, nil
+	default:
+		return Action{}, fmt.Errorf("未知动作: %q", s)
+	}
+}
@@ -0,0 +1,194 @@
+package dsp
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/shellus/voiceWin/internal/audio"
+)
+
+// 采集音频的预处理：高通滤波去掉低频的风扇、空调、桌面震动声，
+// 谱减法降噪去掉稳定的背景噪声，自动增益把说话声调整到接近的响度。
+// 三个环节可以单独开关，按 高通 -> 降噪 -> 增益 的顺序处理 16位单声道 PCM。
+// 每个 20ms 的采集回调都会调用 Process，处理时间需远小于 20ms，见 BenchmarkPipeline。
+
+// Config 预处理配置，三个环节默认都关闭
+type Config struct {
+	HighPass       bool    `json:"high_pass"`        // 高通滤波
+	HighPassCutoff float64 `json:"high_pass_cutoff"` // 截止频率（Hz）
+
+	NoiseSuppression bool    `json:"noise_suppression"` // 谱减法降噪
+	NoiseStrength    float64 `json:"noise_strength"`    // 过减因子，越大降噪越多，语音失真也越多
+	NoiseFloor       float64 `json:"noise_floor"`       // 每个频点最多衰减到原幅度的比例，避免“音乐噪声”
+
+	AGC        bool    `json:"agc"`          // 自动增益
+	AGCTarget  float64 `json:"agc_target"`   // 目标响度（dBFS，RMS）
+	AGCMaxGain float64 `json:"agc_max_gain"` // 最大增益（dB）
+}
+
+// DefaultConfig 默认参数，所有环节都关闭
+func DefaultConfig() Config {
+	return Config{
+		HighPassCutoff: 100,
+		NoiseStrength:  2,
+		NoiseFloor:     0.1,
+		AGCTarget:      -20,
+		AGCMaxGain:     24,
+	}
+}
+
+// Enabled 是否开启了任一环节
+func (c Config) Enabled() bool {
+	return c.HighPass || c.NoiseSuppression || c.AGC
+}
+
+// Pipeline 预处理流水线，不是并发安全的，同一时间只能在一个采集回调中使用
+type Pipeline struct {
+	hpf *highPass
+	ns  *noiseSuppressor
+	agc *agc
+
+	samples []float64 // 复用的处理缓冲区
+	out     []byte
+}
+
+// New 按配置创建流水线，sampleRate 为采样率
+func New(cfg Config, sampleRate int) *Pipeline {
+	p := &Pipeline{}
+	if cfg.HighPass && cfg.HighPassCutoff > 0 {
+		p.hpf = newHighPass(cfg.HighPassCutoff, float64(sampleRate))
+	}
+	if cfg.NoiseSuppression {
+		p.ns = newNoiseSuppressor(sampleRate, cfg.NoiseStrength, cfg.NoiseFloor)
+	}
+	if cfg.AGC {
+		p.agc = newAGC(cfg.AGCTarget, cfg.AGCMaxGain, float64(sampleRate))
+	}
+	return p
+}
+
+// Process 处理一段 16位小端 PCM，返回等长的结果
+// 返回的切片在下次调用前有效；开启降噪时输出比输入延迟一个分析帧（16kHz 时为 32ms）
+func (p *Pipeline) Process(pcm []byte) []byte {
+	n := len(pcm) / 2
+	if cap(p.samples) < n {
+		p.samples = make([]float64, n)
+		p.out = make([]byte, n*2)
+	}
+	x := p.samples[:n]
+	for i := range x {
+		x[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+	}
+	if p.hpf != nil {
+		p.hpf.process(x)
+	}
+	if p.ns != nil {
+		p.ns.process(x)
+	}
+	if p.agc != nil {
+		p.agc.process(x)
+	}
+	out := p.out[:n*2]
+	for i, v := range x {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(clip(v)))
+	}
+	return out
+}
+
+// Reset 清空滤波器和分析帧中残留的音频，开始新的一段录音时调用
+// 学到的噪声特征和当前增益保留，下次录音开始时直接生效
+func (p *Pipeline) Reset() {
+	if p.hpf != nil {
+		p.hpf.reset()
+	}
+	if p.ns != nil {
+		p.ns.reset()
+	}
+}
+
+func clip(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+// highPass 二阶巴特沃斯高通滤波器（RBJ biquad，直接II型转置）
+type highPass struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func newHighPass(cutoff, sampleRate float64) *highPass {
+	w0 := 2 * math.Pi * cutoff / sampleRate
+	// Q = 1/√2，alpha = sin(w0) / (2Q)
+	cos, alpha := math.Cos(w0), math.Sin(w0)/math.Sqrt2
+	a0 := 1 + alpha
+	return &highPass{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *highPass) process(x []float64) {
+	for i, v := range x {
+		y := f.b0*v + f.z1
+		f.z1 = f.b1*v - f.a1*y + f.z2
+		f.z2 = f.b2*v - f.a2*y
+		x[i] = y
+	}
+}
+
+func (f *highPass) reset() {
+	f.z1, f.z2 = 0, 0
+}
+
+// agc 自动增益：按每段音频的 RMS 调整增益，增益在段内线性过渡，
+// 响度下降时快速压低（attack），上升时缓慢放大（release），低于门限的静音段保持增益不变，避免把噪声放大
+type agc struct {
+	target, maxGain   float64 // dBFS、dB
+	attack, release   float64 // 时间常数（秒）
+	gate              float64 // dBFS
+	sampleRate, gainD float64 // gainD 为当前增益（dB）
+}
+
+func newAGC(target, maxGain, sampleRate float64) *agc {
+	return &agc{target: target, maxGain: maxGain, attack: 0.05, release: 0.5, gate: -50, sampleRate: sampleRate}
+}
+
+func (a *agc) process(x []float64) {
+	if len(x) == 0 {
+		return
+	}
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	level := audio.DBFS(math.Sqrt(sum / float64(len(x))))
+
+	from := a.gainD
+	if level > a.gate {
+		want := math.Min(a.target-level, a.maxGain)
+		tau := a.release
+		if want < a.gainD {
+			tau = a.attack
+		}
+		k := 1 - math.Exp(-float64(len(x))/a.sampleRate/tau)
+		a.gainD += (want - a.gainD) * k
+	}
+	g0, g1 := dbToGain(from), dbToGain(a.gainD)
+	for i := range x {
+		x[i] *= g0 + (g1-g0)*float64(i+1)/float64(len(x))
+	}
+}
+
+func dbToGain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
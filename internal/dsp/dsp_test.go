@@ -0,0 +1,204 @@
+package dsp
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/audio"
+)
+
+const rate = 16000
+
+// sine 生成 seconds 秒、幅度为 amp 的正弦波
+func sine(freq, amp, seconds float64) []float64 {
+	x := make([]float64, int(seconds*rate))
+	for i := range x {
+		x[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/rate)
+	}
+	return x
+}
+
+// noise 生成均匀分布的白噪声
+func noise(amp, seconds float64) []float64 {
+	r := rand.New(rand.NewSource(1))
+	x := make([]float64, int(seconds*rate))
+	for i := range x {
+		x[i] = amp * (r.Float64()*2 - 1)
+	}
+	return x
+}
+
+func toPCM(x []float64) []byte {
+	pcm := make([]byte, len(x)*2)
+	for i, v := range x {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(clip(v)))
+	}
+	return pcm
+}
+
+func fromPCM(pcm []byte) []float64 {
+	x := make([]float64, len(pcm)/2)
+	for i := range x {
+		x[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+	}
+	return x
+}
+
+func rms(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
+
+// process 按 20ms 一段处理
+func process(p *Pipeline, x []float64) []float64 {
+	pcm := toPCM(x)
+	var out []float64
+	for i := 0; i < len(pcm); i += 640 {
+		end := min(i+640, len(pcm))
+		out = append(out, fromPCM(p.Process(pcm[i:end]))...)
+	}
+	return out
+}
+
+func TestHighPass(t *testing.T) {
+	cfg := Config{HighPass: true, HighPassCutoff: 100}
+	hum := process(New(cfg, rate), sine(50, 10000, 1))
+	voice := process(New(cfg, rate), sine(1000, 10000, 1))
+	// 二阶滤波器在截止频率以下一个倍频程衰减约 12dB
+	if g := audio.DBFS(rms(hum[rate/2:])) - audio.DBFS(rms(sine(50, 10000, 0.5))); g > -10 {
+		t.Errorf("50Hz 应衰减超过 10dB，实际 %.1fdB", g)
+	}
+	if g := audio.DBFS(rms(voice[rate/2:])) - audio.DBFS(rms(sine(1000, 10000, 0.5))); math.Abs(g) > 0.5 {
+		t.Errorf("1kHz 不应衰减，实际 %.1fdB", g)
+	}
+}
+
+func TestNoiseSuppression_Transparent(t *testing.T) {
+	// 过减因子为 0 时不做任何处理，输出为延迟一帧的输入
+	p := New(Config{NoiseSuppression: true, NoiseStrength: 0, NoiseFloor: 0.1}, rate)
+	in := noise(8000, 1)
+	out := process(p, in)
+	delay := p.ns.size
+	for i := 0; i+delay < len(in); i++ {
+		if math.Abs(out[i+delay]-math.Round(in[i])) > 1 {
+			t.Fatalf("第 %d 个采样不一致: %v != %v", i, out[i+delay], in[i])
+		}
+	}
+}
+
+func TestNoiseSuppression(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NoiseSuppression = true
+	p := New(cfg, rate)
+
+	// 先是 1 秒只有噪声，然后是噪声中的 1kHz 语音
+	bg := noise(1000, 2)
+	in := make([]float64, len(bg))
+	copy(in, bg)
+	tone := sine(1000, 8000, 1)
+	for i := range tone {
+		in[rate+i] += tone[i]
+	}
+	out := process(p, in)
+	delay := p.ns.size
+
+	// 学到噪声后，只有噪声的部分衰减超过 10dB
+	if g := audio.DBFS(rms(out[rate/2:rate])) - audio.DBFS(rms(bg[rate/2:rate])); g > -10 {
+		t.Errorf("噪声应衰减超过 10dB，实际 %.1fdB", g)
+	}
+	// 语音部分基本保留
+	speech := out[rate+delay+rate/4 : 2*rate]
+	if g := audio.DBFS(rms(speech)) - audio.DBFS(rms(in[rate+rate/4:2*rate-delay])); g < -1.5 {
+		t.Errorf("语音不应明显衰减，实际 %.1fdB", g)
+	}
+}
+
+func TestAGC(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AGC = true
+	p := New(cfg, rate)
+
+	// 约 -47dBFS，需要的增益超过上限
+	in := sine(440, 200, 3)
+	quiet := process(p, in)
+	if level := audio.DBFS(rms(quiet[2*rate:])); math.Abs(level-cfg.AGCMaxGain-audio.DBFS(rms(in))) > 1 {
+		t.Errorf("增益应达到上限 %vdB，输出 %.1fdBFS", cfg.AGCMaxGain, level)
+	}
+	loud := process(p, sine(440, 23000, 1)) // 约 -3dBFS
+	if level := audio.DBFS(rms(loud[rate/2:])); math.Abs(level-cfg.AGCTarget) > 1 {
+		t.Errorf("输出应接近目标 %vdBFS，实际 %.1fdBFS", cfg.AGCTarget, level)
+	}
+
+	// 低于门限的静音不调整增益
+	gain := p.agc.gainD
+	process(p, noise(10, 1))
+	if p.agc.gainD != gain {
+		t.Errorf("静音时增益不应变化: %v -> %v", gain, p.agc.gainD)
+	}
+}
+
+func TestPipeline_Disabled(t *testing.T) {
+	in := toPCM(noise(8000, 0.1))
+	out := New(Config{}, rate).Process(in)
+	if string(out) != string(in) {
+		t.Error("没有开启任何环节时输出应与输入相同")
+	}
+}
+
+func TestPipeline_Budget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("short 模式跳过耗时检查")
+	}
+	cfg := DefaultConfig()
+	cfg.HighPass, cfg.NoiseSuppression, cfg.AGC = true, true, true
+	p := New(cfg, rate)
+	frame := toPCM(noise(8000, 0.02))
+	start := time.Now()
+	for i := 0; i < 500; i++ {
+		p.Process(frame)
+	}
+	// 10 秒音频，处理时间不应超过音频时长的 5%
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("处理 10 秒音频用了 %v", elapsed)
+	}
+}
+
+func benchmark(b *testing.B, cfg Config) {
+	p := New(cfg, rate)
+	frame := toPCM(noise(8000, 0.02))
+	b.SetBytes(int64(len(frame)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Process(frame)
+	}
+	// 每个 20ms 回调的处理时间占用的比例
+	b.ReportMetric(float64(b.Elapsed())/float64(b.N)/float64(20*time.Millisecond)*100, "%budget")
+}
+
+func BenchmarkHighPass(b *testing.B) {
+	benchmark(b, Config{HighPass: true, HighPassCutoff: 100})
+}
+
+func BenchmarkNoiseSuppression(b *testing.B) {
+	cfg := DefaultConfig()
+	cfg.NoiseSuppression = true
+	benchmark(b, cfg)
+}
+
+func BenchmarkAGC(b *testing.B) {
+	cfg := DefaultConfig()
+	cfg.AGC = true
+	benchmark(b, cfg)
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	cfg := DefaultConfig()
+	cfg.HighPass, cfg.NoiseSuppression, cfg.AGC = true, true, true
+	benchmark(b, cfg)
+}
@@ -0,0 +1,180 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// noiseSuppressor 谱减法降噪
+//
+// 按约 20ms 的帧（取 2 的幂）做短时傅里叶变换，帧移为半帧，分析和合成都使用正弦窗，
+// 两个窗相乘后相邻帧叠加恰好为 1，不处理时可以无失真地还原。
+// 噪声谱从静音帧中学习：帧能量不超过近期最低能量的 silenceRatio 倍时视为静音，
+// 用它平滑更新每个频点的噪声功率；近期最低能量缓慢上升，噪声变大后也能重新学习。
+// 每个频点的增益为 sqrt(1 - strength*噪声功率/帧功率)，不低于 floor，并和上一帧的增益平滑，减少“音乐噪声”。
+type noiseSuppressor struct {
+	size, hop int
+	strength  float64
+	floor     float64
+
+	window []float64
+	input  []float64 // 最近 size 个输入采样
+	fill   int       // input 中新写入、还未处理的采样数
+	output []float64 // 重叠相加的结果
+	queue  []float64 // 已完成、等待输出的采样
+	spec   []complex128
+
+	noise     []float64 // 每个频点的噪声功率，nil 表示还没有学习
+	gain      []float64 // 上一帧的增益
+	minEnergy float64   // 近期最低的帧能量
+}
+
+const (
+	silenceRatio = 3     // 帧能量不超过近期最低能量的倍数时视为静音（约 5dB）
+	noiseSmooth  = 0.1   // 静音帧更新噪声谱的权重
+	minRise      = 1.002 // 近期最低能量每帧上升的比例，约 10 秒上升 5dB
+	gainSmooth   = 0.5   // 增益下降时保留上一帧增益的比例
+)
+
+func newNoiseSuppressor(sampleRate int, strength, floor float64) *noiseSuppressor {
+	size := 1
+	for size < sampleRate/50 {
+		size <<= 1
+	}
+	ns := &noiseSuppressor{
+		size:     size,
+		hop:      size / 2,
+		strength: strength,
+		floor:    floor,
+		window:   make([]float64, size),
+		input:    make([]float64, size),
+		output:   make([]float64, size),
+		spec:     make([]complex128, size),
+		gain:     make([]float64, size/2+1),
+	}
+	for i := range ns.window {
+		ns.window[i] = math.Sin(math.Pi * float64(i) / float64(size))
+	}
+	ns.reset()
+	return ns
+}
+
+// reset 清空残留的音频，保留学到的噪声谱
+func (ns *noiseSuppressor) reset() {
+	clear(ns.input)
+	clear(ns.output)
+	ns.fill = 0
+	// 预先放入一个帧移的静音，保证每次都能输出和输入等长的采样
+	ns.queue = append(ns.queue[:0], make([]float64, ns.hop)...)
+	for i := range ns.gain {
+		ns.gain[i] = 1
+	}
+}
+
+// process 降噪，输出比输入延迟 size 个采样
+func (ns *noiseSuppressor) process(x []float64) {
+	for _, v := range x {
+		ns.input[ns.size-ns.hop+ns.fill] = v
+		ns.fill++
+		if ns.fill == ns.hop {
+			ns.frame()
+			copy(ns.input, ns.input[ns.hop:])
+			ns.fill = 0
+		}
+	}
+	n := copy(x, ns.queue)
+	ns.queue = append(ns.queue[:0], ns.queue[n:]...)
+}
+
+// frame 处理 input 中的一帧，把前一个帧移的结果放入 queue
+func (ns *noiseSuppressor) frame() {
+	for i, v := range ns.input {
+		ns.spec[i] = complex(v*ns.window[i], 0)
+	}
+	fft(ns.spec, false)
+
+	bins := ns.size/2 + 1
+	var energy float64
+	for k := 0; k < bins; k++ {
+		energy += sqAbs(ns.spec[k])
+	}
+	if ns.noise == nil {
+		ns.noise = make([]float64, bins)
+		for k := range ns.noise {
+			ns.noise[k] = sqAbs(ns.spec[k])
+		}
+		ns.minEnergy = energy
+	}
+	ns.minEnergy *= minRise
+	if energy < ns.minEnergy {
+		ns.minEnergy = energy
+	}
+	if energy <= ns.minEnergy*silenceRatio {
+		for k := range ns.noise {
+			ns.noise[k] += (sqAbs(ns.spec[k]) - ns.noise[k]) * noiseSmooth
+		}
+	}
+
+	for k := 0; k < bins; k++ {
+		g := ns.floor
+		if p := sqAbs(ns.spec[k]); p > 0 {
+			g = math.Max(math.Sqrt(math.Max(1-ns.strength*ns.noise[k]/p, 0)), ns.floor)
+		}
+		// 增益立即上升，缓慢下降，避免语音的起始被削弱
+		if g < ns.gain[k] {
+			g = ns.gain[k]*gainSmooth + g*(1-gainSmooth)
+		}
+		ns.gain[k] = g
+		ns.spec[k] *= complex(g, 0)
+		if k > 0 && k < ns.size/2 {
+			ns.spec[ns.size-k] = cmplx.Conj(ns.spec[k])
+		}
+	}
+	fft(ns.spec, true)
+
+	for i := range ns.output {
+		ns.output[i] += real(ns.spec[i]) * ns.window[i]
+	}
+	ns.queue = append(ns.queue, ns.output[:ns.hop]...)
+	copy(ns.output, ns.output[ns.hop:])
+	clear(ns.output[ns.size-ns.hop:])
+}
+
+func sqAbs(c complex128) float64 {
+	return real(c)*real(c) + imag(c)*imag(c)
+}
+
+// fft 原地计算长度为 2 的幂的复数傅里叶变换，inverse 时计算逆变换并除以长度
+func fft(a []complex128, inverse bool) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for length := 2; length <= n; length <<= 1 {
+		w := cmplx.Exp(complex(0, sign*2*math.Pi/float64(length)))
+		for i := 0; i < n; i += length {
+			wn := complex(1, 0)
+			for j := 0; j < length/2; j++ {
+				u, v := a[i+j], a[i+j+length/2]*wn
+				a[i+j], a[i+j+length/2] = u+v, u-v
+				wn *= w
+			}
+		}
+	}
+	if inverse {
+		for i := range a {
+			a[i] /= complex(float64(n), 0)
+		}
+	}
+}
@@ -0,0 +1,781 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/archive"
+	"github.com/shellus/voiceWin/internal/audio"
+	"github.com/shellus/voiceWin/internal/capture"
+	"github.com/shellus/voiceWin/internal/dsp"
+	"github.com/shellus/voiceWin/internal/history"
+	"github.com/shellus/voiceWin/internal/logging"
+	"github.com/shellus/voiceWin/internal/recognition"
+	"github.com/shellus/voiceWin/internal/usage"
+)
+
+// Engine 把音频捕获和语音识别串起来，支持多次开始/停止识别，
+// 并以事件的形式对外发布状态、音量、中间结果和最终结果。
+// 命令行模式和 serve 模式共用同一个 Engine。
+//
+// 状态流转：
+// idle -Start-> starting -> listening -Stop-> stopping -最终结果/错误-> idle
+// listening 状态下识别服务检测到说话结束（max_end_silence，OpenAI 兼容后端在本地按静音门限检测）时会直接回到 idle，
+// 实时语音识别模式（StartParam.Mode）没有这个限制，会一直识别到 Stop，并按句发布 sentence_end 事件
+
+// State 引擎状态
+type State string
+
+const (
+	StateIdle      State = "idle"      // 空闲
+	StateStarting  State = "starting"  // 正在连接识别服务
+	StateListening State = "listening" // 正在录音识别
+	StateStopping  State = "stopping"  // 已停止录音，等待最终结果
+)
+
+// EventType 事件类型
+type EventType string
+
+const (
+	EventState   EventType = "state"   // 状态变化
+	EventVolume  EventType = "volume"  // 电平，录音期间按固定间隔发布
+	EventPartial EventType = "partial" // 中间识别结果
+	EventFinal   EventType = "final"   // 最终识别结果，空文本表示没有识别到声音
+	EventError   EventType = "error"   // 识别失败
+	EventWarning EventType = "warning" // 警告，不影响识别，Text 为警告内容
+
+	EventSentenceBegin EventType = "sentence_begin" // 实时语音识别模式：一句话开始
+	EventSentenceEnd   EventType = "sentence_end"   // 实时语音识别模式：一句话结束，Text 为该句文本
+)
+
+// Event 引擎事件
+type Event struct {
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	State  State     `json:"state,omitempty"`
+	Text   string    `json:"text,omitempty"`
+	TaskID string    `json:"task_id,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	// Levels 每个声道的电平（dBFS），只在 volume 事件中出现
+	Levels []audio.Level `json:"levels,omitempty"`
+	// Sentence 句子编号和时间，只在 sentence_begin、sentence_end 事件中出现
+	Sentence *recognition.Sentence `json:"sentence,omitempty"`
+	// Sentences 本次识别的分句和词时间信息，只在 final 事件中出现，识别服务不提供时为空
+	Sentences []recognition.Sentence `json:"sentences,omitempty"`
+}
+
+// 识别后端
+const (
+	BackendAliyun = "aliyun" // 阿里云，按 StartParam.Mode 使用一句话识别（默认）或实时语音识别
+	BackendLocal  = "local"  // 本地离线识别进程
+	BackendOpenAI = "openai" // OpenAI 兼容的 /audio/transcriptions 接口，非流式
+)
+
+// Options 引擎配置
+type Options struct {
+	Backend    string // 识别后端，为空时使用阿里云
+	Aliyun     *recognition.AliyunConfig
+	Local      *recognition.LocalConfig  // 本地识别进程，Backend 为 local 时使用
+	OpenAI     *recognition.OpenAIConfig // OpenAI 兼容服务，Backend 为 openai 时使用
+	StartParam *recognition.StartParam
+	Timeouts   recognition.Timeouts // 识别各阶段超时，为 0 的字段使用默认值
+	// Prewarm 开启连接预热，后台保持一个已就绪的连接，减少开头的延迟，只对阿里云一句话识别生效
+	Prewarm        bool
+	PrewarmRefresh time.Duration     // 预热连接的刷新间隔，为 0 时使用默认值
+	History        *history.Store    // 识别历史，为 nil 时不记录
+	Recorder       *archive.Recorder // 录音归档，为 nil 时不归档
+	Usage          *usage.Tracker    // 用量统计和限额，为 nil 时不统计
+	DSP            dsp.Config        // 采集音频的预处理，默认不开启
+	// Meter 电平表参数，为零值时使用 audio.DefaultMeterConfig()
+	// 其中的静音门限也用于信号诊断的音量过低检查，以及 OpenAI 兼容后端在本地检测说话结束
+	Meter audio.MeterConfig
+	// Channels 采集的声道数，大于 1 时每个声道（每人一个麦克风）单独识别，
+	// 结果按说话人（声道 0 为 A，声道 1 为 B）标注并按时间合并，为 0 时为 1
+	// 多声道只支持阿里云实时语音识别模式，见 errMultiChannelMode
+	Channels int
+	// Source 采集来源：capture.SourceMic（默认）、SourceLoopback（系统播放的声音）或 SourceMix（两者混合）
+	Source         string
+	LoopbackDevice string // 采集系统声音的设备，为空时使用默认输出设备
+}
+
+// subscriberBuffer 每个订阅者的事件缓冲区长度
+const subscriberBuffer = 256
+
+// Engine 识别引擎
+type Engine struct {
+	opts      Options
+	capture   *capture.AudioCapture
+	sessionID string
+	logger    *slog.Logger // 带 session_id 字段
+
+	mutex     sync.Mutex
+	state     State
+	clients   map[string]recognition.Recognizer // 按识别模式创建的客户端，首次使用时创建
+	client    recognition.Recognizer            // 当前识别使用的客户端
+	utterance history.Utterance                 // 当前识别的历史记录
+	kind      string                            // 当前识别的计费类型
+	timing    timing                            // 当前识别的时间点
+	signal    signalCheck                       // 当前识别的信号质量检查
+
+	sentBytes atomic.Int64 // 当前识别已发送的音频字节数
+
+	subMutex sync.Mutex
+	subs     map[chan Event]struct{}
+
+	closed chan struct{}
+	ctx    context.Context // Close 时取消，用于连接识别服务等不应超过引擎生命周期的等待
+	cancel context.CancelFunc
+}
+
+// New 创建识别引擎
+func New(opts Options) (*Engine, error) {
+	if opts.StartParam == nil {
+		opts.StartParam = recognition.DefaultStartParam()
+	}
+	if opts.Channels <= 0 {
+		opts.Channels = 1
+	}
+
+	audioCapture := capture.NewAudioCapture()
+	if audioCapture == nil {
+		return nil, fmt.Errorf("初始化音频设备失败")
+	}
+	if err := audioCapture.SetChannels(opts.Channels); err != nil {
+		audioCapture.Close()
+		return nil, err
+	}
+	if err := audioCapture.SetSource(opts.Source, opts.LoopbackDevice); err != nil {
+		audioCapture.Close()
+		return nil, err
+	}
+
+	sessionID := history.NewSessionID()
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Engine{
+		opts:      opts,
+		capture:   audioCapture,
+		sessionID: sessionID,
+		logger:    logging.Component(logging.Engine).With("session_id", sessionID),
+		state:     StateIdle,
+		clients:   make(map[string]recognition.Recognizer),
+		subs:      make(map[chan Event]struct{}),
+		closed:    make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	// 预先创建默认模式的客户端，配置错误在启动时就能发现
+	client, err := e.recognizer(opts.StartParam.Mode)
+	if err != nil {
+		cancel()
+		audioCapture.Close()
+		return nil, fmt.Errorf("初始化识别客户端失败: %w", err)
+	}
+	e.client = client
+
+	audioCapture.OnLevel = func(levels []audio.Level) {
+		e.publish(Event{Type: EventVolume, Levels: levels})
+	}
+	audioCapture.SetDSP(opts.DSP)
+	audioCapture.SetMeter(opts.meter())
+	audioCapture.OnAudioData = e.onAudioData
+	audioCapture.OnError = e.onCaptureError
+	return e, nil
+}
+
+// recognizer 返回识别模式对应的客户端，不存在时创建并开始读取它的结果通道，调用方需持有 mutex 或在 New 中调用
+// 只有阿里云后端区分识别模式
+func (e *Engine) recognizer(mode string) (recognition.Recognizer, error) {
+	if e.opts.Backend != "" && e.opts.Backend != BackendAliyun {
+		mode = ""
+	} else if mode == "" {
+		mode = recognition.ModeSentence
+	}
+	if c, ok := e.clients[mode]; ok {
+		return c, nil
+	}
+	var c recognition.Recognizer
+	if e.opts.Channels > 1 {
+		m, err := e.multiChannel(mode)
+		if err != nil {
+			return nil, err
+		}
+		c = m
+	} else {
+		var err error
+		if c, err = NewRecognizer(e.opts, mode); err != nil {
+			return nil, err
+		}
+	}
+	e.clients[mode] = c
+	go e.loop(c)
+	return c, nil
+}
+
+// errMultiChannelMode 多声道识别使用了不支持的识别模式
+// 只有实时语音识别按句返回相对录音开始的时间，各声道的句子才能按时间合并；
+// 一句话识别的每个声道会在该说话人停顿时各自结束，也没有句子时间
+var errMultiChannelMode = errors.New("多声道识别需要阿里云实时语音识别模式（mode 为 transcription）")
+
+// multiChannel 为每个声道创建一个识别器，合并为多声道识别器
+func (e *Engine) multiChannel(mode string) (*recognition.MultiChannel, error) {
+	if mode != recognition.ModeTranscription {
+		return nil, errMultiChannelMode
+	}
+	channels := make([]recognition.Recognizer, e.opts.Channels)
+	for i := range channels {
+		c, err := NewRecognizer(e.opts, mode)
+		if err != nil {
+			for _, c := range channels[:i] {
+				if cl, ok := c.(interface{ Close() }); ok {
+					cl.Close()
+				}
+			}
+			return nil, err
+		}
+		channels[i] = c
+	}
+	return recognition.NewMultiChannel(channels), nil
+}
+
+// NewRecognizer 按配置和识别模式创建识别器，文件转写等不需要音频设备的场景可以直接使用
+// mode 只对阿里云后端生效，为空时使用一句话识别
+func NewRecognizer(opts Options, mode string) (recognition.Recognizer, error) {
+	switch opts.Backend {
+	case "", BackendAliyun:
+	case BackendLocal:
+		client, err := recognition.NewLocalClient(opts.Local, opts.StartParam)
+		if err != nil {
+			return nil, err
+		}
+		client.SetTimeouts(opts.Timeouts)
+		return client, nil
+	case BackendOpenAI:
+		client, err := recognition.NewOpenAIClient(opts.OpenAI, opts.StartParam)
+		if err != nil {
+			return nil, err
+		}
+		client.SetTimeouts(opts.Timeouts)
+		client.SetSilenceThreshold(opts.meter().SilenceThreshold)
+		return client, nil
+	default:
+		return nil, fmt.Errorf("未知的识别后端: %s", opts.Backend)
+	}
+
+	switch mode {
+	case "", recognition.ModeSentence:
+	case recognition.ModeTranscription:
+		client, err := recognition.NewTranscriptionClient(opts.Aliyun, opts.StartParam)
+		if err != nil {
+			return nil, err
+		}
+		client.SetTimeouts(opts.Timeouts)
+		return client, nil
+	default:
+		return nil, fmt.Errorf("未知的识别模式: %s", mode)
+	}
+
+	if opts.Prewarm {
+		p, err := recognition.NewPrewarmer(opts.Aliyun, *opts.StartParam, opts.Timeouts, opts.PrewarmRefresh)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Usage != nil {
+			// 没有用上的预热连接也是一次计费的识别请求
+			p.SetOnDiscard(func(taskID string) {
+				if err := opts.Usage.Record(usage.Record{Kind: usage.KindSentence, Prewarm: true}); err != nil {
+					logging.Component(logging.Engine).Warn("记录预热连接用量失败", "task_id", taskID, "error", err)
+				}
+			})
+		}
+		return p, nil
+	}
+	client, err := recognition.NewAliyunClient(opts.Aliyun, opts.StartParam)
+	if err != nil {
+		return nil, err
+	}
+	client.SetTimeouts(opts.Timeouts)
+	return client, nil
+}
+
+// meter 返回电平表参数，没有配置时为默认值
+func (o Options) meter() audio.MeterConfig {
+	if o.Meter == (audio.MeterConfig{}) {
+		return audio.DefaultMeterConfig()
+	}
+	return o.Meter
+}
+
+// State 返回当前状态
+func (e *Engine) State() State {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.state
+}
+
+// Start 开始一次识别
+func (e *Engine) Start() error {
+	e.mutex.Lock()
+	if e.state != StateIdle {
+		state := e.state
+		e.mutex.Unlock()
+		return fmt.Errorf("当前状态 %s 不能开始识别", state)
+	}
+	var warning string
+	if e.opts.Usage != nil {
+		var err error
+		if warning, err = e.opts.Usage.Check(time.Now()); err != nil {
+			e.mutex.Unlock()
+			return err
+		}
+	}
+	client, err := e.recognizer(e.opts.StartParam.Mode)
+	if err != nil {
+		e.mutex.Unlock()
+		return fmt.Errorf("初始化识别客户端失败: %w", err)
+	}
+	e.client = client
+	e.kind = UsageKind(e.opts.Backend, e.opts.StartParam.Mode)
+	e.sentBytes.Store(0)
+	kind := e.kind
+	notify := e.setState(StateStarting)
+	e.mutex.Unlock()
+	if warning != "" {
+		e.logger.Warn(warning)
+		e.publish(Event{Type: EventWarning, Text: warning})
+	}
+	notify()
+
+	connectAt := time.Now()
+	if err := client.StartRecognitionContext(e.ctx); err != nil {
+		e.recordUsage(kind, true)
+		observeFinish(kind, timing{}, err)
+		e.resetIdle()
+		return fmt.Errorf("启动语音识别失败: %w", err)
+	}
+	connectSeconds.With(kind).Observe(time.Since(connectAt).Seconds())
+	e.logger.Debug("开始识别", "task_id", client.TaskID(), "kind", kind)
+
+	// 丢弃上次识别残留在缓冲区中的音频
+	e.capture.GetPCMData()
+	if e.opts.Recorder != nil {
+		e.opts.Recorder.Begin()
+	}
+
+	e.mutex.Lock()
+	if e.state != StateStarting || e.client != client {
+		// 连接期间识别已经结束（如识别服务报错），错误已由 finish 发布
+		e.mutex.Unlock()
+		client.ShutdownRecognition()
+		e.discardRecording()
+		return fmt.Errorf("启动语音识别失败: 连接期间识别已结束")
+	}
+	select {
+	case <-e.closed:
+		e.mutex.Unlock()
+		client.ShutdownRecognition()
+		e.discardRecording()
+		e.resetIdle()
+		return fmt.Errorf("启动语音识别失败: 引擎已关闭")
+	default:
+	}
+	e.utterance = history.Utterance{
+		SessionID: e.sessionID,
+		Time:      time.Now(),
+		Device:    e.capture.DeviceName(),
+	}
+	e.timing = timing{listenAt: time.Now()}
+	e.signal = signalCheck{next: time.Now().Add(firstSignalCheck)}
+	notify = e.setState(StateListening)
+	e.mutex.Unlock()
+	notify()
+
+	if err := e.capture.Start(); err != nil {
+		client.ShutdownRecognition()
+		e.resetIdle()
+		return fmt.Errorf("启动音频捕获失败: %w", err)
+	}
+	return nil
+}
+
+// Stop 停止录音并等待最终结果，最终结果通过事件发布
+func (e *Engine) Stop() error {
+	e.mutex.Lock()
+	if e.state != StateListening {
+		e.mutex.Unlock()
+		return nil
+	}
+	notify := e.setState(StateStopping)
+	e.timing.stopAt = time.Now()
+	e.mutex.Unlock()
+	notify()
+
+	if err := e.capture.Stop(); err != nil {
+		e.logger.Warn("停止音频捕获失败", "error", err)
+	}
+	if err := e.currentClient().StopRecognitionContext(context.Background()); err != nil {
+		e.finish(e.currentClient(), "", fmt.Errorf("停止识别失败: %w", err))
+		return err
+	}
+	return nil
+}
+
+// Config 返回当前识别参数
+func (e *Engine) Config() recognition.StartParam {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return *e.opts.StartParam
+}
+
+// SetConfig 修改识别参数，只能在空闲状态下修改，下次识别生效
+func (e *Engine) SetConfig(p recognition.StartParam) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.state != StateIdle {
+		return fmt.Errorf("识别进行中，不能修改参数")
+	}
+	switch p.Mode {
+	case "", recognition.ModeSentence, recognition.ModeTranscription:
+	default:
+		return fmt.Errorf("未知的识别模式: %s", p.Mode)
+	}
+	if e.opts.Channels > 1 && p.Mode != recognition.ModeTranscription {
+		return errMultiChannelMode
+	}
+	*e.opts.StartParam = p
+	for _, c := range e.clients {
+		if s, ok := c.(interface{ SetStartParam(recognition.StartParam) }); ok {
+			// 预热连接使用自己的参数副本
+			s.SetStartParam(p)
+		}
+	}
+	return nil
+}
+
+// Devices 列出所有采集设备
+func (e *Engine) Devices() ([]capture.Device, error) {
+	return e.capture.ListDevices()
+}
+
+// DeviceName 返回当前使用的采集设备名称
+func (e *Engine) DeviceName() string {
+	return e.capture.DeviceName()
+}
+
+// SetDevice 选择采集设备，只能在空闲状态下修改，name 为空表示使用系统默认设备
+func (e *Engine) SetDevice(name string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.state != StateIdle {
+		return fmt.Errorf("识别进行中，不能切换设备")
+	}
+	return e.capture.SetDevice(name)
+}
+
+// History 返回识别历史存储，未开启时为 nil
+func (e *Engine) History() *history.Store {
+	return e.opts.History
+}
+
+// Subscribe 订阅引擎事件，返回事件通道和取消订阅函数
+func (e *Engine) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	e.subMutex.Lock()
+	e.subs[ch] = struct{}{}
+	e.subMutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.subMutex.Lock()
+			delete(e.subs, ch)
+			e.subMutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Close 停止识别并释放所有资源
+func (e *Engine) Close() {
+	select {
+	case <-e.closed:
+		return
+	default:
+	}
+	close(e.closed)
+	e.cancel()
+	e.mutex.Lock()
+	clients := make([]recognition.Recognizer, 0, len(e.clients))
+	for _, c := range e.clients {
+		clients = append(clients, c)
+	}
+	e.mutex.Unlock()
+	for _, client := range clients {
+		client.ShutdownRecognition()
+		if c, ok := client.(interface{ Close() }); ok {
+			c.Close()
+		}
+	}
+	e.capture.Close()
+	if e.opts.Recorder != nil {
+		e.opts.Recorder.Close()
+	}
+}
+
+// onAudioData 在采集回调中读取音频并发送给识别服务，返回的错误由采集器交给 onCaptureError
+func (e *Engine) onAudioData() error {
+	pcmData := e.capture.GetPCMData()
+	e.mutex.Lock()
+	state, client := e.state, e.client
+	e.mutex.Unlock()
+	if state != StateListening {
+		return nil
+	}
+	if len(pcmData) == 0 {
+		// 当audioCapture.Start()后，采集器触发了回调，应该20ms收到一次采集数据的
+		return fmt.Errorf("音频数据为空")
+	}
+	if err := client.SendAudioData(pcmData); err != nil {
+		e.logger.Warn("发送音频数据失败", "task_id", client.TaskID(), "error", err)
+		return nil
+	}
+	e.sentBytes.Add(int64(len(pcmData)))
+	if e.opts.Recorder != nil {
+		e.opts.Recorder.Write(pcmData)
+	}
+	e.checkSignal()
+	return nil
+}
+
+// onCaptureError 采集出错时以该错误结束本次识别
+// 在采集回调中调用，不能在回调中停止设备，所以放到后台执行
+func (e *Engine) onCaptureError(err error) {
+	go func() {
+		e.mutex.Lock()
+		if e.state != StateListening {
+			e.mutex.Unlock()
+			return
+		}
+		notify := e.setState(StateStopping)
+		c := e.client
+		e.mutex.Unlock()
+		notify()
+		e.logger.Error("音频采集失败", "task_id", c.TaskID(), "error", err)
+		e.finish(c, "", fmt.Errorf("音频采集失败: %w", err))
+	}()
+}
+
+// currentClient 返回当前识别使用的客户端
+func (e *Engine) currentClient() recognition.Recognizer {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.client
+}
+
+// loop 读取一个识别客户端的结果通道并转换为事件，不是当前客户端的结果直接丢弃
+func (e *Engine) loop(c recognition.Recognizer) {
+	// 不支持按句返回的客户端，句子通道为 nil，永远不会被选中
+	var begins, ends <-chan recognition.Sentence
+	if sc, ok := c.(recognition.SentenceRecognizer); ok {
+		begins, ends = sc.GetSentenceBeginChannel(), sc.GetSentenceEndChannel()
+	}
+	for {
+		select {
+		case text := <-c.GetResultChannel():
+			if e.currentClient() == c {
+				e.observePartial()
+				e.publish(Event{Type: EventPartial, Text: text})
+			}
+		case s := <-begins:
+			if e.currentClient() == c {
+				e.publish(Event{Type: EventSentenceBegin, Sentence: &s})
+			}
+		case s := <-ends:
+			if e.currentClient() == c {
+				e.observeSentence(s)
+				text := s.Text
+				if s.Speaker != "" {
+					text = s.Speaker + ": " + text
+				}
+				e.publish(Event{Type: EventSentenceEnd, Text: text, Sentence: &s})
+			}
+		case text := <-c.GetCompleteChannel():
+			e.finish(c, text, nil)
+		case err := <-c.GetErrorChannel():
+			e.finish(c, "", err)
+		case <-e.closed:
+			return
+		}
+	}
+}
+
+// finish 结束本次识别：释放连接，保存归档和历史，发布结果并回到空闲状态
+func (e *Engine) finish(c recognition.Recognizer, text string, err error) {
+	e.mutex.Lock()
+	if e.state == StateIdle || e.client != c {
+		e.mutex.Unlock()
+		return
+	}
+	notify := func() {}
+	if e.state == StateListening {
+		// 识别服务检测到说话结束，阻止并发的 Stop 再去停止识别
+		notify = e.setState(StateStopping)
+	}
+	u, kind, t := e.utterance, e.kind, e.timing
+	e.mutex.Unlock()
+	notify()
+
+	e.capture.Stop()
+	c.ShutdownRecognition()
+
+	e.recordUsage(kind, err != nil)
+	observeFinish(kind, t, err)
+	u.TaskID = c.TaskID()
+	u.Duration = time.Since(u.Time).Milliseconds()
+	u.Text = text
+	var sentences []recognition.Sentence
+	if sc, ok := c.(recognition.SegmentRecognizer); ok && err == nil {
+		sentences = sc.Sentences()
+		u.Segments = segments(sentences)
+	}
+
+	if problems := e.capture.Problems(); len(problems) > 0 && (err != nil || text == "") {
+		// 没有识别结果时，信号问题往往就是原因，附在错误或警告中
+		msgs := make([]string, len(problems))
+		for i, p := range problems {
+			msgs[i] = p.Message
+		}
+		if err != nil {
+			err = fmt.Errorf("%w（%s）", err, strings.Join(msgs, "；"))
+		} else {
+			e.publish(Event{Type: EventWarning, TaskID: u.TaskID, Text: strings.Join(msgs, "；")})
+		}
+	}
+
+	if err != nil || text == "" {
+		e.discardRecording()
+	} else {
+		e.save(u)
+	}
+
+	if err != nil {
+		e.logger.Warn("识别失败", "task_id", u.TaskID, "error", err)
+		e.publish(Event{Type: EventError, TaskID: u.TaskID, Error: err.Error()})
+	} else {
+		e.publish(Event{Type: EventFinal, TaskID: u.TaskID, Text: text, Sentences: sentences})
+	}
+	e.resetIdle()
+}
+
+// recordUsage 记录本次识别的用量，多声道时每个声道是一次单独计费的识别
+func (e *Engine) recordUsage(kind string, failed bool) {
+	if e.opts.Usage == nil {
+		return
+	}
+	channels := int64(e.opts.Channels)
+	audioMs := e.sentBytes.Load() / 2 / channels * 1000 / int64(e.opts.StartParam.SampleRate)
+	for range channels {
+		if err := e.opts.Usage.Record(usage.Record{Kind: kind, AudioMs: audioMs, Failed: failed}); err != nil {
+			e.logger.Warn("记录用量失败", "error", err)
+		}
+	}
+}
+
+// UsageKind 返回识别后端和识别模式对应的计费类型
+func UsageKind(backend, mode string) string {
+	switch backend {
+	case BackendLocal:
+		return usage.KindLocal
+	case BackendOpenAI:
+		return usage.KindOpenAI
+	}
+	if mode == recognition.ModeTranscription {
+		return usage.KindTranscription
+	}
+	return usage.KindSentence
+}
+
+// segments 把识别结果的分句转换为历史记录的时间信息
+func segments(sentences []recognition.Sentence) []history.Segment {
+	var segs []history.Segment
+	for _, s := range sentences {
+		seg := history.Segment{Begin: s.BeginTime, End: s.EndTime, Text: s.Text, Confidence: s.Confidence, Speaker: s.Speaker}
+		for _, w := range s.Words {
+			seg.Words = append(seg.Words, history.Word{Begin: w.BeginTime, End: w.EndTime, Text: w.Text, Confidence: w.Confidence})
+		}
+		segs = append(segs, seg)
+	}
+	return segs
+}
+
+// save 保存录音归档和识别历史
+func (e *Engine) save(u history.Utterance) {
+	if e.opts.Recorder != nil {
+		path, err := e.opts.Recorder.Finish(u.TaskID)
+		if err != nil {
+			e.logger.Warn("保存录音归档失败", "task_id", u.TaskID, "error", err)
+		}
+		u.Audio = path
+	}
+	if e.opts.History != nil {
+		if err := e.opts.History.Append(u); err != nil {
+			e.logger.Warn("保存识别历史失败", "task_id", u.TaskID, "error", err)
+		}
+	}
+}
+
+// discardRecording 丢弃本次识别的录音归档
+func (e *Engine) discardRecording() {
+	if e.opts.Recorder != nil {
+		e.opts.Recorder.Discard()
+	}
+}
+
+func (e *Engine) resetIdle() {
+	e.mutex.Lock()
+	notify := e.setState(StateIdle)
+	e.mutex.Unlock()
+	notify()
+}
+
+// setState 修改状态，返回发布状态事件的函数，调用方需持有 mutex，并在释放 mutex 后调用返回的函数
+// publish 可能等待慢的订阅者，持有 mutex 发布会阻塞采集回调 onAudioData
+func (e *Engine) setState(s State) (notify func()) {
+	if e.state == s {
+		return func() {}
+	}
+	e.state = s
+	return func() { e.publish(Event{Type: EventState, State: s}) }
+}
+
+// publishTimeout 重要事件等待订阅者接收的最长时间
+const publishTimeout = time.Second
+
+// publish 向所有订阅者发布事件
+// 音量和中间结果在订阅者来不及处理时直接丢弃，其余事件最多等待 publishTimeout
+func (e *Engine) publish(ev Event) {
+	ev.Time = time.Now()
+	droppable := ev.Type == EventVolume || ev.Type == EventPartial
+
+	e.subMutex.Lock()
+	defer e.subMutex.Unlock()
+	for ch := range e.subs {
+		if droppable {
+			select {
+			case ch <- ev:
+			default:
+			}
+			continue
+		}
+		select {
+		case ch <- ev:
+		case <-time.After(publishTimeout):
+			e.logger.Warn("订阅者处理过慢，丢弃事件", "event", ev.Type)
+		}
+	}
+}
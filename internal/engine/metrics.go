@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/metrics"
+	"github.com/shellus/voiceWin/internal/recognition"
+)
+
+// 识别指标，见 metrics.Default，按计费类型（UsageKind）区分识别后端和模式
+var (
+	recognitionRequests = metrics.Default.NewCounterVec("voicewin_recognition_requests_total",
+		"识别任务数，包括失败的", "kind")
+	recognitionFailures = metrics.Default.NewCounterVec("voicewin_recognition_failures_total",
+		"失败的识别任务数，按识别服务的 status 错误码区分，其他错误为 0", "code")
+	connectSeconds = metrics.Default.NewHistogramVec("voicewin_recognition_connect_seconds",
+		"开始识别到识别服务就绪的时间", "kind", nil)
+	firstPartialSeconds = metrics.Default.NewHistogramVec("voicewin_recognition_first_partial_seconds",
+		"开始录音到收到第一个中间结果的时间", "kind", nil)
+	finalLatencySeconds = metrics.Default.NewHistogramVec("voicewin_recognition_final_latency_seconds",
+		"说话结束到收到最终结果的时间：主动停止时从 Stop 开始计算，识别服务检测到说话结束时从最后一个中间结果开始计算",
+		"kind", nil)
+	sentenceLatencySeconds = metrics.Default.NewHistogramVec("voicewin_recognition_sentence_latency_seconds",
+		"实时语音识别模式下，一句话在音频中结束到收到该句结果的时间", "kind", nil)
+)
+
+// timing 一次识别的时间点，用于计算延迟指标
+type timing struct {
+	listenAt    time.Time // 开始录音
+	lastPartial time.Time // 最后一个中间结果，为零表示还没有收到
+	stopAt      time.Time // 调用 Stop，为零表示识别服务自行结束
+}
+
+// observePartial 记录收到中间结果，第一个中间结果计入首字延迟
+func (e *Engine) observePartial() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.state != StateListening && e.state != StateStopping {
+		return
+	}
+	now := time.Now()
+	if e.timing.lastPartial.IsZero() {
+		firstPartialSeconds.With(e.kind).Observe(now.Sub(e.timing.listenAt).Seconds())
+	}
+	e.timing.lastPartial = now
+}
+
+// observeSentence 记录实时语音识别模式下一句话的延迟，音频从开始录音时算起
+func (e *Engine) observeSentence(s recognition.Sentence) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.timing.listenAt.IsZero() || s.EndTime <= 0 {
+		return
+	}
+	end := e.timing.listenAt.Add(time.Duration(s.EndTime) * time.Millisecond)
+	sentenceLatencySeconds.With(e.kind).Observe(time.Since(end).Seconds())
+}
+
+// observeFinish 记录一次识别结束
+func observeFinish(kind string, t timing, err error) {
+	recognitionRequests.With(kind).Inc()
+	if err != nil {
+		recognitionFailures.With(strconv.Itoa(recognition.StatusCode(err))).Inc()
+		return
+	}
+	end := t.stopAt
+	if end.IsZero() {
+		end = t.lastPartial
+	}
+	if !end.IsZero() {
+		finalLatencySeconds.With(kind).Observe(time.Since(end).Seconds())
+	}
+}
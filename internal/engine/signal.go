@@ -0,0 +1,47 @@
+package engine
+
+import "time"
+
+// 录音过程中定期检查采集到的原始信号，发现麦克风静音、音量过低、削波等问题时发布警告，
+// 每种问题每次识别只警告一次。统计从本次识别开始累计，音量过低按 Options.Meter 的静音门限判断，见 audio.Stats.Problems。
+
+const (
+	firstSignalCheck = 500 * time.Millisecond // 开始录音后第一次检查的时间，尽早发现静音
+	signalInterval   = time.Second            // 之后每次检查的间隔
+)
+
+// signalCheck 一次识别的信号检查状态
+type signalCheck struct {
+	next   time.Time       // 下次检查的时间
+	warned map[string]bool // 已经警告过的问题
+}
+
+// checkSignal 到时间时检查信号质量，在采集回调中调用
+func (e *Engine) checkSignal() {
+	e.mutex.Lock()
+	now := time.Now()
+	if e.state != StateListening || now.Before(e.signal.next) {
+		e.mutex.Unlock()
+		return
+	}
+	e.signal.next = now.Add(signalInterval)
+	var warnings []string
+	for _, p := range e.capture.Problems() {
+		if e.signal.warned[p.Code] {
+			continue
+		}
+		if e.signal.warned == nil {
+			e.signal.warned = make(map[string]bool)
+		}
+		e.signal.warned[p.Code] = true
+		warnings = append(warnings, p.Message)
+	}
+	taskID := e.client.TaskID()
+	e.mutex.Unlock()
+
+	for _, w := range warnings {
+		e.logger.Warn(w, "task_id", taskID)
+		// 采集回调中不能等待订阅者
+		go e.publish(Event{Type: EventWarning, TaskID: taskID, Text: w})
+	}
+}
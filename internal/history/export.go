@@ -0,0 +1,66 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportFormats 支持的导出格式
+var ExportFormats = []string{"jsonl", "json", "csv", "txt"}
+
+// Export 将记录按指定格式写出
+func Export(w io.Writer, utterances []Utterance, format string) error {
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, u := range utterances {
+			if err := enc.Encode(u); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if utterances == nil {
+			utterances = []Utterance{}
+		}
+		return enc.Encode(utterances)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"time", "session_id", "task_id", "duration_ms", "device", "text", "audio"})
+		for _, u := range utterances {
+			cw.Write([]string{
+				u.Time.Format(time.RFC3339),
+				u.SessionID,
+				u.TaskID,
+				strconv.FormatInt(u.Duration, 10),
+				u.Device,
+				u.Text,
+				u.Audio,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "txt":
+		for _, u := range utterances {
+			if _, err := fmt.Fprintln(w, FormatLine(u)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// FormatLine 返回一条记录的单行文本表示
+func FormatLine(u Utterance) string {
+	return fmt.Sprintf("[%s] %s", u.Time.Local().Format("2006-01-02 15:04:05"), u.Text)
+}
@@ -0,0 +1,197 @@
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 识别历史使用仅追加的 JSONL 文件存储，每行一条 Utterance 记录。
+// 文件只追加不修改，进程崩溃时最多丢失正在写入的那一行。
+
+// Utterance 一次识别（一句话）的记录
+type Utterance struct {
+	SessionID string    `json:"session_id"`      // 会话ID，一次程序运行为一个会话
+	TaskID    string    `json:"task_id"`         // 识别服务返回的任务ID
+	Time      time.Time `json:"time"`            // 开始识别的时间
+	Duration  int64     `json:"duration"`        // 识别耗时（毫秒）
+	Device    string    `json:"device"`          // 采集设备名称
+	Text      string    `json:"text"`            // 最终识别文本
+	Audio     string    `json:"audio,omitempty"` // 录音归档文件路径，未开启归档时为空
+	// Segments 分句和词的时间信息，相对录音开始，可与录音归档对齐，识别服务不提供时为空
+	Segments []Segment `json:"segments,omitempty"`
+}
+
+// Segment 一句话的时间信息，时间为相对录音开始的毫秒数
+type Segment struct {
+	Begin      int     `json:"begin"`
+	End        int     `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Words      []Word  `json:"words,omitempty"`
+	Speaker    string  `json:"speaker,omitempty"` // 说话人，多声道识别时为声道标签
+}
+
+// Word 一个词的时间信息，时间为相对录音开始的毫秒数
+type Word struct {
+	Begin      int     `json:"begin"`
+	End        int     `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// Filter 查询条件，零值字段表示不限制
+type Filter struct {
+	From  time.Time // 起始时间（包含）
+	To    time.Time // 结束时间（不包含）
+	Query string    // 全文检索关键词，空格分隔的多个关键词需全部匹配
+	Limit int       // 最多返回最近的多少条
+}
+
+// Store 识别历史存储
+type Store struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// DefaultPath 返回默认的历史文件路径
+// 优先使用环境变量 VOICEWIN_HISTORY_FILE，否则放在用户配置目录下
+func DefaultPath() string {
+	if p := os.Getenv("VOICEWIN_HISTORY_FILE"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "history.jsonl"
+	}
+	return filepath.Join(dir, "voiceWin", "history.jsonl")
+}
+
+// NewSessionID 生成新的会话ID
+func NewSessionID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// Open 打开历史存储，文件不存在时会在首次写入时创建
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path 返回历史文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append 追加一条记录
+func (s *Store) Append(u Utterance) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建历史目录失败: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开历史文件失败: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("序列化历史记录失败: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入历史文件失败: %w", err)
+	}
+	return nil
+}
+
+// Query 按条件查询记录，结果按时间升序排列
+func (s *Store) Query(filter Filter) ([]Utterance, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开历史文件失败: %w", err)
+	}
+	defer f.Close()
+
+	terms := strings.Fields(strings.ToLower(filter.Query))
+	var result []Utterance
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var u Utterance
+		if err := json.Unmarshal(line, &u); err != nil {
+			return nil, fmt.Errorf("历史文件第%d行损坏: %v", lineNo, err)
+		}
+		if filter.match(u, terms) {
+			result = append(result, u)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取历史文件失败: %w", err)
+	}
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result, nil
+}
+
+// ParseDate 解析查询用的日期，支持 2006-01-02、2006-01-02 15:04 和 RFC3339
+// endOfDay 为 true 且只给出日期时返回次日零点，用作不包含的结束时间
+func ParseDate(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无法解析日期 %q", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func (f Filter) match(u Utterance, terms []string) bool {
+	if !f.From.IsZero() && u.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !u.Time.Before(f.To) {
+		return false
+	}
+	text := strings.ToLower(u.Text)
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
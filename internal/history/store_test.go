@@ -0,0 +1,105 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	store := Open(filepath.Join(t.TempDir(), "sub", "history.jsonl"))
+	base := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	texts := []string{"帮我完成任务。", "我是一个中国人。", "Hello World", "今天天气不错。"}
+	for i, text := range texts {
+		err := store.Append(Utterance{
+			SessionID: "s1",
+			TaskID:    "task" + string(rune('a'+i)),
+			Time:      base.Add(time.Duration(i) * 24 * time.Hour),
+			Duration:  1500,
+			Device:    "默认麦克风",
+			Text:      text,
+		})
+		if err != nil {
+			t.Fatalf("追加记录失败: %v", err)
+		}
+	}
+	return store
+}
+
+func TestStore_Query(t *testing.T) {
+	store := newTestStore(t)
+
+	all, err := store.Query(Filter{})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("期望4条记录，实际为%d", len(all))
+	}
+	if all[1].TaskID != "taskb" || all[1].Device != "默认麦克风" {
+		t.Errorf("记录内容不符合预期: %+v", all[1])
+	}
+
+	// 日期范围：12月2日（含）到12月4日（不含）
+	ranged, _ := store.Query(Filter{
+		From: time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 12, 4, 0, 0, 0, 0, time.UTC),
+	})
+	if len(ranged) != 2 || ranged[0].Text != "我是一个中国人。" {
+		t.Errorf("日期范围查询结果不符合预期: %+v", ranged)
+	}
+
+	// 全文检索，不区分大小写，多个关键词需全部匹配
+	found, _ := store.Query(Filter{Query: "hello world"})
+	if len(found) != 1 || found[0].Text != "Hello World" {
+		t.Errorf("全文检索结果不符合预期: %+v", found)
+	}
+	found, _ = store.Query(Filter{Query: "中国人 天气"})
+	if len(found) != 0 {
+		t.Errorf("多关键词检索应无结果，实际为%+v", found)
+	}
+
+	// Limit 返回最近的记录
+	limited, _ := store.Query(Filter{Limit: 1})
+	if len(limited) != 1 || limited[0].Text != "今天天气不错。" {
+		t.Errorf("Limit 查询结果不符合预期: %+v", limited)
+	}
+}
+
+func TestStore_QueryMissingFile(t *testing.T) {
+	store := Open(filepath.Join(t.TempDir(), "none.jsonl"))
+	result, err := store.Query(Filter{})
+	if err != nil || len(result) != 0 {
+		t.Errorf("文件不存在时应返回空结果，实际为%v, %v", result, err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	store := newTestStore(t)
+	all, _ := store.Query(Filter{})
+
+	var buf bytes.Buffer
+	if err := Export(&buf, all, "json"); err != nil {
+		t.Fatalf("导出json失败: %v", err)
+	}
+	var decoded []Utterance
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 4 {
+		t.Errorf("导出的json无法解析: %v", err)
+	}
+
+	buf.Reset()
+	if err := Export(&buf, all, "csv"); err != nil {
+		t.Fatalf("导出csv失败: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "time,") {
+		t.Errorf("导出的csv不符合预期: %s", buf.String())
+	}
+
+	if err := Export(&buf, all, "xml"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
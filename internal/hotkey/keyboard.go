@@ -1,9 +1,11 @@
 package hotkey
 
 import (
-	"log"
+	"github.com/shellus/voiceWin/internal/logging"
 )
 
+var logger = logging.Component(logging.Hotkey)
+
 // KeyboardInput 表示键盘输入器
 type KeyboardInput struct {
 	// 可以在这里添加配置参数
@@ -17,19 +19,19 @@ func NewKeyboardInput() *KeyboardInput {
 // TypeText 将文本输入到当前活动窗口
 func (ki *KeyboardInput) TypeText(text string) error {
 	// 模拟键盘输入
-	log.Printf("模拟键盘输入: %s", text)
+	logger.Info("模拟键盘输入", "text", text)
 	return nil
 }
 
 // PressKey 模拟按下指定键
 func (ki *KeyboardInput) PressKey(key string) error {
-	log.Printf("模拟按键: %s", key)
+	logger.Info("模拟按键", "key", key)
 	return nil
 }
 
 // PressKeyWithModifiers 模拟按下带修饰键的按键
 func (ki *KeyboardInput) PressKeyWithModifiers(key string, modifiers ...string) error {
-	log.Printf("模拟按键: %s 带修饰键: %v", key, modifiers)
+	logger.Info("模拟按键", "key", key, "modifiers", modifiers)
 	return nil
 }
 
@@ -41,18 +43,18 @@ func (ki *KeyboardInput) GetActiveWindow() string {
 
 // FocusWindow 聚焦到指定窗口
 func (ki *KeyboardInput) FocusWindow(title string) error {
-	log.Printf("模拟聚焦窗口: %s", title)
+	logger.Info("模拟聚焦窗口", "title", title)
 	return nil
 }
 
 // TypeWithDelay 以指定的延迟输入文本（每个字符之间有延迟）
 func (ki *KeyboardInput) TypeWithDelay(text string, delayMS int) error {
-	log.Printf("模拟延迟输入: %s (延迟: %dms)", text, delayMS)
+	logger.Info("模拟延迟输入", "text", text, "delay_ms", delayMS)
 	return nil
 }
 
 // PasteText 粘贴文本（使用剪贴板）
 func (ki *KeyboardInput) PasteText(text string) error {
-	log.Printf("模拟粘贴文本: %s", text)
+	logger.Info("模拟粘贴文本", "text", text)
 	return nil
-} 
\ No newline at end of file
+}
@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// 结构化日志：所有模块通过 Component 取得带 component 字段的 logger，
+// 输出到 Setup 设置的默认 slog handler。Setup 之后标准库 log 的输出也会转到同一个 handler，
+// 命令行参数错误等直接退出的场景仍可使用 log.Fatalf。
+
+// 模块名，作为日志的 component 字段
+const (
+	Capture     = "capture"
+	Recognition = "recognition"
+	NLS         = "nls" // 阿里云 NLS SDK 自身的日志
+	Engine      = "engine"
+	Hotkey      = "hotkey"
+	Archive     = "archive"
+	Server      = "server"
+	Transcribe  = "transcribe"
+	Output      = "output"
+)
+
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup 创建输出到 w 的 handler 并设为默认
+// level 为 debug、info、warn、error，format 为 text 或 json
+func Setup(w io.Writer, level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("无效的日志级别: %s", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("无效的日志格式: %s", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// Component 返回带 component 字段的 logger
+// 每条日志都使用写入时的默认 handler，可以在 Setup 之前创建，适合作为包级变量
+func Component(name string) *slog.Logger {
+	return slog.New(deferred{wrap: func(h slog.Handler) slog.Handler { return h }}).With("component", name)
+}
+
+// deferred 把日志转给当前的默认 handler
+type deferred struct {
+	wrap func(slog.Handler) slog.Handler // 在默认 handler 上附加的字段和分组
+}
+
+func (d deferred) handler() slog.Handler {
+	return d.wrap(slog.Default().Handler())
+}
+
+func (d deferred) Enabled(ctx context.Context, level slog.Level) bool {
+	return d.handler().Enabled(ctx, level)
+}
+
+func (d deferred) Handle(ctx context.Context, r slog.Record) error {
+	return d.handler().Handle(ctx, r)
+}
+
+func (d deferred) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return deferred{wrap: func(h slog.Handler) slog.Handler { return d.wrap(h).WithAttrs(attrs) }}
+}
+
+func (d deferred) WithGroup(name string) slog.Handler {
+	return deferred{wrap: func(h slog.Handler) slog.Handler { return d.wrap(h).WithGroup(name) }}
+}
+
+// Writer 把按行写入的文本转为 l 的日志，用于接入只支持 io.Writer 的第三方库
+func Writer(l *slog.Logger, level slog.Level) io.Writer {
+	return lineWriter{logger: l, level: level}
+}
+
+type lineWriter struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// Write 每次写入一行或多行，log.Logger 每条日志调用一次 Write
+func (w lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			w.logger.Log(context.Background(), w.level, line)
+		}
+	}
+	return len(p), nil
+}
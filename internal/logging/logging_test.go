@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+
+	// 包级变量在 Setup 之前创建
+	l := Component(Recognition).With("task_id", "t1")
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, "info", FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("不应输出")
+	l.Info("识别任务已开始")
+	log.Print("标准库日志")
+	Writer(Component(NLS), slog.LevelInfo).Write([]byte("send: {}\nconnect done\n"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("期望 4 行日志，实际:\n%s", buf.String())
+	}
+	var first map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first["component"] != Recognition || first["task_id"] != "t1" || first["msg"] != "识别任务已开始" {
+		t.Errorf("字段错误: %v", first)
+	}
+	if !strings.Contains(lines[1], "标准库日志") || !strings.Contains(lines[3], `"msg":"connect done","component":"nls"`) {
+		t.Errorf("输出错误:\n%s", buf.String())
+	}
+}
+
+func TestSetup_Invalid(t *testing.T) {
+	if err := Setup(&bytes.Buffer{}, "verbose", FormatText); err == nil {
+		t.Error("无效的级别应返回错误")
+	}
+	if err := Setup(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("无效的格式应返回错误")
+	}
+}
@@ -0,0 +1,354 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 运行指标：计数器和直方图，按 Prometheus 文本格式输出，
+// 用于观察采集回调和识别延迟，调整 MaxEndSilence、回调间隔等参数。
+// 只实现本项目用到的部分：最多一个标签，指标在包初始化时注册，不支持注销。
+
+// Registry 指标注册表，并发安全
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// NewRegistry 创建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default 默认注册表，各模块的指标都注册在这里
+var Default = NewRegistry()
+
+func (r *Registry) register(m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, old := range r.metrics {
+		if old.name() == m.name() {
+			panic("重复注册指标: " + m.name())
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText 按 Prometheus 文本格式输出所有指标
+func (r *Registry) WriteText(w io.Writer) {
+	r.mutex.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mutex.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler 返回输出所有指标的 HTTP 处理器
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	bits atomic.Uint64 // float64 的二进制表示
+}
+
+// Inc 加 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 增加 v，v 不能为负数
+func (c *Counter) Add(v float64) {
+	for {
+		old := c.bits.Load()
+		if c.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Value 当前值
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// Histogram 直方图，记录观测值落在各个区间的次数
+type Histogram struct {
+	buckets []float64       // 各区间的上界，升序
+	counts  []atomic.Uint64 // 落在各区间的次数（不累加），最后一个为 +Inf
+	count   atomic.Uint64
+	sum     Counter
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets)+1)}
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.buckets, v)].Add(1)
+	h.count.Add(1)
+	h.sum.Add(v)
+}
+
+// Count 观测次数
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// DefaultBuckets 默认的延迟区间（秒），覆盖 10ms 到 10s
+var DefaultBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10}
+
+// vec 按一个标签的值分开统计的一组指标
+type vec[T any] struct {
+	metricName, help, kind, label string
+	create                        func() *T
+	writeOne                      func(w io.Writer, name, labels string, m *T)
+
+	mutex  sync.Mutex
+	values map[string]*T
+}
+
+func (v *vec[T]) name() string { return v.metricName }
+
+// With 返回标签值对应的指标，不存在时创建
+func (v *vec[T]) With(value string) *T {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	m, ok := v.values[value]
+	if !ok {
+		m = v.create()
+		v.values[value] = m
+	}
+	return m
+}
+
+func (v *vec[T]) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.metricName, v.help, v.metricName, v.kind)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	values := make([]string, 0, len(v.values))
+	for value := range v.values {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		labels := ""
+		if v.label != "" {
+			labels = fmt.Sprintf("%s=%q", v.label, value)
+		}
+		v.writeOne(w, v.metricName, labels, v.values[value])
+	}
+}
+
+// CounterVec 按标签分开的计数器
+type CounterVec struct{ vec[Counter] }
+
+// HistogramVec 按标签分开的直方图
+type HistogramVec struct{ vec[Histogram] }
+
+// NewCounter 创建并注册计数器
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help, "").With("")
+}
+
+// NewCounterVec 创建并注册按 label 分开的计数器
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{vec[Counter]{
+		metricName: name, help: help, kind: "counter", label: label,
+		create:   func() *Counter { return &Counter{} },
+		writeOne: writeCounter,
+		values:   make(map[string]*Counter),
+	}}
+	r.register(v)
+	return v
+}
+
+// NewHistogram 创建并注册直方图，buckets 为 nil 时使用 DefaultBuckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	return r.NewHistogramVec(name, help, "", buckets).With("")
+}
+
+// NewHistogramVec 创建并注册按 label 分开的直方图，buckets 为 nil 时使用 DefaultBuckets
+func (r *Registry) NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	v := &HistogramVec{vec[Histogram]{
+		metricName: name, help: help, kind: "histogram", label: label,
+		create:   func() *Histogram { return newHistogram(buckets) },
+		writeOne: writeHistogram,
+		values:   make(map[string]*Histogram),
+	}}
+	r.register(v)
+	return v
+}
+
+func writeCounter(w io.Writer, name, labels string, c *Counter) {
+	fmt.Fprintf(w, "%s%s %s\n", name, braces(labels), formatFloat(c.Value()))
+}
+
+func writeHistogram(w io.Writer, name, labels string, h *Histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i].Load()
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, formatFloat(le), cumulative)
+	}
+	cumulative += h.counts[len(h.buckets)].Load()
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, cumulative)
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, braces(labels), formatFloat(h.sum.Value()))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, braces(labels), cumulative)
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Sample 文本格式中的一行数据
+type Sample struct {
+	Name   string            // 包括 _bucket、_sum、_count 后缀
+	Labels map[string]string // 不包括 le
+	LE     float64           // 直方图区间上界，其他为 0
+	Value  float64
+}
+
+// Family 同名的一组数据
+type Family struct {
+	Name    string
+	Help    string
+	Type    string // counter 或 histogram
+	Samples []Sample
+}
+
+// Parse 解析 WriteText 输出的文本格式，供 stats 命令读取正在运行的服务的指标
+func Parse(r io.Reader) ([]Family, error) {
+	var families []Family
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "# HELP "); ok {
+			name, help, _ := strings.Cut(rest, " ")
+			families = append(families, Family{Name: name, Help: help})
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			if name, kind, _ := strings.Cut(rest, " "); len(families) > 0 && families[len(families)-1].Name == name {
+				families[len(families)-1].Type = kind
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if len(families) == 0 {
+			return nil, fmt.Errorf("指标数据缺少 HELP 行: %s", line)
+		}
+		s, err := parseSample(line)
+		if err != nil {
+			return nil, err
+		}
+		f := &families[len(families)-1]
+		f.Samples = append(f.Samples, s)
+	}
+	return families, scanner.Err()
+}
+
+// parseSample 解析 name{k="v",le="0.1"} value 格式的一行
+func parseSample(line string) (Sample, error) {
+	s := Sample{Labels: make(map[string]string)}
+	head, value, ok := strings.Cut(line, " ")
+	if i := strings.LastIndex(line, "} "); i >= 0 {
+		head, value, ok = line[:i+1], line[i+2:], true
+	}
+	if !ok {
+		return s, fmt.Errorf("无效的指标数据: %s", line)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return s, fmt.Errorf("无效的指标数据: %s", line)
+	}
+	s.Value = v
+	name, labels, _ := strings.Cut(head, "{")
+	s.Name = name
+	labels = strings.TrimSuffix(labels, "}")
+	for labels != "" {
+		key, rest, ok := strings.Cut(labels, "=")
+		if !ok {
+			return s, fmt.Errorf("无效的指标标签: %s", line)
+		}
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return s, fmt.Errorf("无效的指标标签: %s", line)
+		}
+		value, _ := strconv.Unquote(quoted)
+		labels = strings.TrimPrefix(rest[len(quoted):], ",")
+		if key == "le" {
+			if s.LE, err = strconv.ParseFloat(value, 64); err != nil {
+				return s, fmt.Errorf("无效的直方图区间: %s", line)
+			}
+			continue
+		}
+		s.Labels[key] = value
+	}
+	return s, nil
+}
+
+// Quantile 由累计的区间计数估算分位数，区间内按线性插值，
+// 落在最后一个有限区间之外的按该区间上界计算
+// buckets 为 _bucket 数据，需按 LE 升序且包含 +Inf
+func Quantile(q float64, buckets []Sample) float64 {
+	if len(buckets) == 0 {
+		return math.NaN()
+	}
+	total := buckets[len(buckets)-1].Value
+	if total == 0 {
+		return math.NaN()
+	}
+	rank := q * total
+	lower, below := 0.0, 0.0
+	for _, b := range buckets {
+		if b.Value >= rank {
+			if math.IsInf(b.LE, 1) {
+				return lower
+			}
+			if b.Value == below {
+				return b.LE
+			}
+			return lower + (b.LE-lower)*(rank-below)/(b.Value-below)
+		}
+		lower, below = b.LE, b.Value
+	}
+	return lower
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	reg := NewRegistry()
+	frames := reg.NewCounter("test_frames_total", "帧数")
+	failures := reg.NewCounterVec("test_failures_total", "失败数", "code")
+	latency := reg.NewHistogramVec("test_latency_seconds", "延迟", "kind", []float64{0.1, 0.5, 1})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			frames.Add(160)
+		}()
+	}
+	wg.Wait()
+	failures.With("40000004").Inc()
+	failures.With("0").Add(2)
+	for _, v := range []float64{0.05, 0.3, 0.3, 0.8, 2} {
+		latency.With("aliyun-sentence").Observe(v)
+	}
+
+	var buf bytes.Buffer
+	reg.WriteText(&buf)
+	for _, want := range []string{
+		"# TYPE test_frames_total counter\ntest_frames_total 1600\n",
+		"test_failures_total{code=\"0\"} 2\ntest_failures_total{code=\"40000004\"} 1\n",
+		"test_latency_seconds_bucket{kind=\"aliyun-sentence\",le=\"0.5\"} 3\n",
+		"test_latency_seconds_bucket{kind=\"aliyun-sentence\",le=\"+Inf\"} 5\n",
+		"test_latency_seconds_sum{kind=\"aliyun-sentence\"} 3.45\n",
+		"test_latency_seconds_count{kind=\"aliyun-sentence\"} 5\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("输出中缺少 %q:\n%s", want, buf.String())
+		}
+	}
+
+	families, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 3 || families[1].Type != "counter" || families[2].Type != "histogram" {
+		t.Fatalf("解析结果错误: %+v", families)
+	}
+	if s := families[1].Samples[1]; s.Labels["code"] != "40000004" || s.Value != 1 {
+		t.Errorf("解析计数器错误: %+v", s)
+	}
+	var buckets []Sample
+	for _, s := range families[2].Samples {
+		if s.Name == "test_latency_seconds_bucket" {
+			buckets = append(buckets, s)
+		}
+	}
+	if len(buckets) != 4 || !math.IsInf(buckets[3].LE, 1) {
+		t.Fatalf("解析直方图错误: %+v", buckets)
+	}
+	// 第 2.5 个观测值落在 0.1~0.5 区间（累计 1~3）的 3/4 处
+	if q := Quantile(0.5, buckets); math.Abs(q-0.4) > 1e-9 {
+		t.Errorf("p50 期望 0.4，实际 %f", q)
+	}
+	// 落在 +Inf 区间的按最后一个有限上界计算
+	if q := Quantile(0.99, buckets); q != 1 {
+		t.Errorf("p99 期望 1，实际 %f", q)
+	}
+}
+
+func TestRegistry_Duplicate(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("test_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("重复注册应 panic")
+		}
+	}()
+	reg.NewCounter("test_total", "")
+}
@@ -0,0 +1,287 @@
+package output
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 实时字幕：用中间结果滚动显示最近两行字幕，一句话结束后保留在上一行，
+// 一段时间没有新的结果时淡出。内置一个很小的 HTTP 服务，网页可以直接作为 OBS 的浏览器源或置顶的透明窗口。
+//
+// 接口：
+//
+//	GET /         字幕网页，参数 size 为字号（像素），默认 40
+//	GET /state    当前字幕 CaptionState JSON
+//	GET /events   Server-Sent Events，字幕变化时推送 CaptionState JSON
+
+//go:embed caption.html
+var captionPage []byte
+
+// CaptionConfig 字幕配置
+type CaptionConfig struct {
+	Lines     int           // 显示的行数
+	LineWidth int           // 每行最多的字数，超过时换行，只保留最后 Lines 行
+	Hold      time.Duration // 最后一次更新后保持显示的时间，之后淡出
+	Fade      time.Duration // 淡出动画的时长，由网页执行
+}
+
+// DefaultCaptionConfig 返回默认配置：两行，每行 24 个字，保持 4 秒，淡出 1 秒
+func DefaultCaptionConfig() CaptionConfig {
+	return CaptionConfig{Lines: 2, LineWidth: 24, Hold: 4 * time.Second, Fade: time.Second}
+}
+
+// CaptionState 字幕的显示状态
+type CaptionState struct {
+	Lines   []string `json:"lines"`
+	Visible bool     `json:"visible"` // 为 false 时网页淡出，Lines 为淡出前的内容
+	FadeMs  int64    `json:"fade_ms"`
+}
+
+// Caption 实时字幕，实现 Sink 和 http.Handler
+type Caption struct {
+	cfg CaptionConfig
+	mux *http.ServeMux
+
+	mutex   sync.Mutex
+	prev    string // 上一句的最终结果
+	current string // 当前句的中间结果
+	state   CaptionState
+	timer   *time.Timer // 淡出计时
+	gen     uint64      // 每次更新加一，已经触发、正在等待 mutex 的淡出发现不一致时放弃
+	subs    map[chan CaptionState]struct{}
+	server  *http.Server
+	closed  bool
+}
+
+// NewCaption 创建实时字幕，配置中为 0 的字段使用默认值
+func NewCaption(cfg CaptionConfig) *Caption {
+	def := DefaultCaptionConfig()
+	if cfg.Lines <= 0 {
+		cfg.Lines = def.Lines
+	}
+	if cfg.LineWidth <= 0 {
+		cfg.LineWidth = def.LineWidth
+	}
+	if cfg.Hold <= 0 {
+		cfg.Hold = def.Hold
+	}
+	if cfg.Fade <= 0 {
+		cfg.Fade = def.Fade
+	}
+	c := &Caption{
+		cfg:   cfg,
+		mux:   http.NewServeMux(),
+		state: CaptionState{Lines: []string{}, FadeMs: cfg.Fade.Milliseconds()},
+		subs:  make(map[chan CaptionState]struct{}),
+	}
+	c.mux.HandleFunc("GET /{$}", c.handlePage)
+	c.mux.HandleFunc("GET /state", c.handleState)
+	c.mux.HandleFunc("GET /events", c.handleEvents)
+	return c
+}
+
+// Listen 在 addr 上提供字幕网页，监听失败时返回错误，Close 时停止
+func (c *Caption) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("字幕服务监听 %s 失败: %w", addr, err)
+	}
+	srv := &http.Server{Handler: c}
+	c.mutex.Lock()
+	c.server = srv
+	c.mutex.Unlock()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("字幕服务已停止", "error", err)
+		}
+	}()
+	logger.Info("字幕网页已启动", "addr", "http://"+ln.Addr().String())
+	return nil
+}
+
+// ServeHTTP 实现 http.Handler
+func (c *Caption) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+// Partial 用中间结果更新当前行
+func (c *Caption) Partial(text string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.current = text
+	c.update()
+}
+
+// Final 当前句结束，移到上一行，下一句从新的一行开始
+func (c *Caption) Final(text string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if strings.TrimSpace(text) == "" {
+		// 没有识别到声音，去掉当前句的中间结果，之前的字幕保留到淡出
+		c.current = ""
+		c.update()
+		return nil
+	}
+	c.prev, c.current = text, ""
+	c.update()
+	return nil
+}
+
+// State 返回当前字幕
+func (c *Caption) State() CaptionState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.state
+}
+
+// Close 停止淡出计时和字幕服务，断开所有网页的连接
+func (c *Caption) Close() error {
+	c.mutex.Lock()
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	for ch := range c.subs {
+		close(ch)
+	}
+	c.subs = make(map[chan CaptionState]struct{})
+	srv := c.server
+	c.mutex.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// update 重新排版字幕并重置淡出计时，调用方需持有 mutex
+func (c *Caption) update() {
+	if c.closed {
+		return
+	}
+	var lines []string
+	for _, text := range []string{c.prev, c.current} {
+		lines = append(lines, wrap(text, c.cfg.LineWidth)...)
+	}
+	if len(lines) > c.cfg.Lines {
+		lines = lines[len(lines)-c.cfg.Lines:]
+	}
+	if lines == nil {
+		lines = []string{}
+	}
+	c.broadcast(CaptionState{Lines: lines, Visible: len(lines) > 0, FadeMs: c.cfg.Fade.Milliseconds()})
+
+	// Stop 无法取消已经触发的淡出，由 gen 判断
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.gen++
+	gen := c.gen
+	c.timer = time.AfterFunc(c.cfg.Hold, func() { c.fade(gen) })
+}
+
+// fade 一段时间没有更新，淡出字幕，下一句从空白开始；gen 不是最新的更新时说明期间有新的字幕
+func (c *Caption) fade(gen uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed || gen != c.gen || !c.state.Visible {
+		return
+	}
+	c.prev, c.current = "", ""
+	s := c.state
+	s.Visible = false
+	c.broadcast(s)
+}
+
+// broadcast 保存并推送字幕，网页来不及接收时只保留最新的状态，调用方需持有 mutex
+func (c *Caption) broadcast(s CaptionState) {
+	c.state = s
+	for ch := range c.subs {
+		select {
+		case ch <- s:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- s
+		}
+	}
+}
+
+// wrap 按每行最多 width 个字拆分文本，原有的换行保留
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		runes := []rune(strings.TrimSpace(line))
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		if len(runes) > 0 {
+			lines = append(lines, string(runes))
+		}
+	}
+	return lines
+}
+
+func (c *Caption) handlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(captionPage)
+}
+
+func (c *Caption) handleState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.State())
+}
+
+func (c *Caption) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持推送", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ch := make(chan CaptionState, 1)
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	c.subs[ch] = struct{}{}
+	ch <- c.state
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		delete(c.subs, ch)
+		c.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		case s, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(s)
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/command"
+)
+
+// failingSink Final 总是返回错误
+type failingSink struct{ finals []string }
+
+func (f *failingSink) Partial(text string) {}
+func (f *failingSink) Final(text string) error {
+	f.finals = append(f.finals, text)
+	return errors.New("失败")
+}
+func (f *failingSink) Close() error { return nil }
+
+func TestMulti(t *testing.T) {
+	var out, dry bytes.Buffer
+	failing := &failingSink{}
+	keyboard := NewKeyboard(command.NewInterpreter(command.DefaultRules()), nil, &dry)
+	m := Multi{failing, NewConsole(&out), keyboard}
+	if err := m.Final("你好，换行"); err == nil {
+		t.Error("应返回失败的 Sink 的错误")
+	}
+	if len(failing.finals) != 1 || !strings.Contains(out.String(), "识别结果: 你好，换行") {
+		t.Errorf("一个 Sink 失败不应影响其他 Sink: %q", out.String())
+	}
+	if !strings.Contains(dry.String(), "1. ") || !strings.Contains(dry.String(), "2. ") {
+		t.Errorf("dry-run 应打印动作列表: %q", dry.String())
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.now = func() time.Time { return time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local) }
+	f.Partial("忽略")
+	f.Final("A: 你好\nB: 请坐")
+	f.Close()
+
+	data, _ := os.ReadFile(path)
+	if want := "[2024-05-01 09:30:00] A: 你好\n[2024-05-01 09:30:00] B: 请坐\n"; string(data) != want {
+		t.Errorf("文件内容 %q，期望 %q", data, want)
+	}
+}
+
+func TestCaption_Lines(t *testing.T) {
+	c := NewCaption(CaptionConfig{LineWidth: 4, Hold: time.Hour})
+	defer c.Close()
+
+	c.Partial("今天")
+	if s := c.State(); !slices.Equal(s.Lines, []string{"今天"}) || !s.Visible {
+		t.Errorf("中间结果: %+v", s)
+	}
+	c.Final("今天天气很好")
+	if s := c.State(); !slices.Equal(s.Lines, []string{"今天天气", "很好"}) {
+		t.Errorf("超过行宽应换行: %+v", s)
+	}
+	// 新的一句从新的一行开始，只保留最后两行
+	c.Partial("出去")
+	if s := c.State(); !slices.Equal(s.Lines, []string{"很好", "出去"}) {
+		t.Errorf("应滚动显示最后两行: %+v", s)
+	}
+}
+
+func TestCaption_Fade(t *testing.T) {
+	c := NewCaption(CaptionConfig{Hold: 50 * time.Millisecond, Fade: 300 * time.Millisecond})
+	defer c.Close()
+
+	c.Final("你好")
+	time.Sleep(150 * time.Millisecond)
+	s := c.State()
+	if s.Visible || !slices.Equal(s.Lines, []string{"你好"}) || s.FadeMs != 300 {
+		t.Errorf("应淡出并保留原来的文字: %+v", s)
+	}
+	// 淡出后的下一句不再显示上一句
+	c.Partial("再见")
+	if s := c.State(); !s.Visible || !slices.Equal(s.Lines, []string{"再见"}) {
+		t.Errorf("淡出后的下一句: %+v", s)
+	}
+	// 没有识别到声音时去掉中间结果
+	c.Final("")
+	if s := c.State(); s.Visible || len(s.Lines) != 0 {
+		t.Errorf("空的最终结果应清除中间结果: %+v", s)
+	}
+
+	// 已经触发的旧淡出不应清除新的字幕
+	c.Partial("新的一句")
+	c.mutex.Lock()
+	gen := c.gen
+	c.mutex.Unlock()
+	c.fade(gen - 1)
+	if s := c.State(); !s.Visible || !slices.Equal(s.Lines, []string{"新的一句"}) {
+		t.Errorf("过期的淡出不应生效: %+v", s)
+	}
+}
+
+func TestCaption_HTTP(t *testing.T) {
+	c := NewCaption(CaptionConfig{Hold: time.Hour})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	defer c.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("字幕网页: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := make(chan CaptionState, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var s CaptionState
+				json.Unmarshal([]byte(data), &s)
+				events <- s
+			}
+		}
+		close(events)
+	}()
+	next := func() CaptionState {
+		t.Helper()
+		select {
+		case s := <-events:
+			return s
+		case <-time.After(time.Second):
+			t.Fatal("等待字幕推送超时")
+		}
+		return CaptionState{}
+	}
+
+	if s := next(); s.Visible || len(s.Lines) != 0 {
+		t.Errorf("连接后先推送当前字幕: %+v", s)
+	}
+	c.Partial("你好")
+	if s := next(); !s.Visible || !slices.Equal(s.Lines, []string{"你好"}) {
+		t.Errorf("中间结果推送: %+v", s)
+	}
+
+	var state CaptionState
+	resp2, err := http.Get(srv.URL + "/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp2.Body).Decode(&state)
+	resp2.Body.Close()
+	if !slices.Equal(state.Lines, []string{"你好"}) {
+		t.Errorf("/state: %+v", state)
+	}
+
+	// Close 断开推送连接
+	c.Close()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Close 后不应再推送")
+		}
+	case <-time.After(time.Second):
+		t.Error("Close 后推送连接没有断开")
+	}
+}
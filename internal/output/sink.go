@@ -0,0 +1,191 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/command"
+	"github.com/shellus/voiceWin/internal/logging"
+)
+
+var logger = logging.Component(logging.Output)
+
+// 识别结果的输出：打印到控制台、作为语音命令模拟键盘输入、复制到剪贴板、追加到文件，或者显示为实时字幕。
+// 一次识别可以同时输出到多个 Sink，中间结果只有需要实时显示的 Sink（字幕）使用。
+
+// 输出类型，命令行 -output 参数的取值
+const (
+	KindConsole   = "console"   // 打印到控制台
+	KindKeyboard  = "keyboard"  // 解释语音命令并模拟键盘输入
+	KindClipboard = "clipboard" // 复制到剪贴板
+	KindFile      = "file"      // 追加到文件
+	KindCaption   = "caption"   // 实时字幕网页
+)
+
+// Sink 识别结果的输出
+type Sink interface {
+	// Partial 中间识别结果，同一句话会多次更新，应尽快返回
+	Partial(text string)
+	// Final 最终识别结果，一句话识别为整段结果，实时语音识别为每一句
+	Final(text string) error
+	// Close 释放资源，之后不再调用 Partial 和 Final
+	Close() error
+}
+
+// Multi 把识别结果依次交给多个 Sink，某个 Sink 出错不影响其他 Sink
+type Multi []Sink
+
+func (m Multi) Partial(text string) {
+	for _, s := range m {
+		s.Partial(text)
+	}
+}
+
+func (m Multi) Final(text string) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Final(text); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m Multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Console 把最终结果打印到 w
+type Console struct {
+	w io.Writer
+}
+
+// NewConsole 创建控制台输出
+func NewConsole(w io.Writer) *Console {
+	return &Console{w: w}
+}
+
+func (c *Console) Partial(text string) {}
+
+func (c *Console) Final(text string) error {
+	_, err := fmt.Fprintf(c.w, "\n识别结果: %s\n", text)
+	return err
+}
+
+func (c *Console) Close() error { return nil }
+
+// Keyboard 把最终结果解释为语音命令，通过键盘输入到当前窗口
+type Keyboard struct {
+	interpreter *command.Interpreter
+	executor    *command.Executor
+	dryRun      io.Writer // 不为 nil 时只把动作列表打印到这里，不执行
+}
+
+// NewKeyboard 创建键盘输入，dryRun 不为 nil 时只打印解释出的动作列表
+func NewKeyboard(interpreter *command.Interpreter, executor *command.Executor, dryRun io.Writer) *Keyboard {
+	return &Keyboard{interpreter: interpreter, executor: executor, dryRun: dryRun}
+}
+
+func (k *Keyboard) Partial(text string) {}
+
+func (k *Keyboard) Final(text string) error {
+	actions := k.interpreter.Interpret(text)
+	if k.dryRun != nil {
+		for i, a := range actions {
+			fmt.Fprintf(k.dryRun, "  %d. %s\n", i+1, a)
+		}
+		return nil
+	}
+	if err := k.executor.Execute(actions); err != nil {
+		return fmt.Errorf("执行语音命令失败: %w", err)
+	}
+	return nil
+}
+
+func (k *Keyboard) Close() error { return nil }
+
+// Clipboard 把最终结果复制到剪贴板，使用系统自带的命令
+// Windows 为 clip，macOS 为 pbcopy，Linux 依次尝试 wl-copy、xclip、xsel
+type Clipboard struct {
+	command []string
+}
+
+// clipboardCommands 各平台写剪贴板的命令，从标准输入读取文本
+var clipboardCommands = map[string][][]string{
+	"windows": {{"clip"}},
+	"darwin":  {{"pbcopy"}},
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}},
+}
+
+// NewClipboard 查找当前平台可用的剪贴板命令，找不到时返回错误
+func NewClipboard() (*Clipboard, error) {
+	for _, c := range clipboardCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return &Clipboard{command: c}, nil
+		}
+	}
+	return nil, fmt.Errorf("找不到剪贴板命令（%s）", runtime.GOOS)
+}
+
+func (c *Clipboard) Partial(text string) {}
+
+func (c *Clipboard) Final(text string) error {
+	cmd := exec.Command(c.command[0], c.command[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("复制到剪贴板失败: %w %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (c *Clipboard) Close() error { return nil }
+
+// File 把最终结果追加到文件，每行一条，带有时间
+type File struct {
+	mutex sync.Mutex
+	f     *os.File
+	now   func() time.Time
+}
+
+// OpenFile 以追加方式打开输出文件，不存在时创建
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开输出文件失败: %w", err)
+	}
+	return &File{f: f, now: time.Now}, nil
+}
+
+func (f *File) Partial(text string) {}
+
+func (f *File) Final(text string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	// 多声道识别的结果有多行，每行单独记录
+	for _, line := range strings.Split(text, "\n") {
+		if _, err := fmt.Fprintf(f.f, "[%s] %s\n", f.now().Format("2006-01-02 15:04:05"), line); err != nil {
+			return fmt.Errorf("写入输出文件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+func (f *File) Close() error {
+	return f.f.Close()
+}
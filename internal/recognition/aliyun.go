@@ -1,50 +1,120 @@
 package recognition
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	nls "github.com/aliyun/alibabacloud-nls-go-sdk"
+	"github.com/shellus/voiceWin/internal/logging"
 )
 
 // 阿里云Go SDK 文档：https://help.aliyun.com/zh/isi/developer-reference/sdk-for-go-1
-// 生命周期：
-// NewAliyunClient 创建阿里云语音识别客户端【无状态】
-// StartRecognition 【需要无连接状态】开始语音识别WS连接【开始连接】
-// SendAudioData 【需要连接状态】发送音频数据
-// StopRecognition 【需要连接状态】停止语音识别，等待识别结果，然后【连接断开】
-// ShutdownRecognition 【需要连接状态】关闭语音识别实例，不等待识别结果，立即【连接断开】
+// 生命周期（状态定义见 state.go）：
+// NewAliyunClient 创建阿里云语音识别客户端【Idle】
+// StartRecognition 【需要 Idle/Closed/Failed】开始语音识别WS连接【Connecting -> Streaming】
+// SendAudioData 【需要 Streaming】发送音频数据
+// StopRecognition 【需要 Streaming】停止语音识别，等待识别结果，然后断开连接【Finishing -> Closed】
+// ShutdownRecognition 【任意状态】关闭语音识别实例，不等待识别结果，立即断开连接【Closed】
 
 // StartRecognition->SendAudioData->StopRecognition 是一次识别的周期
 //
 // 注意：WS不可以长期连接，不识别时需要断开，它的最大空闲连接时间是60秒
+//
+// 超时与取消：
+// Start/Stop/SendAudioData 都有接受 context.Context 的版本，ctx 取消或超时后会关闭连接并返回 ctx.Err()。
+// 不带 ctx 的版本使用 context.Background()，但仍受 Timeouts 约束，识别服务无响应时不会永远阻塞。
+// ShutdownRecognition 只关闭连接，本身不会阻塞。
+
+// speechRecognizer 对 nls.SpeechRecognition 的抽象，便于测试时替换
+type speechRecognizer interface {
+	Start(param nls.SpeechRecognitionStartParam, extra map[string]interface{}) (chan bool, error)
+	Stop() (chan bool, error)
+	Shutdown()
+	SendAudioData(data []byte) error
+}
 
 type StartParam struct {
 	// 5个SDK参数
-	Format                         string // 音频格式:PCM、WAV、OPUS、SPEEX、AMR、MP3、AAC。
-	SampleRate                     int    // 采样率:8000、16000 两种
-	EnableIntermediateResult       bool   // 是否返回中间识别结果
-	EnablePunctuationPrediction    bool   // 是否在后处理中添加标点
-	EnableInverseTextNormalization bool   // 中文数字将转为阿拉伯数字输出
+	Format                         string `json:"format"`                            // 音频格式:PCM、WAV、OPUS、SPEEX、AMR、MP3、AAC。
+	SampleRate                     int    `json:"sample_rate"`                       // 采样率:8000、16000 两种
+	EnableIntermediateResult       bool   `json:"enable_intermediate_result"`        // 是否返回中间识别结果
+	EnablePunctuationPrediction    bool   `json:"enable_punctuation_prediction"`     // 是否在后处理中添加标点
+	EnableInverseTextNormalization bool   `json:"enable_inverse_text_normalization"` // 中文数字将转为阿拉伯数字输出
 	// 4个自定义参数（API文档上的）
-	DisableDisfluency    bool // disfluency 是否去除口语中的非正式表达(嗯嗯啊啊的语气词)
-	EnableVoiceDetection bool // enable_voice_detection 是否开启语音检测
-	MaxStartSilence      int  // max_start_silence 表示允许的最大开始静音时长
-	MaxEndSilence        int  // max_end_silence 表示允许的最大结束静音时长
+	DisableDisfluency    bool `json:"disfluency"`             // disfluency 是否去除口语中的非正式表达(嗯嗯啊啊的语气词)
+	EnableVoiceDetection bool `json:"enable_voice_detection"` // enable_voice_detection 是否开启语音检测
+	MaxStartSilence      int  `json:"max_start_silence"`      // max_start_silence 表示允许的最大开始静音时长
+	MaxEndSilence        int  `json:"max_end_silence"`        // max_end_silence 表示允许的最大结束静音时长
+	// 识别模式，见 ModeSentence、ModeTranscription
+	Mode               string `json:"mode"`
+	MaxSentenceSilence int    `json:"max_sentence_silence"` // 实时语音识别的断句静音时长，200~2000毫秒
+	EnableWords        bool   `json:"enable_words"`         // 返回词的时间信息，用于生成字幕和对齐录音
+}
+
+// 识别模式
+const (
+	ModeSentence      = "sentence"      // 一句话识别：说完一句自动结束，最长60秒
+	ModeTranscription = "transcription" // 实时语音识别：持续识别并按句返回，没有60秒限制，需要主动停止
+)
+
+// Timeouts 识别各阶段的超时时间，为 0 的字段使用默认值
+type Timeouts struct {
+	Connect time.Duration `json:"connect"` // 从开始连接到识别服务确认开始识别
+	Stop    time.Duration `json:"stop"`    // 从停止发送音频到收到最终结果
+	Session time.Duration `json:"session"` // 一次识别的最长时间，超过后强制关闭连接并报错，只对一句话识别生效
+}
+
+// DefaultTimeouts 默认的超时时间
+// 一句话识别的音频最长60秒，Session 在此基础上留出等待最终结果的时间
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Connect: 10 * time.Second,
+		Stop:    10 * time.Second,
+		Session: 90 * time.Second,
+	}
+}
+
+// withDefaults 用默认值填充为 0 的字段
+func (t Timeouts) withDefaults() Timeouts {
+	d := DefaultTimeouts()
+	if t.Connect <= 0 {
+		t.Connect = d.Connect
+	}
+	if t.Stop <= 0 {
+		t.Stop = d.Stop
+	}
+	if t.Session <= 0 {
+		t.Session = d.Session
+	}
+	return t
 }
 
 type AliyunClient struct {
-	config        *AliyunConfig
-	startParam    *StartParam
-	resultChan    chan string
-	completeChan  chan string
-	errorChan     chan error
-	stopChan      chan struct{}
-	isRecognizing bool       // 正在识别中
-	mutex         sync.Mutex // 识别切换锁
+	config       *AliyunConfig
+	startParam   *StartParam
+	timeouts     Timeouts
+	format       string // SetFormat 设置的音频格式，为空时使用 startParam.Format
+	resultChan   chan string
+	completeChan chan string
+	errorChan    chan error
+	state        *stateMachine // 会话状态机
+	taskID       atomic.Value  // 当前识别任务ID(string)，在回调中更新
+	watch        bool          // 是否限制一次识别的最长时间（Timeouts.Session）
+
+	sentenceMutex sync.Mutex
+	sentences     []Sentence // 本次识别的分句结果，在回调中追加
 
-	sr     *nls.SpeechRecognition
-	logger *nls.NlsLogger
+	// shutdown 在每次 Start 时重新创建，ShutdownRecognition 时关闭，
+	// 用于唤醒正在等待 ready 的 Start/Stop，避免连接被关闭后永远等待
+	shutdownMutex sync.Mutex
+	shutdown      chan struct{}
+
+	sr        speechRecognizer
+	nlsLogger *nls.NlsLogger
 }
 
 // AliyunConfig 阿里云配置
@@ -67,24 +137,57 @@ func DefaultStartParam() *StartParam {
 		EnableVoiceDetection:           true,
 		MaxStartSilence:                5000,
 		MaxEndSilence:                  3000,
+		Mode:                           ModeSentence,
+		MaxSentenceSilence:             800,
 	}
 }
 
-// NewAliyunClient 创建新的阿里云语音识别客户端
+// NewAliyunClient 创建新的阿里云语音识别客户端（一句话识别）
 func NewAliyunClient(cfg *AliyunConfig, startParam *StartParam) (*AliyunClient, error) {
+	ac := newAliyunClient(cfg, startParam)
+	ac.watch = true
+
+	config, err := ac.connectionConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	ac.sr, err = nls.NewSpeechRecognition(config, ac.nlsLogger,
+		ac.onTaskFailed, ac.onStarted, ac.onResultChanged,
+		ac.onCompleted, ac.onClose, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建语音识别实例失败: %v", err)
+	}
+
+	return ac, nil
+}
+
+// newAliyunClient 创建客户端，sr 由调用方设置
+func newAliyunClient(cfg *AliyunConfig, startParam *StartParam) *AliyunClient {
 	ac := &AliyunClient{
-		config:        cfg,
-		startParam:    startParam,
-		resultChan:    make(chan string, 10),
-		completeChan:  make(chan string, 10),
-		errorChan:     make(chan error, 10),
-		stopChan:      make(chan struct{}),
-		isRecognizing: false,
-		logger:        nls.DefaultNlsLog(),
-	}
-	ac.logger.SetLogSil(true)
-
-	// 创建阿里云NLS客户端配置
+		config:       cfg,
+		startParam:   startParam,
+		resultChan:   make(chan string, 10),
+		completeChan: make(chan string, 10),
+		errorChan:    make(chan error, 10),
+		timeouts:     DefaultTimeouts(),
+		state:        newStateMachine(),
+		shutdown:     make(chan struct{}),
+		nlsLogger:    newNLSLogger(),
+	}
+	return ac
+}
+
+// newNLSLogger 把 NLS SDK 的日志转到 slog，SDK 的日志（发送的指令、连接状态等）都记为 debug 级别
+func newNLSLogger() *nls.NlsLogger {
+	l := logging.Component(logging.NLS)
+	nl := nls.NewNlsLogger(logging.Writer(l, slog.LevelDebug), "", 0)
+	nl.SetDebug(l.Enabled(context.Background(), slog.LevelDebug))
+	return nl
+}
+
+// connectionConfig 创建阿里云NLS客户端配置，会请求获取访问令牌
+func (ac *AliyunClient) connectionConfig() (*nls.ConnectionConfig, error) {
 	wsUrl := fmt.Sprintf("wss://nls-gateway-%s.aliyuncs.com/ws/v1", ac.config.Region)
 	config, err := nls.NewConnectionConfigWithAKInfoDefault(
 		wsUrl,
@@ -95,95 +198,229 @@ func NewAliyunClient(cfg *AliyunConfig, startParam *StartParam) (*AliyunClient,
 	if err != nil {
 		return nil, fmt.Errorf("创建连接配置失败: %v", err)
 	}
+	return config, nil
+}
 
-	ac.sr, err = nls.NewSpeechRecognition(config, ac.logger,
-		ac.onTaskFailed, ac.onStarted, ac.onResultChanged,
-		ac.onCompleted, ac.onClose, ac.logger)
-	if err != nil {
-		return nil, fmt.Errorf("创建语音识别实例失败: %v", err)
+// SetTimeouts 修改超时时间，下次识别生效
+func (ac *AliyunClient) SetTimeouts(t Timeouts) {
+	ac.shutdownMutex.Lock()
+	defer ac.shutdownMutex.Unlock()
+	ac.timeouts = t.withDefaults()
+}
+
+// NativeFormats 阿里云能直接识别的压缩格式，识别服务还支持 SPEEX、AMR、AAC，目前只用到这两种
+func (ac *AliyunClient) NativeFormats() []string {
+	return []string{FormatOpus, FormatMP3}
+}
+
+// SetFormat 设置之后识别任务的音频格式，下次识别生效
+func (ac *AliyunClient) SetFormat(format string) {
+	ac.shutdownMutex.Lock()
+	defer ac.shutdownMutex.Unlock()
+	ac.format = format
+}
+
+// audioFormat 本次识别的音频格式
+func (ac *AliyunClient) audioFormat() string {
+	ac.shutdownMutex.Lock()
+	defer ac.shutdownMutex.Unlock()
+	if ac.format != "" {
+		return ac.format
 	}
+	return ac.startParam.Format
+}
 
-	return ac, nil
+// Timeouts 返回当前的超时时间
+func (ac *AliyunClient) Timeouts() Timeouts {
+	ac.shutdownMutex.Lock()
+	defer ac.shutdownMutex.Unlock()
+	return ac.timeouts
 }
 
 // StartRecognition 开始语音识别
 func (ac *AliyunClient) StartRecognition() error {
-	ac.mutex.Lock()
-	defer ac.mutex.Unlock()
+	return ac.StartRecognitionContext(context.Background())
+}
 
-	if ac.isRecognizing {
-		return fmt.Errorf("StartRecognition 重复启动")
+// StartRecognitionContext 开始语音识别，等待识别服务确认开始
+// ctx 取消或超过 Timeouts.Connect 时关闭连接并返回错误；
+// 识别开始后 ctx 不再影响本次识别，整个识别受 Timeouts.Session 约束
+func (ac *AliyunClient) StartRecognitionContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("StartRecognition 已取消: %w", err)
+	}
+	if err := ac.state.Transition(StateConnecting); err != nil {
+		return fmt.Errorf("StartRecognition 重复启动: %w", err)
 	}
+	shutdown := ac.resetShutdown()
+	ac.sentenceMutex.Lock()
+	ac.sentences = nil
+	ac.sentenceMutex.Unlock()
+	timeouts := ac.Timeouts()
+	ctx, cancel := context.WithTimeout(ctx, timeouts.Connect)
+	defer cancel()
+
 	nlsStartParam := nls.SpeechRecognitionStartParam{
-		Format:                         ac.startParam.Format,
+		Format:                         ac.audioFormat(),
 		SampleRate:                     ac.startParam.SampleRate,
 		EnableIntermediateResult:       ac.startParam.EnableIntermediateResult,
 		EnablePunctuationPrediction:    ac.startParam.EnablePunctuationPrediction,
 		EnableInverseTextNormalization: ac.startParam.EnableInverseTextNormalization,
 	}
 
-	// 启动识别
+	// 启动识别，SDK 在这里同步建立WS连接，握手自带10秒超时
 	ready, err := ac.sr.Start(nlsStartParam, map[string]interface{}{
 		"disfluency":             ac.startParam.DisableDisfluency,
 		"enable_voice_detection": ac.startParam.EnableVoiceDetection,
 		"max_start_silence":      ac.startParam.MaxStartSilence,
 		"max_end_silence":        ac.startParam.MaxEndSilence,
+		"enable_words":           ac.startParam.EnableWords,
 	})
 
 	if err != nil {
+		ac.state.TransitionFrom(StateFailed, StateConnecting)
 		return fmt.Errorf("StartRecognition Start失败: %v", err)
 	}
 
 	// 是否完成连接就看这个ready
-	ac.isRecognizing = <-ready
-	if !ac.isRecognizing {
+	ok := false
+	select {
+	case ok = <-ready:
+	case <-shutdown:
+	case <-ctx.Done():
+		ac.ShutdownRecognition()
+		return fmt.Errorf("StartRecognition 等待识别开始失败: %w", ctx.Err())
+	}
+	if !ok {
+		ac.state.TransitionFrom(StateFailed, StateConnecting)
 		return fmt.Errorf("StartRecognition WS连接失败")
 	}
+	if !ac.state.TransitionFrom(StateStreaming, StateConnecting) {
+		// 连接期间被 ShutdownRecognition 关闭或任务失败
+		return fmt.Errorf("StartRecognition 连接期间识别被关闭(%s)", ac.state.Current())
+	}
+	if ac.watch {
+		go ac.watchSession(shutdown, timeouts.Session)
+	}
 	return nil
 }
 
 // SendAudioData 发送音频数据
 func (ac *AliyunClient) SendAudioData(data []byte) error {
-	ac.mutex.Lock()
-	defer ac.mutex.Unlock()
+	return ac.SendAudioDataContext(context.Background(), data)
+}
 
-	if !ac.isRecognizing {
+// SendAudioDataContext 发送音频数据，ctx 已取消时不再发送
+func (ac *AliyunClient) SendAudioDataContext(ctx context.Context, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("发送音频数据已取消: %w", err)
+	}
+	if ac.state.Current() != StateStreaming {
 		return fmt.Errorf("语音识别未连接")
 	}
 
 	return ac.sr.SendAudioData(data)
 }
 
-// StopRecognition 停止语音识别任务
+// StopRecognition 停止语音识别任务，等待识别结果后断开连接
+// 等待期间不持有任何锁，可以与 ShutdownRecognition 并发调用
 func (ac *AliyunClient) StopRecognition() error {
-	ac.mutex.Lock()
-	defer ac.mutex.Unlock()
+	return ac.StopRecognitionContext(context.Background())
+}
 
-	if !ac.isRecognizing {
+// StopRecognitionContext 停止语音识别任务，等待识别结果后断开连接
+// ctx 取消或超过 Timeouts.Stop 时直接断开连接并返回错误，最终结果会丢失
+func (ac *AliyunClient) StopRecognitionContext(ctx context.Context) error {
+	if !ac.state.TransitionFrom(StateFinishing, StateStreaming) {
+		// 未在识别中，或识别已经完成/正在停止
 		return nil
 	}
+	shutdown := ac.currentShutdown()
+	ctx, cancel := context.WithTimeout(ctx, ac.Timeouts().Stop)
+	defer cancel()
 
 	// 停止识别并等待结果
 	ready, err := ac.sr.Stop()
 	if err != nil {
+		ac.ShutdownRecognition()
 		return fmt.Errorf("停止语音识别失败: %v", err)
 	}
 
 	// 这里是等待识别完成事件或任务失败事件，我们不期望在这里得到这个结果。
-	<-ready
+	select {
+	case <-ready:
+	case <-shutdown:
+	case <-ctx.Done():
+		ac.ShutdownRecognition()
+		return fmt.Errorf("等待识别结果失败: %w", ctx.Err())
+	}
 	// 停止并关闭连接
-	ac.sr.Shutdown()
-	ac.isRecognizing = false
+	ac.ShutdownRecognition()
 	return nil
 }
 
-// Close 关闭连接
-func (ac *AliyunClient) ShutdownRecognition() {
-	ac.mutex.Lock()
-	defer ac.mutex.Unlock()
+// TaskID 返回最近一次识别任务的ID，识别开始前为空
+func (ac *AliyunClient) TaskID() string {
+	id, _ := ac.taskID.Load().(string)
+	return id
+}
+
+// State 返回当前会话状态
+func (ac *AliyunClient) State() SessionState {
+	return ac.state.Current()
+}
+
+// SubscribeState 订阅会话状态转换，返回通知通道和取消订阅函数
+func (ac *AliyunClient) SubscribeState() (<-chan Transition, func()) {
+	return ac.state.Subscribe()
+}
 
+// ShutdownRecognition 关闭连接，不等待识别结果，可重复调用
+func (ac *AliyunClient) ShutdownRecognition() {
 	ac.sr.Shutdown()
+	ac.closeShutdown()
+	ac.state.TransitionFrom(StateClosed, StateConnecting, StateStreaming, StateFinishing, StateFailed)
+}
 
-	ac.isRecognizing = false
+// watchSession 一次识别超过最长时间后强制关闭连接，连接关闭后退出
+func (ac *AliyunClient) watchSession(shutdown chan struct{}, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-shutdown:
+	case <-timer.C:
+		if ac.currentShutdown() != shutdown {
+			// 已经开始了下一次识别
+			return
+		}
+		ac.ShutdownRecognition()
+		select {
+		case ac.errorChan <- fmt.Errorf("识别超过最长时间 %s，已强制关闭", timeout):
+		default:
+		}
+	}
+}
 
+// resetShutdown 为新的一次识别创建 shutdown 通道
+func (ac *AliyunClient) resetShutdown() chan struct{} {
+	ac.shutdownMutex.Lock()
+	defer ac.shutdownMutex.Unlock()
+	ac.shutdown = make(chan struct{})
+	return ac.shutdown
+}
+
+func (ac *AliyunClient) currentShutdown() chan struct{} {
+	ac.shutdownMutex.Lock()
+	defer ac.shutdownMutex.Unlock()
+	return ac.shutdown
+}
+
+func (ac *AliyunClient) closeShutdown() {
+	ac.shutdownMutex.Lock()
+	defer ac.shutdownMutex.Unlock()
+	select {
+	case <-ac.shutdown:
+	default:
+		close(ac.shutdown)
+	}
 }
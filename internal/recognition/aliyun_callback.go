@@ -2,8 +2,8 @@ package recognition
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 )
 
 // status错误码：
@@ -18,6 +18,7 @@ import (
 // Name结果数据名称
 // RecognitionCompleted 识别完成
 // RecognitionResultChanged 表示获取到中间识别结果
+// 实时语音识别：SentenceBegin 句子开始、TranscriptionResultChanged 中间结果、SentenceEnd 句子结束、TranscriptionCompleted 识别完成
 // 解析识别结果
 type recognitionResult struct {
 	Header struct {
@@ -29,10 +30,65 @@ type recognitionResult struct {
 		StatusText string `json:"status_text"`
 	} `json:"header"`
 	Payload struct {
-		Result string `json:"result"`
+		Result     string  `json:"result"`
+		Index      int     `json:"index"`      // 实时语音识别：句子编号
+		Time       int     `json:"time"`       // 实时语音识别：当前处理的音频时长，SentenceEnd 时为句子结束时间（毫秒）
+		BeginTime  int     `json:"begin_time"` // 实时语音识别：SentenceEnd 对应的句子开始时间（毫秒）
+		Confidence float64 `json:"confidence"` // 实时语音识别：SentenceEnd 的句子置信度
+		// 开启 enable_words 时返回的词信息（毫秒）
+		Words []struct {
+			Text       string  `json:"text"`
+			StartTime  int     `json:"startTime"`
+			EndTime    int     `json:"endTime"`
+			Confidence float64 `json:"confidence"`
+		} `json:"words"`
 	} `json:"payload"`
 }
 
+// sentence 把识别结果转换为 Sentence
+// 一句话识别的结果没有句子时间，有词信息时取第一个词的开始和最后一个词的结束
+func (r *recognitionResult) sentence() Sentence {
+	p := r.Payload
+	s := Sentence{
+		Index:      p.Index,
+		BeginTime:  p.BeginTime,
+		EndTime:    p.Time,
+		Text:       p.Result,
+		Confidence: p.Confidence,
+	}
+	for _, w := range p.Words {
+		s.Words = append(s.Words, Word{Text: w.Text, BeginTime: w.StartTime, EndTime: w.EndTime, Confidence: w.Confidence})
+	}
+	if s.Index == 0 {
+		s.Index = 1
+	}
+	if s.EndTime == 0 && len(s.Words) > 0 {
+		s.BeginTime = s.Words[0].BeginTime
+		s.EndTime = s.Words[len(s.Words)-1].EndTime
+	}
+	return s
+}
+
+// StatusError 识别服务返回的任务失败，Status 为上面列出的 status 错误码
+type StatusError struct {
+	Status     int
+	StatusText string
+	TaskID     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("识别失败: %d %s (task_id=%s)", e.Status, e.StatusText, e.TaskID)
+}
+
+// StatusCode 返回错误链中识别服务的 status 错误码，不是识别服务返回的错误时为 0
+func StatusCode(err error) int {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Status
+	}
+	return 0
+}
+
 // extractText 从JSON响应中提取文本
 func extractText(jsonStr string) (*recognitionResult, error) {
 	var result recognitionResult
@@ -45,6 +101,7 @@ func extractText(jsonStr string) (*recognitionResult, error) {
 
 // onTaskFailed 处理识别任务失败的回调
 func (ac *AliyunClient) onTaskFailed(text string, param interface{}) {
+	ac.state.TransitionFrom(StateFailed, StateConnecting, StateStreaming, StateFinishing)
 	result, err := extractText(text)
 	if err != nil {
 		ac.errorChan <- err
@@ -53,17 +110,26 @@ func (ac *AliyunClient) onTaskFailed(text string, param interface{}) {
 	// 任务失败如果是 status==41010105 && status_text=="SILENT_SPEECH"，说明是开始后但是超过max_start_silence没有识别到声音
 	// 如果是这样，应该发送到完成Chan而不是err
 	if result.Header.Status == 41010105 && result.Header.StatusText == "SILENT_SPEECH" {
-		// 输出调试警告信息
-		log.Printf("开始识别后 %d ms 未识别到声音，结束识别", ac.startParam.MaxStartSilence)
+		logger.Info("未识别到声音，结束识别", "task_id", result.Header.TaskId, "max_start_silence", ac.startParam.MaxStartSilence)
 		ac.completeChan <- ""
 		return
 	}
-	ac.errorChan <- fmt.Errorf("识别失败: %s", text)
+	ac.errorChan <- &StatusError{
+		Status:     result.Header.Status,
+		StatusText: result.Header.StatusText,
+		TaskID:     result.Header.TaskId,
+	}
 }
 
 func (ac *AliyunClient) onStarted(text string, param interface{}) {
 	// 这些回调应该都是基于WS消息的，不是WS连接状态级别的东西
-	log.Printf("onStarted: %s", text)
+	result, err := extractText(text)
+	if err != nil {
+		logger.Warn("无法解析开始识别的消息", "error", err)
+		return
+	}
+	ac.taskID.Store(result.Header.TaskId)
+	logger.Debug("识别任务已开始", "task_id", result.Header.TaskId)
 }
 
 // onResultChanged 中间结果
@@ -78,14 +144,34 @@ func (ac *AliyunClient) onResultChanged(text string, param interface{}) {
 
 // onCompleted 处理识别完成
 func (ac *AliyunClient) onCompleted(text string, param interface{}) {
+	// 识别服务检测到说话结束时会直接完成，此后不再接收音频
+	ac.state.TransitionFrom(StateFinishing, StateStreaming)
 	result, err := extractText(text)
 	if err != nil {
 		ac.errorChan <- err
 		return
 	}
+	if len(result.Payload.Words) > 0 {
+		// 一句话识别只有开启 enable_words 时才有时间信息
+		ac.appendSentence(result.sentence())
+	}
 	ac.completeChan <- result.Payload.Result
 }
 
+// appendSentence 记录本次识别的一句话
+func (ac *AliyunClient) appendSentence(s Sentence) {
+	ac.sentenceMutex.Lock()
+	defer ac.sentenceMutex.Unlock()
+	ac.sentences = append(ac.sentences, s)
+}
+
+// Sentences 最近一次识别的分句结果
+func (ac *AliyunClient) Sentences() []Sentence {
+	ac.sentenceMutex.Lock()
+	defer ac.sentenceMutex.Unlock()
+	return append([]Sentence(nil), ac.sentences...)
+}
+
 func (ac *AliyunClient) onClose(param interface{}) {
 	// 这些回调应该都是基于WS消息的，不是WS连接状态级别的东西
 }
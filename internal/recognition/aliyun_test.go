@@ -1,17 +1,25 @@
 package recognition
 
 import (
-	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/shellus/voiceWin/internal/audio"
 )
 
-// 测试数据来自：
-// ffmpeg -i <音频文件> -ar 16000 -ac 1 -f s16le -acodec pcm_s16le <输出文件>.pcm
+// 测试数据为 16kHz 单声道 16位裸 PCM（.pcm），也可以直接使用 WAV 文件（任意位深、采样率和声道数）
+
+// readTestAudio 读取测试音频，WAV 文件转换为 16kHz 单声道 PCM
+func readTestAudio(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil || strings.HasSuffix(name, ".pcm") {
+		return data, err
+	}
+	return audio.Decode(data, 16000)
+}
 
 func TestAliyunClient(t *testing.T) {
 	// 加载.env文件
@@ -46,7 +54,7 @@ func TestAliyunClient(t *testing.T) {
 	}
 
 	// 读取测试音频文件
-	pcmData, err := ioutil.ReadFile("中国人.pcm")
+	pcmData, err := readTestAudio("中国人.pcm")
 	if err != nil {
 		t.Fatalf("读取PCM文件失败: %v", err)
 	}
@@ -176,7 +184,7 @@ func TestTwoConsecutiveRecognitions(t *testing.T) {
 	}
 
 	// 读取测试音频文件
-	pcmData, err := ioutil.ReadFile("中国人.pcm")
+	pcmData, err := readTestAudio("中国人.pcm")
 	if err != nil {
 		t.Fatalf("读取PCM文件失败: %v", err)
 	}
@@ -188,7 +196,7 @@ func TestTwoConsecutiveRecognitions(t *testing.T) {
 
 	// 执行第二次识别周期
 	// 读取测试音频文件
-	pcmData, err = ioutil.ReadFile("帮我完成任务.pcm")
+	pcmData, err = readTestAudio("帮我完成任务.pcm")
 	if err != nil {
 		t.Fatalf("读取PCM文件失败: %v", err)
 	}
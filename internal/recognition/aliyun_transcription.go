@@ -0,0 +1,125 @@
+package recognition
+
+import (
+	"fmt"
+	"strings"
+
+	nls "github.com/aliyun/alibabacloud-nls-go-sdk"
+)
+
+// 实时语音识别（SpeechTranscription）：适合连续听写，持续识别并按句返回结果，没有一句话识别的60秒限制。
+// 生命周期与 AliyunClient 相同（Start -> Send -> Stop/Shutdown），区别是：
+// 识别服务不会因为说话结束而自动完成，需要主动 Stop；
+// 每句话开始和结束时通过 SentenceBegin/SentenceEnd 通道返回，时间相对本次识别音频的开始；
+// Stop 后完成通道返回所有句子拼接的全文。
+//
+// 文档：https://help.aliyun.com/zh/isi/developer-reference/sdk-for-go-2
+
+// SentenceRecognizer 能按句返回结果的识别器
+type SentenceRecognizer interface {
+	Recognizer
+	GetSentenceBeginChannel() <-chan Sentence
+	GetSentenceEndChannel() <-chan Sentence
+}
+
+var _ SentenceRecognizer = (*TranscriptionClient)(nil)
+
+// transcriptionAdapter 把 nls.SpeechTranscription 适配为 speechRecognizer，
+// 一句话识别的参数转换为实时语音识别的参数
+type transcriptionAdapter struct {
+	*nls.SpeechTranscription
+	startParam *StartParam
+}
+
+func (a *transcriptionAdapter) Start(param nls.SpeechRecognitionStartParam, extra map[string]interface{}) (chan bool, error) {
+	// enable_voice_detection、max_start_silence、max_end_silence 只属于一句话识别
+	return a.SpeechTranscription.Start(nls.SpeechTranscriptionStartParam{
+		Format:                         param.Format,
+		SampleRate:                     param.SampleRate,
+		EnableIntermediateResult:       param.EnableIntermediateResult,
+		EnablePunctuationPrediction:    param.EnablePunctuationPrediction,
+		EnableInverseTextNormalization: param.EnableInverseTextNormalization,
+		MaxSentenceSilence:             a.startParam.MaxSentenceSilence,
+		EnableWords:                    a.startParam.EnableWords,
+	}, map[string]interface{}{
+		"disfluency": a.startParam.DisableDisfluency,
+	})
+}
+
+// TranscriptionClient 阿里云实时语音识别客户端，实现 SentenceRecognizer 接口
+type TranscriptionClient struct {
+	*AliyunClient
+	sentenceBeginChan chan Sentence
+	sentenceEndChan   chan Sentence
+}
+
+// NewTranscriptionClient 创建阿里云实时语音识别客户端
+func NewTranscriptionClient(cfg *AliyunConfig, startParam *StartParam) (*TranscriptionClient, error) {
+	tc := newTranscriptionClient(newAliyunClient(cfg, startParam))
+
+	config, err := tc.connectionConfig()
+	if err != nil {
+		return nil, err
+	}
+	st, err := nls.NewSpeechTranscription(config, tc.nlsLogger,
+		tc.onTaskFailed, tc.onStarted, tc.onSentenceBegin, tc.onSentenceEnd,
+		tc.onResultChanged, tc.onTranscriptionCompleted, tc.onClose, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建实时语音识别实例失败: %v", err)
+	}
+	tc.sr = &transcriptionAdapter{SpeechTranscription: st, startParam: startParam}
+	return tc, nil
+}
+
+func newTranscriptionClient(ac *AliyunClient) *TranscriptionClient {
+	return &TranscriptionClient{
+		AliyunClient:      ac,
+		sentenceBeginChan: make(chan Sentence, 10),
+		sentenceEndChan:   make(chan Sentence, 10),
+	}
+}
+
+// GetSentenceBeginChannel 获取句子开始通道
+func (tc *TranscriptionClient) GetSentenceBeginChannel() <-chan Sentence {
+	return tc.sentenceBeginChan
+}
+
+// GetSentenceEndChannel 获取句子结束通道
+func (tc *TranscriptionClient) GetSentenceEndChannel() <-chan Sentence {
+	return tc.sentenceEndChan
+}
+
+// onSentenceBegin 检测到一句话开始
+func (tc *TranscriptionClient) onSentenceBegin(text string, param interface{}) {
+	result, err := extractText(text)
+	if err != nil {
+		tc.errorChan <- err
+		return
+	}
+	tc.sentenceBeginChan <- Sentence{
+		Index:     result.Payload.Index,
+		BeginTime: result.Payload.Time,
+	}
+}
+
+// onSentenceEnd 检测到一句话结束
+func (tc *TranscriptionClient) onSentenceEnd(text string, param interface{}) {
+	result, err := extractText(text)
+	if err != nil {
+		tc.errorChan <- err
+		return
+	}
+	s := result.sentence()
+	tc.appendSentence(s)
+	tc.sentenceEndChan <- s
+}
+
+// onTranscriptionCompleted Stop 后识别完成，返回所有句子拼接的全文
+func (tc *TranscriptionClient) onTranscriptionCompleted(text string, param interface{}) {
+	tc.state.TransitionFrom(StateFinishing, StateStreaming)
+	var full strings.Builder
+	for _, s := range tc.Sentences() {
+		full.WriteString(s.Text)
+	}
+	tc.completeChan <- full.String()
+}
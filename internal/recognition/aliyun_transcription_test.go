@@ -0,0 +1,65 @@
+package recognition
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestTranscriptionClient(sr speechRecognizer) *TranscriptionClient {
+	ac := newTestClient(sr)
+	ac.watch = false
+	return newTranscriptionClient(ac)
+}
+
+func TestTranscriptionClient_Sentences(t *testing.T) {
+	tc := newTestTranscriptionClient(&fakeRecognizer{autoReady: true})
+	// 实时语音识别没有一句话识别的时长限制
+	tc.SetTimeouts(Timeouts{Session: 50 * time.Millisecond})
+
+	for round := 0; round < 2; round++ {
+		if err := tc.StartRecognition(); err != nil {
+			t.Fatalf("启动识别失败: %v", err)
+		}
+
+		tc.onSentenceBegin(`{"header":{"name":"SentenceBegin","status":20000000},"payload":{"index":1,"time":320}}`, nil)
+		tc.onSentenceEnd(`{"header":{"name":"SentenceEnd","status":20000000},"payload":{"index":1,"time":1820,"begin_time":320,"confidence":0.92,"result":"今天天气不错。","words":[{"text":"今天","startTime":320,"endTime":700},{"text":"天气","startTime":700,"endTime":1200},{"text":"不错","startTime":1200,"endTime":1820}]}}`, nil)
+		tc.onSentenceEnd(`{"header":{"name":"SentenceEnd","status":20000000},"payload":{"index":2,"time":3600,"begin_time":2400,"result":"出去走走。"}}`, nil)
+
+		if s := <-tc.GetSentenceBeginChannel(); !reflect.DeepEqual(s, Sentence{Index: 1, BeginTime: 320}) {
+			t.Errorf("句子开始事件错误: %+v", s)
+		}
+		want := []Sentence{
+			{Index: 1, BeginTime: 320, EndTime: 1820, Text: "今天天气不错。", Confidence: 0.92, Words: []Word{
+				{Text: "今天", BeginTime: 320, EndTime: 700},
+				{Text: "天气", BeginTime: 700, EndTime: 1200},
+				{Text: "不错", BeginTime: 1200, EndTime: 1820},
+			}},
+			{Index: 2, BeginTime: 2400, EndTime: 3600, Text: "出去走走。"},
+		}
+		for _, w := range want {
+			if s := <-tc.GetSentenceEndChannel(); !reflect.DeepEqual(s, w) {
+				t.Errorf("句子结束事件期望 %+v，实际 %+v", w, s)
+			}
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		select {
+		case err := <-tc.GetErrorChannel():
+			t.Fatalf("实时语音识别不应超时: %v", err)
+		default:
+		}
+
+		if err := tc.StopRecognition(); err != nil {
+			t.Fatalf("停止识别失败: %v", err)
+		}
+		tc.onTranscriptionCompleted(`{"header":{"name":"TranscriptionCompleted","status":20000000}}`, nil)
+		if text := <-tc.GetCompleteChannel(); text != "今天天气不错。出去走走。" {
+			t.Errorf("完成结果应为所有句子拼接，实际为 %q", text)
+		}
+		if got := tc.Sentences(); !reflect.DeepEqual(got, want) {
+			t.Errorf("分句结果期望 %+v，实际 %+v", want, got)
+		}
+		tc.ShutdownRecognition()
+	}
+}
@@ -0,0 +1,124 @@
+package recognition
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// checkGoroutines 等待 goroutine 数量回落到测试开始前的水平，用于发现泄漏
+func checkGoroutines(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			n := runtime.Stack(buf, true)
+			t.Fatalf("goroutine 泄漏: 开始前 %d，现在 %d\n%s", before, runtime.NumGoroutine(), buf[:n])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAliyunClient_ConnectTimeout(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sr := &fakeRecognizer{}
+	ac := newTestClient(sr)
+	ac.SetTimeouts(Timeouts{Connect: 50 * time.Millisecond})
+
+	start := time.Now()
+	err := ac.StartRecognition()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望连接超时，实际为 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("连接超时后返回过慢: %s", elapsed)
+	}
+	if ac.State() != StateClosed || sr.shutdowns == 0 {
+		t.Errorf("超时后应关闭连接，实际状态 %s，Shutdown 调用 %d 次", ac.State(), sr.shutdowns)
+	}
+	checkGoroutines(t, before)
+}
+
+func TestAliyunClient_StartCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sr := &fakeRecognizer{}
+	ac := newTestClient(sr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := ac.StartRecognitionContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望被取消，实际为 %v", err)
+	}
+	if ac.State() != StateClosed {
+		t.Errorf("取消后期望 Closed，实际为 %s", ac.State())
+	}
+
+	// 已取消的 ctx 不应开始连接
+	if err := ac.StartRecognitionContext(ctx); !errors.Is(err, context.Canceled) || ac.State() != StateClosed {
+		t.Errorf("已取消的 ctx 不应开始识别: %v, %s", err, ac.State())
+	}
+	if err := ac.SendAudioDataContext(ctx, []byte{1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("已取消的 ctx 不应发送音频: %v", err)
+	}
+	checkGoroutines(t, before)
+}
+
+func TestAliyunClient_StopTimeout(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sr := &fakeRecognizer{autoReady: true, stopHang: true}
+	ac := newTestClient(sr)
+	ac.SetTimeouts(Timeouts{Stop: 50 * time.Millisecond})
+
+	if err := ac.StartRecognition(); err != nil {
+		t.Fatalf("启动识别失败: %v", err)
+	}
+	if err := ac.StopRecognition(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望等待结果超时，实际为 %v", err)
+	}
+	if ac.State() != StateClosed {
+		t.Errorf("超时后期望 Closed，实际为 %s", ac.State())
+	}
+	checkGoroutines(t, before)
+}
+
+func TestAliyunClient_SessionTimeout(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ac := newTestClient(&fakeRecognizer{autoReady: true})
+	ac.SetTimeouts(Timeouts{Session: 50 * time.Millisecond})
+
+	if err := ac.StartRecognition(); err != nil {
+		t.Fatalf("启动识别失败: %v", err)
+	}
+	select {
+	case err := <-ac.GetErrorChannel():
+		if err == nil {
+			t.Error("超过最长时间应发送错误")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("超过最长时间后没有强制关闭")
+	}
+	if ac.State() != StateClosed {
+		t.Errorf("强制关闭后期望 Closed，实际为 %s", ac.State())
+	}
+	checkGoroutines(t, before)
+}
+
+func TestAliyunClient_NoGoroutineLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ac := newTestClient(&fakeRecognizer{autoReady: true})
+	for i := 0; i < 20; i++ {
+		if err := ac.StartRecognition(); err != nil {
+			t.Fatalf("启动识别失败: %v", err)
+		}
+		if i%2 == 0 {
+			ac.StopRecognition()
+		} else {
+			ac.ShutdownRecognition()
+		}
+	}
+	// 会话监视 goroutine 应随连接关闭退出
+	checkGoroutines(t, before)
+}
@@ -0,0 +1,430 @@
+package recognition
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 本地识别后端：驱动一个本地的离线识别进程（如包装了 whisper.cpp 或 Vosk 的程序），不需要网络。
+// 进程在第一次识别时启动并常驻，通过标准输入输出交换 JSON Lines（每行一个 JSON 对象）：
+//
+// voiceWin -> 进程（stdin）：
+//
+//	{"type":"start","id":"<任务ID>","format":"pcm","sample_rate":16000}  开始一次识别
+//	{"type":"audio","id":"<任务ID>","data":"<base64 编码的 PCM>"}          音频数据，16位单声道小端
+//	{"type":"stop","id":"<任务ID>"}                                       音频发送完毕，需要返回 final
+//	{"type":"cancel","id":"<任务ID>"}                                     放弃本次识别，不需要返回结果
+//
+// 进程 -> voiceWin（stdout）：
+//
+//	{"type":"ready","id":"<任务ID>"}                   收到 start 后可以接收音频
+//	{"type":"partial","id":"<任务ID>","text":"..."}    中间结果（可选）
+//	{"type":"final","id":"<任务ID>","text":"..."}      最终结果，text 为空表示没有识别到声音；进程自己检测到说话结束时也可以提前返回
+//	{"type":"error","id":"<任务ID>","error":"..."}     识别失败
+//
+// id 与当前任务不一致的消息会被忽略。进程的 stderr 直接输出到 voiceWin 的 stderr。
+// 调用方不读取结果时，中间结果被丢弃，最终结果和错误在任务放弃后丢弃，不会阻塞读取进程的输出。
+// 进程 localWriteTimeout 内不读取 stdin 时视为卡死，结束进程，下次识别时重新启动。
+
+// localWriteTimeout 向进程写入一条消息的最长时间
+const localWriteTimeout = 2 * time.Second
+
+// LocalConfig 本地识别进程配置
+type LocalConfig struct {
+	Command string   // 可执行文件路径
+	Args    []string // 命令行参数
+	Env     []string // 额外的环境变量，格式为 KEY=VALUE
+}
+
+// localMessage 本地识别进程协议的消息
+type localMessage struct {
+	Type       string `json:"type"`
+	ID         string `json:"id,omitempty"`
+	Format     string `json:"format,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Data       []byte `json:"data,omitempty"`
+	Text       string `json:"text,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// localProcess 一个运行中的本地识别进程
+type localProcess struct {
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	writeMutex sync.Mutex // 保证消息按调用顺序完整写入
+	enc        *json.Encoder
+	exited     chan struct{} // 进程退出时关闭
+}
+
+// localTask 一次识别任务
+type localTask struct {
+	id    string
+	ready chan error    // 收到 ready 或失败时写入
+	done  chan struct{} // 识别结束（收到 final、失败或关闭）时关闭
+	once  sync.Once
+}
+
+func (t *localTask) signalReady(err error) {
+	select {
+	case t.ready <- err:
+	default:
+	}
+}
+
+func (t *localTask) finish() {
+	t.once.Do(func() { close(t.done) })
+}
+
+// LocalClient 本地识别客户端，实现 Recognizer 接口
+type LocalClient struct {
+	config       *LocalConfig
+	startParam   *StartParam
+	timeouts     Timeouts
+	resultChan   chan string
+	completeChan chan string
+	errorChan    chan error
+	state        *stateMachine
+	taskID       atomic.Value // 当前识别任务ID(string)
+
+	mutex        sync.Mutex // 保护 proc 和 task，向进程写入时不持有
+	proc         *localProcess
+	task         *localTask
+	writeTimeout time.Duration // 默认 localWriteTimeout
+}
+
+// NewLocalClient 创建本地识别客户端，进程在第一次识别时才启动
+func NewLocalClient(cfg *LocalConfig, startParam *StartParam) (*LocalClient, error) {
+	if cfg == nil || cfg.Command == "" {
+		return nil, errors.New("未配置本地识别程序")
+	}
+	if _, err := exec.LookPath(cfg.Command); err != nil {
+		return nil, fmt.Errorf("找不到本地识别程序: %w", err)
+	}
+	return &LocalClient{
+		config:       cfg,
+		startParam:   startParam,
+		timeouts:     DefaultTimeouts(),
+		resultChan:   make(chan string, 10),
+		completeChan: make(chan string, 10),
+		errorChan:    make(chan error, 10),
+		state:        newStateMachine(),
+		writeTimeout: localWriteTimeout,
+	}, nil
+}
+
+// SetTimeouts 修改超时时间，下次识别生效；Session 对本地识别不生效
+func (lc *LocalClient) SetTimeouts(t Timeouts) {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	lc.timeouts = t.withDefaults()
+}
+
+// StartRecognitionContext 开始一次识别，等待进程返回 ready
+func (lc *LocalClient) StartRecognitionContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("StartRecognition 已取消: %w", err)
+	}
+	if err := lc.state.Transition(StateConnecting); err != nil {
+		return fmt.Errorf("StartRecognition 重复启动: %w", err)
+	}
+
+	task := &localTask{
+		id:    newTaskID(),
+		ready: make(chan error, 1),
+		done:  make(chan struct{}),
+	}
+	lc.mutex.Lock()
+	ctx, cancel := context.WithTimeout(ctx, lc.timeouts.Connect)
+	defer cancel()
+	lc.task = task
+	lc.taskID.Store(task.id)
+	err := lc.ensureProcess()
+	proc := lc.proc
+	lc.mutex.Unlock()
+	if err == nil {
+		err = lc.write(proc, localMessage{
+			Type:       "start",
+			ID:         task.id,
+			Format:     lc.startParam.Format,
+			SampleRate: lc.startParam.SampleRate,
+		})
+	}
+	if err != nil {
+		lc.state.TransitionFrom(StateFailed, StateConnecting)
+		task.finish()
+		return fmt.Errorf("StartRecognition 启动本地识别失败: %w", err)
+	}
+
+	select {
+	case err = <-task.ready:
+	case <-ctx.Done():
+		lc.ShutdownRecognition()
+		return fmt.Errorf("StartRecognition 等待本地识别开始失败: %w", ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("StartRecognition 本地识别开始失败: %w", err)
+	}
+	return nil
+}
+
+// SendAudioData 发送音频数据
+func (lc *LocalClient) SendAudioData(data []byte) error {
+	if lc.state.Current() != StateStreaming {
+		return fmt.Errorf("语音识别未连接")
+	}
+	lc.mutex.Lock()
+	task, proc := lc.task, lc.proc
+	lc.mutex.Unlock()
+	if task == nil {
+		return fmt.Errorf("语音识别未连接")
+	}
+	return lc.write(proc, localMessage{Type: "audio", ID: task.id, Data: data})
+}
+
+// StopRecognitionContext 通知进程音频发送完毕，等待最终结果
+func (lc *LocalClient) StopRecognitionContext(ctx context.Context) error {
+	if !lc.state.TransitionFrom(StateFinishing, StateStreaming) {
+		return nil
+	}
+	lc.mutex.Lock()
+	task, proc := lc.task, lc.proc
+	ctx, cancel := context.WithTimeout(ctx, lc.timeouts.Stop)
+	defer cancel()
+	lc.mutex.Unlock()
+	if task == nil {
+		return nil
+	}
+	if err := lc.write(proc, localMessage{Type: "stop", ID: task.id}); err != nil {
+		lc.ShutdownRecognition()
+		return fmt.Errorf("停止语音识别失败: %w", err)
+	}
+
+	select {
+	case <-task.done:
+	case <-ctx.Done():
+		lc.ShutdownRecognition()
+		return fmt.Errorf("等待识别结果失败: %w", ctx.Err())
+	}
+	return nil
+}
+
+// ShutdownRecognition 放弃当前识别，不等待结果，进程保持运行
+// 进程卡死不读取输入时最多等待 localWriteTimeout，之后结束进程
+func (lc *LocalClient) ShutdownRecognition() {
+	lc.mutex.Lock()
+	task, proc := lc.task, lc.proc
+	cancel := task != nil && lc.state.TransitionFrom(StateClosed, StateConnecting, StateStreaming, StateFinishing, StateFailed)
+	lc.task = nil
+	lc.mutex.Unlock()
+	if task != nil {
+		task.signalReady(errors.New("识别已关闭"))
+		task.finish()
+	}
+	if cancel {
+		lc.write(proc, localMessage{Type: "cancel", ID: task.id})
+	}
+}
+
+// TaskID 返回最近一次识别任务的ID
+func (lc *LocalClient) TaskID() string {
+	id, _ := lc.taskID.Load().(string)
+	return id
+}
+
+// State 返回当前会话状态
+func (lc *LocalClient) State() SessionState {
+	return lc.state.Current()
+}
+
+// GetResultChannel 获取结果通道
+func (lc *LocalClient) GetResultChannel() <-chan string {
+	return lc.resultChan
+}
+
+// GetCompleteChannel 获取完成通道
+func (lc *LocalClient) GetCompleteChannel() <-chan string {
+	return lc.completeChan
+}
+
+// GetErrorChannel 获取错误通道
+func (lc *LocalClient) GetErrorChannel() <-chan error {
+	return lc.errorChan
+}
+
+// Close 放弃当前识别并结束本地识别进程
+func (lc *LocalClient) Close() {
+	lc.ShutdownRecognition()
+	lc.mutex.Lock()
+	proc := lc.proc
+	lc.proc = nil
+	lc.mutex.Unlock()
+	if proc == nil {
+		return
+	}
+
+	// 先关闭 stdin 让进程自行退出，超时后强制结束
+	proc.stdin.Close()
+	select {
+	case <-proc.exited:
+	case <-time.After(2 * time.Second):
+		proc.cmd.Process.Kill()
+		<-proc.exited
+	}
+}
+
+// ensureProcess 确保进程在运行，调用方需持有 mutex
+func (lc *LocalClient) ensureProcess() error {
+	if lc.proc != nil {
+		select {
+		case <-lc.proc.exited:
+		default:
+			return nil
+		}
+	}
+
+	cmd := exec.Command(lc.config.Command, lc.config.Args...)
+	cmd.Env = append(os.Environ(), lc.config.Env...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动本地识别程序失败: %w", err)
+	}
+
+	proc := &localProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		enc:    json.NewEncoder(stdin),
+		exited: make(chan struct{}),
+	}
+	lc.proc = proc
+	go lc.readLoop(proc, stdout)
+	return nil
+}
+
+// write 向进程写入一条消息，调用方不能持有 mutex
+// 进程 writeTimeout 内没有读取时结束进程，阻塞的写入随之失败
+func (lc *LocalClient) write(proc *localProcess, msg localMessage) error {
+	if proc == nil {
+		return errors.New("本地识别进程未运行")
+	}
+	proc.writeMutex.Lock()
+	defer proc.writeMutex.Unlock()
+	done := make(chan error, 1)
+	go func() { done <- proc.enc.Encode(msg) }()
+	timer := time.NewTimer(lc.writeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		logger.Warn("本地识别程序没有读取输入，结束进程", "timeout", lc.writeTimeout)
+		proc.cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("本地识别程序 %s 内没有读取输入，已结束进程", lc.writeTimeout)
+	}
+}
+
+// readLoop 读取进程输出，进程退出后让当前识别失败
+func (lc *LocalClient) readLoop(proc *localProcess, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var msg localMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logger.Warn("无法解析本地识别程序的输出", "line", scanner.Text())
+			continue
+		}
+		lc.handle(msg)
+	}
+	err := proc.cmd.Wait()
+	close(proc.exited)
+	lc.fail(proc, fmt.Errorf("本地识别程序已退出: %v", err))
+}
+
+// handle 处理进程返回的一条消息
+func (lc *LocalClient) handle(msg localMessage) {
+	lc.mutex.Lock()
+	task := lc.task
+	lc.mutex.Unlock()
+	if task == nil || msg.ID != task.id {
+		// 已经结束或放弃的任务
+		return
+	}
+
+	switch msg.Type {
+	case "ready":
+		if lc.state.TransitionFrom(StateStreaming, StateConnecting) {
+			task.signalReady(nil)
+		}
+	case "partial":
+		if s := lc.state.Current(); s == StateStreaming || s == StateFinishing {
+			select {
+			case lc.resultChan <- msg.Text:
+			default:
+				logger.Debug("中间结果没有被读取，已丢弃", "task_id", task.id)
+			}
+		}
+	case "final":
+		if lc.state.TransitionFrom(StateClosed, StateStreaming, StateFinishing) {
+			send(lc.completeChan, msg.Text, task.done)
+			task.finish()
+		}
+	case "error":
+		lc.failTask(task, errors.New(msg.Error))
+	default:
+		logger.Warn("未知的本地识别消息类型", "type", msg.Type, "task_id", msg.ID)
+	}
+}
+
+// fail 进程退出时让正在进行的识别失败
+func (lc *LocalClient) fail(proc *localProcess, err error) {
+	lc.mutex.Lock()
+	task := lc.task
+	current := lc.proc == proc
+	lc.mutex.Unlock()
+	if task != nil && current {
+		lc.failTask(task, err)
+	}
+}
+
+func (lc *LocalClient) failTask(task *localTask, err error) {
+	if lc.state.TransitionFrom(StateFailed, StateConnecting, StateStreaming, StateFinishing) {
+		err = fmt.Errorf("本地识别失败: %w", err)
+		send(lc.errorChan, err, task.done)
+		task.signalReady(err)
+		task.finish()
+	}
+}
+
+// send 写入结果通道，调用方不再读取、任务已经放弃（done 关闭）时放弃写入
+func send[T any](ch chan<- T, v T, done <-chan struct{}) {
+	select {
+	case ch <- v:
+	case <-done:
+	}
+}
+
+// newTaskID 生成本地识别任务ID
+func newTaskID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
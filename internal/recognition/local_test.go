@@ -0,0 +1,230 @@
+package recognition
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess 不是真正的测试，而是被 LocalClient 启动的本地识别进程替身：
+// 收到音频后返回收到的字节数作为中间结果，stop 后返回固定的最终结果
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("VOICEWIN_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	enc := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	received := 0
+	for scanner.Scan() {
+		var msg localMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			fmt.Fprintf(os.Stderr, "bad message: %v\n", err)
+			os.Exit(2)
+		}
+		switch msg.Type {
+		case "start":
+			received = 0
+			switch os.Getenv("VOICEWIN_HELPER_MODE") {
+			case "error":
+				enc.Encode(localMessage{Type: "error", ID: msg.ID, Error: "模型加载失败"})
+				continue
+			case "hang":
+				continue
+			case "exit":
+				os.Exit(3)
+			case "flood":
+				// 大量中间结果和最终结果，超过结果通道的缓冲区
+				enc.Encode(localMessage{Type: "ready", ID: msg.ID})
+				for i := 0; i < 100; i++ {
+					enc.Encode(localMessage{Type: "partial", ID: msg.ID, Text: fmt.Sprintf("%d", i)})
+				}
+				enc.Encode(localMessage{Type: "final", ID: msg.ID, Text: "你好世界"})
+				continue
+			case "stuck":
+				// 开始后不再读取输入
+				enc.Encode(localMessage{Type: "ready", ID: msg.ID})
+				time.Sleep(time.Hour)
+			}
+			enc.Encode(localMessage{Type: "ready", ID: msg.ID})
+		case "audio":
+			received += len(msg.Data)
+			enc.Encode(localMessage{Type: "partial", ID: msg.ID, Text: fmt.Sprintf("%d", received)})
+		case "stop":
+			enc.Encode(localMessage{Type: "final", ID: msg.ID, Text: fmt.Sprintf("你好世界%d", received)})
+		case "cancel":
+			// 过期的结果应被忽略
+			enc.Encode(localMessage{Type: "final", ID: msg.ID, Text: "已取消"})
+		}
+	}
+}
+
+func newHelperClient(t *testing.T, mode string) *LocalClient {
+	t.Helper()
+	lc, err := NewLocalClient(&LocalConfig{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestHelperProcess$"},
+		Env:     []string{"VOICEWIN_HELPER_PROCESS=1", "VOICEWIN_HELPER_MODE=" + mode},
+	}, DefaultStartParam())
+	if err != nil {
+		t.Fatalf("创建本地识别客户端失败: %v", err)
+	}
+	lc.SetTimeouts(Timeouts{Connect: time.Second, Stop: time.Second})
+	t.Cleanup(lc.Close)
+	return lc
+}
+
+func TestLocalClient_Recognize(t *testing.T) {
+	lc := newHelperClient(t, "")
+
+	for i := 0; i < 2; i++ {
+		if err := lc.StartRecognitionContext(context.Background()); err != nil {
+			t.Fatalf("第%d次开始识别失败: %v", i+1, err)
+		}
+		lc.SendAudioData([]byte{1, 2, 3, 4})
+		lc.SendAudioData([]byte{5, 6})
+
+		select {
+		case text := <-lc.GetResultChannel():
+			if text != "4" {
+				t.Errorf("期望中间结果为4，实际为%s", text)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("等待中间结果超时")
+		}
+
+		if err := lc.StopRecognitionContext(context.Background()); err != nil {
+			t.Fatalf("停止识别失败: %v", err)
+		}
+		if text := <-lc.GetCompleteChannel(); text != "你好世界6" {
+			t.Errorf("期望最终结果为 你好世界6，实际为 %s", text)
+		}
+		lc.ShutdownRecognition()
+		<-lc.GetResultChannel()
+		if lc.TaskID() == "" {
+			t.Error("任务ID不应为空")
+		}
+	}
+}
+
+func TestLocalClient_Cancel(t *testing.T) {
+	lc := newHelperClient(t, "")
+	if err := lc.StartRecognitionContext(context.Background()); err != nil {
+		t.Fatalf("开始识别失败: %v", err)
+	}
+	lc.ShutdownRecognition()
+
+	if err := lc.StartRecognitionContext(context.Background()); err != nil {
+		t.Fatalf("取消后再次开始识别失败: %v", err)
+	}
+	select {
+	case text := <-lc.GetCompleteChannel():
+		t.Errorf("已取消任务的结果不应返回: %s", text)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestLocalClient_NotRead 调用方不读取结果通道时，读取进程输出的 goroutine 不应阻塞
+func TestLocalClient_NotRead(t *testing.T) {
+	before := runtime.NumGoroutine()
+	lc := newHelperClient(t, "flood")
+	for i := 0; i < 15; i++ {
+		if err := lc.StartRecognitionContext(context.Background()); err != nil {
+			t.Fatalf("第%d次开始识别失败: %v", i+1, err)
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for lc.State() != StateClosed {
+			if time.Now().After(deadline) {
+				t.Fatalf("第%d次识别没有收到最终结果，读取输出被阻塞", i+1)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		lc.ShutdownRecognition()
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		lc.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Close 等待读取进程输出的 goroutine 超时")
+	}
+	checkGoroutines(t, before)
+}
+
+func TestLocalClient_Errors(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		lc := newHelperClient(t, "error")
+		if err := lc.StartRecognitionContext(context.Background()); err == nil {
+			t.Fatal("进程返回错误时应开始失败")
+		}
+		if lc.State() != StateFailed {
+			t.Errorf("期望 Failed，实际为 %s", lc.State())
+		}
+		if err := <-lc.GetErrorChannel(); err == nil {
+			t.Error("应通过错误通道报告失败")
+		}
+	})
+
+	t.Run("stuck", func(t *testing.T) {
+		lc := newHelperClient(t, "stuck")
+		lc.writeTimeout = 100 * time.Millisecond
+		if err := lc.StartRecognitionContext(context.Background()); err != nil {
+			t.Fatalf("开始识别失败: %v", err)
+		}
+		// 超过管道缓冲区的音频会阻塞写入
+		sent := make(chan error, 1)
+		go func() { sent <- lc.SendAudioData(make([]byte, 1<<20)) }()
+		time.Sleep(20 * time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			lc.TaskID()
+			lc.ShutdownRecognition()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("进程不读取输入时 ShutdownRecognition 不应一直阻塞")
+		}
+		if err := <-sent; err == nil {
+			t.Error("写入超时应返回错误")
+		}
+	})
+
+	t.Run("hang", func(t *testing.T) {
+		lc := newHelperClient(t, "hang")
+		lc.SetTimeouts(Timeouts{Connect: 50 * time.Millisecond})
+		if err := lc.StartRecognitionContext(context.Background()); err == nil {
+			t.Fatal("进程无响应时应超时")
+		}
+		if lc.State() != StateClosed {
+			t.Errorf("期望 Closed，实际为 %s", lc.State())
+		}
+	})
+
+	t.Run("exit", func(t *testing.T) {
+		lc := newHelperClient(t, "exit")
+		if err := lc.StartRecognitionContext(context.Background()); err == nil {
+			t.Fatal("进程退出时应开始失败")
+		}
+		<-lc.GetErrorChannel()
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if _, err := NewLocalClient(&LocalConfig{Command: "voicewin-no-such-program"}, DefaultStartParam()); err == nil {
+			t.Error("找不到程序时应返回错误")
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package recognition
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 多声道识别：采访等场景每人一个麦克风，每个输入声道由一个独立的识别器识别。
+// MultiChannel 把交错排列的多声道 PCM 拆成单声道分别发送，结果标注说话人（声道 0 为 A，声道 1 为 B，依此类推），
+// 所有声道都完成后按句子开始时间合并为一份带说话人的文本。
+// 某个声道没有人说话或识别失败不影响其他声道，只有所有声道都失败时才返回错误。
+
+// Speaker 返回声道对应的说话人标签
+func Speaker(channel int) string {
+	return string(rune('A' + channel))
+}
+
+// MultiChannel 多声道识别器，实现 SentenceRecognizer 和 SegmentRecognizer 接口
+type MultiChannel struct {
+	channels []Recognizer
+
+	resultChan        chan string
+	completeChan      chan string
+	errorChan         chan error
+	sentenceBeginChan chan Sentence
+	sentenceEndChan   chan Sentence
+	closed            chan struct{}
+	closeOnce         sync.Once
+
+	mutex     sync.Mutex
+	active    bool       // 识别进行中，还有声道没有完成
+	done      []bool     // 声道是否已完成（成功或失败）
+	texts     []string   // 每个声道的最终结果
+	errs      []error    // 每个声道的错误
+	sentences []Sentence // 合并后的分句结果
+}
+
+// NewMultiChannel 创建多声道识别器，channels[i] 识别第 i 个声道
+func NewMultiChannel(channels []Recognizer) *MultiChannel {
+	m := &MultiChannel{
+		channels:          channels,
+		resultChan:        make(chan string, 10),
+		completeChan:      make(chan string, 10),
+		errorChan:         make(chan error, 10),
+		sentenceBeginChan: make(chan Sentence, 10),
+		sentenceEndChan:   make(chan Sentence, 10),
+		closed:            make(chan struct{}),
+		done:              make([]bool, len(channels)),
+		texts:             make([]string, len(channels)),
+		errs:              make([]error, len(channels)),
+	}
+	for i, c := range channels {
+		go m.forward(i, c)
+	}
+	return m
+}
+
+// StartRecognitionContext 同时开始所有声道的识别，任一声道失败时关闭已开始的识别并返回错误
+func (m *MultiChannel) StartRecognitionContext(ctx context.Context) error {
+	m.mutex.Lock()
+	m.active = true
+	for i := range m.channels {
+		m.done[i], m.texts[i], m.errs[i] = false, "", nil
+	}
+	m.sentences = nil
+	m.mutex.Unlock()
+
+	errs := make([]error, len(m.channels))
+	m.each(func(i int, c Recognizer) {
+		if err := c.StartRecognitionContext(ctx); err != nil {
+			errs[i] = fmt.Errorf("说话人 %s: %w", Speaker(i), err)
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		m.mutex.Lock()
+		m.active = false
+		m.mutex.Unlock()
+		m.ShutdownRecognition()
+		return err
+	}
+	return nil
+}
+
+// SendAudioData 把交错排列的 16位 PCM 按声道拆开发送，已经完成的声道不再发送
+func (m *MultiChannel) SendAudioData(data []byte) error {
+	m.mutex.Lock()
+	done := slices.Clone(m.done)
+	m.mutex.Unlock()
+
+	// 识别器可能缓存发送的数据（如 Prewarmer），每次都拆分到新的缓冲区
+	mono := splitChannels(data, len(m.channels))
+	var errs []error
+	for c, r := range m.channels {
+		if done[c] {
+			continue
+		}
+		if err := r.SendAudioData(mono[c]); err != nil {
+			errs = append(errs, fmt.Errorf("说话人 %s: %w", Speaker(c), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StopRecognitionContext 停止还没有完成的声道，等待所有声道的结果
+func (m *MultiChannel) StopRecognitionContext(ctx context.Context) error {
+	m.mutex.Lock()
+	done := slices.Clone(m.done)
+	m.mutex.Unlock()
+
+	errs := make([]error, len(m.channels))
+	m.each(func(i int, c Recognizer) {
+		if done[i] {
+			return
+		}
+		if err := c.StopRecognitionContext(ctx); err != nil {
+			errs[i] = fmt.Errorf("说话人 %s: %w", Speaker(i), err)
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// ShutdownRecognition 关闭所有声道的识别
+func (m *MultiChannel) ShutdownRecognition() {
+	for _, c := range m.channels {
+		c.ShutdownRecognition()
+	}
+}
+
+// TaskID 各声道的任务ID，用逗号分隔
+func (m *MultiChannel) TaskID() string {
+	var ids []string
+	for _, c := range m.channels {
+		if id := c.TaskID(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// Sentences 所有声道按开始时间合并的分句结果，Speaker 为说话人
+func (m *MultiChannel) Sentences() []Sentence {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.sentences
+}
+
+// Close 停止转发各声道的结果，并关闭支持 Close 的识别器
+func (m *MultiChannel) Close() {
+	m.closeOnce.Do(func() { close(m.closed) })
+	for _, c := range m.channels {
+		if cl, ok := c.(interface{ Close() }); ok {
+			cl.Close()
+		}
+	}
+}
+
+func (m *MultiChannel) GetResultChannel() <-chan string          { return m.resultChan }
+func (m *MultiChannel) GetCompleteChannel() <-chan string        { return m.completeChan }
+func (m *MultiChannel) GetErrorChannel() <-chan error            { return m.errorChan }
+func (m *MultiChannel) GetSentenceBeginChannel() <-chan Sentence { return m.sentenceBeginChan }
+func (m *MultiChannel) GetSentenceEndChannel() <-chan Sentence   { return m.sentenceEndChan }
+
+// each 对每个声道并发执行 f，等待全部完成
+func (m *MultiChannel) each(f func(i int, c Recognizer)) {
+	var wg sync.WaitGroup
+	for i, c := range m.channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(i, c)
+		}()
+	}
+	wg.Wait()
+}
+
+// forward 读取一个声道的结果通道，标注说话人后转发
+func (m *MultiChannel) forward(i int, c Recognizer) {
+	var begins, ends <-chan Sentence
+	if sc, ok := c.(SentenceRecognizer); ok {
+		begins, ends = sc.GetSentenceBeginChannel(), sc.GetSentenceEndChannel()
+	}
+	speaker := Speaker(i)
+	for {
+		select {
+		case text := <-c.GetResultChannel():
+			sendOrClose(m.resultChan, speaker+": "+text, m.closed)
+		case s := <-begins:
+			s.Speaker = speaker
+			sendOrClose(m.sentenceBeginChan, s, m.closed)
+		case s := <-ends:
+			s.Speaker = speaker
+			sendOrClose(m.sentenceEndChan, s, m.closed)
+		case text := <-c.GetCompleteChannel():
+			m.finishChannel(i, c, text, nil)
+		case err := <-c.GetErrorChannel():
+			m.finishChannel(i, c, "", err)
+		case <-m.closed:
+			return
+		}
+	}
+}
+
+// finishChannel 记录一个声道的结果，所有声道都完成时发布合并的结果
+func (m *MultiChannel) finishChannel(i int, c Recognizer, text string, err error) {
+	m.mutex.Lock()
+	if !m.active || m.done[i] {
+		m.mutex.Unlock()
+		return
+	}
+	m.done[i], m.texts[i], m.errs[i] = true, text, err
+	if err != nil {
+		logger.Warn("声道识别失败", "speaker", Speaker(i), "task_id", c.TaskID(), "error", err)
+	}
+	if slices.Contains(m.done, false) {
+		m.mutex.Unlock()
+		return
+	}
+	m.active = false
+
+	var sentences []Sentence
+	var errs []error
+	for ch, r := range m.channels {
+		if m.errs[ch] != nil {
+			errs = append(errs, fmt.Errorf("说话人 %s: %w", Speaker(ch), m.errs[ch]))
+			continue
+		}
+		var ss []Sentence
+		if sc, ok := r.(SegmentRecognizer); ok {
+			ss = sc.Sentences()
+		}
+		if len(ss) == 0 && strings.TrimSpace(m.texts[ch]) != "" {
+			// 没有分句时间的识别器，整段结果作为一句话，排在同一时间的最后
+			ss = []Sentence{{Text: m.texts[ch]}}
+		}
+		for _, s := range ss {
+			s.Speaker = Speaker(ch)
+			sentences = append(sentences, s)
+		}
+	}
+	slices.SortStableFunc(sentences, func(a, b Sentence) int { return a.BeginTime - b.BeginTime })
+	lines := make([]string, len(sentences))
+	for k := range sentences {
+		sentences[k].Index = k + 1
+		lines[k] = sentences[k].Speaker + ": " + sentences[k].Text
+	}
+	m.sentences = sentences
+	m.mutex.Unlock()
+
+	if len(errs) == len(m.channels) {
+		sendOrClose(m.errorChan, errors.Join(errs...), m.closed)
+		return
+	}
+	sendOrClose(m.completeChan, strings.Join(lines, "\n"), m.closed)
+}
+
+// sendOrClose 发送到通道，Close 之后放弃发送
+func sendOrClose[T any](ch chan<- T, v T, closed <-chan struct{}) {
+	select {
+	case ch <- v:
+	case <-closed:
+	}
+}
+
+// splitChannels 把交错排列的 16位 PCM 拆成每个声道的单声道 PCM
+func splitChannels(pcm []byte, channels int) [][]byte {
+	frames := len(pcm) / 2 / channels
+	out := make([][]byte, channels)
+	for c := range out {
+		out[c] = make([]byte, frames*2)
+		for i := 0; i < frames; i++ {
+			binary.LittleEndian.PutUint16(out[c][i*2:], binary.LittleEndian.Uint16(pcm[(i*channels+c)*2:]))
+		}
+	}
+	return out
+}
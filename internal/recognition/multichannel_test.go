@@ -0,0 +1,228 @@
+package recognition
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// channelFake 一个声道的假识别器，Stop 时按 sentences 返回结果
+type channelFake struct {
+	mutex     sync.Mutex
+	sent      []byte
+	sentences []Sentence
+	stopErr   error // Stop 后通过错误通道返回
+	finished  bool  // 已经返回结果，之后发送音频返回错误
+
+	results   chan string
+	complete  chan string
+	errors    chan error
+	sentBegin chan Sentence
+	sentEnd   chan Sentence
+}
+
+func newChannelFake(sentences ...Sentence) *channelFake {
+	return &channelFake{
+		sentences: sentences,
+		results:   make(chan string, 10),
+		complete:  make(chan string, 10),
+		errors:    make(chan error, 10),
+		sentBegin: make(chan Sentence, 10),
+		sentEnd:   make(chan Sentence, 10),
+	}
+}
+
+func (f *channelFake) StartRecognitionContext(ctx context.Context) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.finished = false
+	return nil
+}
+func (f *channelFake) SendAudioData(data []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.finished {
+		return errors.New("识别已结束")
+	}
+	f.sent = append(f.sent, data...)
+	return nil
+}
+func (f *channelFake) StopRecognitionContext(ctx context.Context) error {
+	if f.stopErr != nil {
+		f.errors <- f.stopErr
+		return nil
+	}
+	f.finish()
+	return nil
+}
+
+// finish 返回所有句子和最终结果，像识别服务检测到说话结束
+func (f *channelFake) finish() {
+	f.mutex.Lock()
+	f.finished = true
+	f.mutex.Unlock()
+	var texts []string
+	for _, s := range f.sentences {
+		f.sentEnd <- s
+		texts = append(texts, s.Text)
+	}
+	f.complete <- strings.Join(texts, "")
+}
+func (f *channelFake) ShutdownRecognition()                     {}
+func (f *channelFake) TaskID() string                           { return "" }
+func (f *channelFake) Sentences() []Sentence                    { return f.sentences }
+func (f *channelFake) GetResultChannel() <-chan string          { return f.results }
+func (f *channelFake) GetCompleteChannel() <-chan string        { return f.complete }
+func (f *channelFake) GetErrorChannel() <-chan error            { return f.errors }
+func (f *channelFake) GetSentenceBeginChannel() <-chan Sentence { return f.sentBegin }
+func (f *channelFake) GetSentenceEndChannel() <-chan Sentence   { return f.sentEnd }
+
+func TestMultiChannel_Merge(t *testing.T) {
+	a := newChannelFake(Sentence{Index: 1, BeginTime: 0, EndTime: 900, Text: "你好"}, Sentence{Index: 2, BeginTime: 3000, EndTime: 4000, Text: "再见"})
+	b := newChannelFake(Sentence{Index: 1, BeginTime: 1000, EndTime: 2500, Text: "请坐"})
+	m := NewMultiChannel([]Recognizer{a, b})
+	defer m.Close()
+
+	if err := m.StartRecognitionContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 双声道交错：A 声道为 1，B 声道为 2
+	pcm := make([]byte, 8)
+	binary.LittleEndian.PutUint16(pcm[0:], 1)
+	binary.LittleEndian.PutUint16(pcm[2:], 2)
+	binary.LittleEndian.PutUint16(pcm[4:], 1)
+	binary.LittleEndian.PutUint16(pcm[6:], 2)
+	if err := m.SendAudioData(pcm); err != nil {
+		t.Fatal(err)
+	}
+	if string(a.sent) != "\x01\x00\x01\x00" || string(b.sent) != "\x02\x00\x02\x00" {
+		t.Errorf("声道拆分错误: %v %v", a.sent, b.sent)
+	}
+
+	b.results <- "请"
+	select {
+	case text := <-m.GetResultChannel():
+		if text != "B: 请" {
+			t.Errorf("中间结果应标注说话人: %q", text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("等待中间结果超时")
+	}
+
+	if err := m.StopRecognitionContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	speakers := map[string]int{}
+	for range 3 {
+		select {
+		case s := <-m.GetSentenceEndChannel():
+			speakers[s.Speaker]++
+		case <-time.After(time.Second):
+			t.Fatal("等待句子结束超时")
+		}
+	}
+	if speakers["A"] != 2 || speakers["B"] != 1 {
+		t.Errorf("句子的说话人错误: %v", speakers)
+	}
+
+	select {
+	case text := <-m.GetCompleteChannel():
+		if want := "A: 你好\nB: 请坐\nA: 再见"; text != want {
+			t.Errorf("合并结果 %q，期望 %q", text, want)
+		}
+	case err := <-m.GetErrorChannel():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("等待最终结果超时")
+	}
+	ss := m.Sentences()
+	if len(ss) != 3 || ss[1].Speaker != "B" || ss[1].Index != 2 || ss[2].Index != 3 {
+		t.Errorf("合并的分句错误: %+v", ss)
+	}
+}
+
+func TestMultiChannel_Errors(t *testing.T) {
+	// 一个声道失败时仍然返回其他声道的结果
+	a := newChannelFake(Sentence{Text: "你好"})
+	b := newChannelFake()
+	b.stopErr = errors.New("连接断开")
+	m := NewMultiChannel([]Recognizer{a, b})
+	defer m.Close()
+	m.StartRecognitionContext(context.Background())
+	m.StopRecognitionContext(context.Background())
+	select {
+	case text := <-m.GetCompleteChannel():
+		if text != "A: 你好" {
+			t.Errorf("结果 %q", text)
+		}
+	case err := <-m.GetErrorChannel():
+		t.Fatalf("一个声道失败不应返回错误: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("等待最终结果超时")
+	}
+
+	// 所有声道都失败时返回错误
+	a.stopErr = errors.New("超时")
+	m.StartRecognitionContext(context.Background())
+	m.StopRecognitionContext(context.Background())
+	select {
+	case text := <-m.GetCompleteChannel():
+		t.Fatalf("所有声道失败时应返回错误，实际结果 %q", text)
+	case err := <-m.GetErrorChannel():
+		if !strings.Contains(err.Error(), "说话人 A: 超时") || !strings.Contains(err.Error(), "说话人 B: 连接断开") {
+			t.Errorf("错误应包含每个说话人的原因: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("等待错误超时")
+	}
+}
+
+func TestMultiChannel_EarlyFinish(t *testing.T) {
+	// 一个声道提前结束后，其他声道继续接收音频，发送不返回错误
+	a := newChannelFake(Sentence{Text: "你好"})
+	b := newChannelFake(Sentence{Text: "请坐"})
+	m := NewMultiChannel([]Recognizer{a, b})
+	defer m.Close()
+	if err := m.StartRecognitionContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	a.finish()
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mutex.Lock()
+		done := m.done[0]
+		m.mutex.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("等待声道 A 完成超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := m.SendAudioData([]byte{1, 0, 2, 0}); err != nil {
+		t.Fatalf("已完成的声道不应再发送: %v", err)
+	}
+	if len(a.sent) != 0 || string(b.sent) != "\x02\x00" {
+		t.Errorf("发送的数据错误: A %v B %v", a.sent, b.sent)
+	}
+
+	if err := m.StopRecognitionContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case text := <-m.GetCompleteChannel():
+		if text != "A: 你好\nB: 请坐" {
+			t.Errorf("结果 %q", text)
+		}
+	case err := <-m.GetErrorChannel():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("等待最终结果超时")
+	}
+}
@@ -0,0 +1,311 @@
+package recognition
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/audio"
+)
+
+// OpenAI 兼容后端：对接实现了 OpenAI /v1/audio/transcriptions 接口的服务（官方或自建的 whisper 服务）。
+// 这是非流式的识别：Start 后音频缓存在本地，Stop 时封装为 WAV 一次性上传，最终结果通过完成通道返回，
+// 没有中间结果。上传和识别受 Timeouts.Stop 约束，CPU 上运行的自建服务可能需要调大 -stop-timeout。
+// 接口拒绝超过 25MB 的文件，缓存的音频达到 openAIMaxAudio（16kHz 单声道约 13 分钟）时自动结束识别。
+// 服务没有语音检测，StartParam.EnableVoiceDetection 开启时在本地按静音门限检测（见 audio.Endpointer）：
+// 说话后静音超过 MaxEndSilence 自动结束识别；开始后 MaxStartSilence 内没有说话时自动结束，不上传音频，返回空结果。
+//
+// 接口文档：https://platform.openai.com/docs/api-reference/audio/createTranscription
+
+// DefaultOpenAIBaseURL 默认的 OpenAI 接口地址
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// openAIMaxAudio 一次识别最多缓存的 PCM 字节数，接口的上传限制为 25MB，留出 WAV 头和表单字段的空间
+const openAIMaxAudio = 25<<20 - 64<<10
+
+// OpenAIConfig OpenAI 兼容服务配置
+type OpenAIConfig struct {
+	BaseURL  string // 接口地址，如 http://127.0.0.1:8000/v1，为空时使用 DefaultOpenAIBaseURL
+	APIKey   string // 为空时不发送 Authorization 头
+	Model    string // 模型名称，为空时使用 whisper-1
+	Language string // 音频语言（ISO-639-1，如 zh），为空时由服务自动检测
+	Prompt   string // 提示词，可用于提供专有名词
+}
+
+// openAIResponse 识别结果，response_format=json
+type openAIResponse struct {
+	Text  string `json:"text"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// OpenAIClient OpenAI 兼容识别客户端，实现 Recognizer 接口
+type OpenAIClient struct {
+	config       *OpenAIConfig
+	startParam   *StartParam
+	timeouts     Timeouts
+	httpClient   *http.Client
+	resultChan   chan string
+	completeChan chan string
+	errorChan    chan error
+	state        *stateMachine
+	taskID       atomic.Value // 当前识别任务ID(string)，本地生成
+
+	mutex    sync.Mutex
+	pcm      bytes.Buffer       // 本次识别的音频
+	maxAudio int                // pcm 的上限，默认 openAIMaxAudio
+	cancel   context.CancelFunc // 取消正在进行的上传
+	silence  float64            // 静音门限（dBFS），默认 audio.QuietPeak
+	endpoint *audio.Endpointer  // 本次识别的说话结束检测，没有开启语音检测时为 nil
+}
+
+// NewOpenAIClient 创建 OpenAI 兼容识别客户端
+func NewOpenAIClient(cfg *OpenAIConfig, startParam *StartParam) (*OpenAIClient, error) {
+	if cfg == nil {
+		return nil, errors.New("未配置 OpenAI 兼容服务")
+	}
+	c := *cfg
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultOpenAIBaseURL
+	}
+	if c.Model == "" {
+		c.Model = "whisper-1"
+	}
+	if !strings.EqualFold(startParam.Format, "pcm") {
+		return nil, fmt.Errorf("OpenAI 兼容后端只支持 PCM 音频，当前为 %s", startParam.Format)
+	}
+	return &OpenAIClient{
+		config:       &c,
+		startParam:   startParam,
+		timeouts:     DefaultTimeouts(),
+		httpClient:   &http.Client{},
+		resultChan:   make(chan string, 10),
+		completeChan: make(chan string, 10),
+		errorChan:    make(chan error, 10),
+		state:        newStateMachine(),
+		maxAudio:     openAIMaxAudio,
+		silence:      audio.QuietPeak,
+	}, nil
+}
+
+// SetSilenceThreshold 设置本地语音检测的静音门限（dBFS），通常为电平表的 SilenceThreshold，下次识别生效
+func (oc *OpenAIClient) SetSilenceThreshold(dbfs float64) {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+	oc.silence = dbfs
+}
+
+// SetTimeouts 修改超时时间，下次识别生效；只有 Stop 对该后端生效
+func (oc *OpenAIClient) SetTimeouts(t Timeouts) {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+	oc.timeouts = t.withDefaults()
+}
+
+// StartRecognitionContext 开始缓存一次识别的音频，不需要连接
+func (oc *OpenAIClient) StartRecognitionContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("StartRecognition 已取消: %w", err)
+	}
+	if err := oc.state.Transition(StateConnecting); err != nil {
+		return fmt.Errorf("StartRecognition 重复启动: %w", err)
+	}
+	oc.mutex.Lock()
+	oc.pcm.Reset()
+	oc.endpoint = nil
+	if p := oc.startParam; p.EnableVoiceDetection {
+		oc.endpoint = audio.NewEndpointer(p.SampleRate, oc.silence,
+			time.Duration(p.MaxStartSilence)*time.Millisecond, time.Duration(p.MaxEndSilence)*time.Millisecond)
+	}
+	oc.mutex.Unlock()
+	oc.taskID.Store(newTaskID())
+	oc.state.Transition(StateStreaming)
+	return nil
+}
+
+// SendAudioData 缓存音频数据，达到上传限制或检测到说话结束时在后台自动结束识别
+// 达到上传限制时只保留限制以内的部分
+func (oc *OpenAIClient) SendAudioData(data []byte) error {
+	if oc.state.Current() != StateStreaming {
+		return fmt.Errorf("语音识别未连接")
+	}
+	oc.mutex.Lock()
+	room := oc.maxAudio - oc.pcm.Len()
+	full := len(data) >= room
+	if full {
+		data = data[:max(room, 0)]
+	}
+	oc.pcm.Write(data)
+	ended, heard := false, true
+	if oc.endpoint != nil {
+		ended, heard = oc.endpoint.Add(data), oc.endpoint.Heard()
+		if ended && !heard {
+			// 一直没有说话，不需要上传
+			oc.pcm.Reset()
+		}
+	}
+	oc.mutex.Unlock()
+
+	switch {
+	case full:
+		logger.Warn("音频达到 OpenAI 接口的上传限制，自动结束识别", "task_id", oc.TaskID(), "bytes", oc.maxAudio)
+	case ended && heard:
+		logger.Debug("检测到说话结束，自动结束识别", "task_id", oc.TaskID())
+	case ended:
+		logger.Debug("开始后一直没有说话，自动结束识别", "task_id", oc.TaskID())
+	default:
+		return nil
+	}
+	// 结果和错误照常通过完成通道和错误通道返回
+	go oc.StopRecognitionContext(context.Background())
+	return nil
+}
+
+// StopRecognitionContext 上传缓存的音频并等待识别结果
+// 服务返回的错误通过错误通道报告，ctx 取消或超时返回错误
+func (oc *OpenAIClient) StopRecognitionContext(ctx context.Context) error {
+	if !oc.state.TransitionFrom(StateFinishing, StateStreaming) {
+		return nil
+	}
+
+	oc.mutex.Lock()
+	ctx, cancel := context.WithTimeout(ctx, oc.timeouts.Stop)
+	defer cancel()
+	oc.cancel = cancel
+	pcm := append([]byte(nil), oc.pcm.Bytes()...)
+	oc.pcm.Reset()
+	oc.mutex.Unlock()
+
+	if len(pcm) == 0 {
+		if oc.state.TransitionFrom(StateClosed, StateFinishing) {
+			oc.completeChan <- ""
+		}
+		return nil
+	}
+
+	text, err := oc.transcribe(ctx, pcm)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// 超时或被 ShutdownRecognition 取消
+		if oc.state.TransitionFrom(StateClosed, StateFinishing) {
+			return fmt.Errorf("等待识别结果失败: %w", ctxErr)
+		}
+		return nil
+	}
+	if err != nil {
+		if oc.state.TransitionFrom(StateFailed, StateFinishing) {
+			oc.errorChan <- fmt.Errorf("识别失败: %w", err)
+		}
+		return nil
+	}
+	if oc.state.TransitionFrom(StateClosed, StateFinishing) {
+		oc.completeChan <- text
+	}
+	return nil
+}
+
+// ShutdownRecognition 放弃当前识别，取消正在进行的上传
+func (oc *OpenAIClient) ShutdownRecognition() {
+	oc.mutex.Lock()
+	if oc.cancel != nil {
+		oc.cancel()
+		oc.cancel = nil
+	}
+	oc.pcm.Reset()
+	oc.mutex.Unlock()
+	oc.state.TransitionFrom(StateClosed, StateConnecting, StateStreaming, StateFinishing, StateFailed)
+}
+
+// TaskID 返回最近一次识别任务的ID
+func (oc *OpenAIClient) TaskID() string {
+	id, _ := oc.taskID.Load().(string)
+	return id
+}
+
+// State 返回当前会话状态
+func (oc *OpenAIClient) State() SessionState {
+	return oc.state.Current()
+}
+
+// GetResultChannel 获取结果通道，该后端没有中间结果
+func (oc *OpenAIClient) GetResultChannel() <-chan string {
+	return oc.resultChan
+}
+
+// GetCompleteChannel 获取完成通道
+func (oc *OpenAIClient) GetCompleteChannel() <-chan string {
+	return oc.completeChan
+}
+
+// GetErrorChannel 获取错误通道
+func (oc *OpenAIClient) GetErrorChannel() <-chan error {
+	return oc.errorChan
+}
+
+// transcribe 把 PCM 封装为 WAV 上传，返回识别文本
+func (oc *OpenAIClient) transcribe(ctx context.Context, pcm []byte) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="audio.wav"`)
+	h.Set("Content-Type", "audio/wav")
+	fw, err := mw.CreatePart(h)
+	if err != nil {
+		return "", err
+	}
+	fw.Write(audio.EncodeWAV(pcm, oc.startParam.SampleRate, 1))
+	mw.WriteField("model", oc.config.Model)
+	mw.WriteField("response_format", "json")
+	if oc.config.Language != "" {
+		mw.WriteField("language", oc.config.Language)
+	}
+	if oc.config.Prompt != "" {
+		mw.WriteField("prompt", oc.config.Prompt)
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	url := strings.TrimRight(oc.config.BaseURL, "/") + "/audio/transcriptions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if oc.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+oc.config.APIKey)
+	}
+
+	resp, err := oc.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求识别服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("读取识别结果失败: %w", err)
+	}
+
+	var result openAIResponse
+	jsonErr := json.Unmarshal(data, &result)
+	if resp.StatusCode != http.StatusOK {
+		if jsonErr == nil && result.Error != nil {
+			return "", fmt.Errorf("识别服务返回 %d: %s", resp.StatusCode, result.Error.Message)
+		}
+		return "", fmt.Errorf("识别服务返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if jsonErr != nil {
+		return "", fmt.Errorf("解析识别结果失败: %w", jsonErr)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
@@ -0,0 +1,243 @@
+package recognition
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newOpenAIServer 模拟 /v1/audio/transcriptions，返回收到的 WAV 字节数
+func newOpenAIServer(t *testing.T, handler http.HandlerFunc) *OpenAIClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	oc, err := NewOpenAIClient(&OpenAIConfig{BaseURL: srv.URL + "/v1/", APIKey: "sk-test", Language: "zh"}, DefaultStartParam())
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	return oc
+}
+
+func TestOpenAIClient_Transcribe(t *testing.T) {
+	oc := newOpenAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "audio.wav" || string(data[:4]) != "RIFF" || len(data) != 44+6 {
+			http.Error(w, "bad wav", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "zh" {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"text": " 你好世界 "})
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := oc.StartRecognitionContext(context.Background()); err != nil {
+			t.Fatalf("开始识别失败: %v", err)
+		}
+		oc.SendAudioData([]byte{1, 2, 3, 4})
+		oc.SendAudioData([]byte{5, 6})
+		if err := oc.StopRecognitionContext(context.Background()); err != nil {
+			t.Fatalf("停止识别失败: %v", err)
+		}
+		select {
+		case text := <-oc.GetCompleteChannel():
+			if text != "你好世界" {
+				t.Errorf("期望结果为 你好世界，实际为 %q", text)
+			}
+		case err := <-oc.GetErrorChannel():
+			t.Fatalf("识别失败: %v", err)
+		}
+		oc.ShutdownRecognition()
+	}
+}
+
+func TestOpenAIClient_MaxAudio(t *testing.T) {
+	received := make(chan int, 1)
+	oc := newOpenAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		received <- len(data) - 44
+		json.NewEncoder(w).Encode(map[string]string{"text": "你好"})
+	})
+	oc.maxAudio = 6
+
+	oc.StartRecognitionContext(context.Background())
+	oc.SendAudioData([]byte{1, 2, 3, 4})
+	// 超出上限的部分被丢弃，并自动结束识别
+	if err := oc.SendAudioData([]byte{5, 6, 7, 8}); err != nil {
+		t.Fatalf("达到上限时不应返回错误: %v", err)
+	}
+	select {
+	case text := <-oc.GetCompleteChannel():
+		if text != "你好" {
+			t.Errorf("期望结果为 你好，实际为 %q", text)
+		}
+	case err := <-oc.GetErrorChannel():
+		t.Fatalf("识别失败: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("达到上限后没有自动结束识别")
+	}
+	if n := <-received; n != 6 {
+		t.Errorf("期望上传 6 字节音频，实际 %d", n)
+	}
+	if err := oc.SendAudioData([]byte{9, 10}); err == nil {
+		t.Error("自动结束后不应再接收音频")
+	}
+}
+
+// sine 生成 d 时长的 16kHz 单声道正弦波，amp 为 0 时为静音
+func sine(d time.Duration, amp float64) []byte {
+	n := int(d * 16000 / time.Second)
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(amp*math.Sin(2*math.Pi*440*float64(i)/16000))))
+	}
+	return pcm
+}
+
+// sendFor 按 100ms 一段发送 d 时长的音频
+func sendFor(oc *OpenAIClient, d time.Duration, amp float64) {
+	for i := time.Duration(0); i < d; i += 100 * time.Millisecond {
+		oc.SendAudioData(sine(100*time.Millisecond, amp))
+	}
+}
+
+func TestOpenAIClient_Endpoint(t *testing.T) {
+	received := make(chan int, 1)
+	oc := newOpenAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		received <- len(data) - 44
+		json.NewEncoder(w).Encode(map[string]string{"text": "你好"})
+	})
+
+	// 默认 max_end_silence 为 3 秒，静音门限为 -45 dBFS（幅度约 185）
+	oc.StartRecognitionContext(context.Background())
+	sendFor(oc, time.Second, 8000)
+	sendFor(oc, 2900*time.Millisecond, 100)
+	select {
+	case <-oc.GetCompleteChannel():
+		t.Fatal("静音不到 max_end_silence 时不应结束识别")
+	case <-time.After(100 * time.Millisecond):
+	}
+	sendFor(oc, 200*time.Millisecond, 0)
+	select {
+	case text := <-oc.GetCompleteChannel():
+		if text != "你好" {
+			t.Errorf("期望结果为 你好，实际为 %q", text)
+		}
+	case err := <-oc.GetErrorChannel():
+		t.Fatalf("识别失败: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("说话后静音超过 max_end_silence 没有自动结束识别")
+	}
+	if n := <-received; n < len(sine(4*time.Second, 0)) {
+		t.Errorf("应上传说话和之后的静音，实际 %d 字节", n)
+	}
+}
+
+func TestOpenAIClient_StartSilence(t *testing.T) {
+	oc := newOpenAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("一直没有说话时不应请求识别服务")
+	})
+	oc.SetSilenceThreshold(-30)
+	oc.StartRecognitionContext(context.Background())
+	// 低于 -30 dBFS 门限的声音视为静音，默认 max_start_silence 为 5 秒
+	sendFor(oc, 5*time.Second, 500)
+	select {
+	case text := <-oc.GetCompleteChannel():
+		if text != "" {
+			t.Errorf("没有说话时期望空结果，实际为 %q", text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("开始后一直没有说话，没有自动结束识别")
+	}
+
+	// 关闭语音检测时一直缓存到 Stop
+	param := *DefaultStartParam()
+	param.EnableVoiceDetection = false
+	oc.startParam = &param
+	oc.StartRecognitionContext(context.Background())
+	sendFor(oc, 6*time.Second, 0)
+	if s := oc.State(); s != StateStreaming {
+		t.Errorf("关闭语音检测时不应自动结束，实际状态 %s", s)
+	}
+	oc.ShutdownRecognition()
+}
+
+func TestOpenAIClient_EmptyAudio(t *testing.T) {
+	oc := newOpenAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("没有音频时不应请求识别服务")
+	})
+	oc.StartRecognitionContext(context.Background())
+	oc.StopRecognitionContext(context.Background())
+	if text := <-oc.GetCompleteChannel(); text != "" {
+		t.Errorf("没有音频时期望空结果，实际为 %q", text)
+	}
+}
+
+func TestOpenAIClient_ServerError(t *testing.T) {
+	oc := newOpenAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"Incorrect API key provided"}}`))
+	})
+	oc.StartRecognitionContext(context.Background())
+	oc.SendAudioData([]byte{1, 2})
+	if err := oc.StopRecognitionContext(context.Background()); err != nil {
+		t.Fatalf("服务返回的错误应通过错误通道报告，实际返回 %v", err)
+	}
+	select {
+	case err := <-oc.GetErrorChannel():
+		if err == nil || oc.State() != StateFailed {
+			t.Errorf("期望 Failed 和错误信息，实际为 %v, %s", err, oc.State())
+		}
+	default:
+		t.Fatal("应通过错误通道报告失败")
+	}
+}
+
+func TestOpenAIClient_StopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	oc := newOpenAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	oc.SetTimeouts(Timeouts{Stop: 50 * time.Millisecond})
+
+	oc.StartRecognitionContext(context.Background())
+	oc.SendAudioData([]byte{1, 2})
+	if err := oc.StopRecognitionContext(context.Background()); err == nil {
+		t.Fatal("识别服务无响应时应超时")
+	}
+	if oc.State() != StateClosed {
+		t.Errorf("超时后期望 Closed，实际为 %s", oc.State())
+	}
+}
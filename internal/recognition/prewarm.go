@@ -0,0 +1,525 @@
+package recognition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 连接预热：
+// 每次识别都要经过WS握手和 StartRecognition 才能发送音频，按键说话时开头的字会延迟甚至丢失。
+// Prewarmer 在后台保持一个已开始识别、尚未发送音频的连接，下一次识别直接使用它；
+// 没有可用的预热连接时（刚被用掉或正在刷新）识别也立即开始，音频先缓存在本地，连接完成后补发。
+//
+// 识别服务 10 秒收不到音频会返回 IDLE_TIMEOUT，WS最长空闲 60 秒，
+// 所以预热连接每隔 refresh（默认 8 秒）重建一次。预热会一直占用一个连接，默认不开启。
+//
+// 一句话识别按次计费，每个预热连接都是一次识别请求，没有被使用就关闭的也计费：
+// 默认 8 秒刷新一次，空闲时每小时约 450 次。这些请求通过 SetOnDiscard 交给调用方记录用量。
+
+// DefaultPrewarmRefresh 预热连接的默认刷新间隔，需要小于识别服务 10 秒的无数据超时
+const DefaultPrewarmRefresh = 8 * time.Second
+
+// warmConn 一个预热中或正在使用的连接
+type warmConn struct {
+	client  *AliyunClient
+	param   StartParam
+	readyAt time.Time
+	ready   bool          // 识别已开始
+	used    bool          // 曾被用于识别，没有用过就关闭的连接通过 onDiscard 报告
+	err     error         // 连接失败的原因
+	done    chan struct{} // 连接完成（成功或失败）时关闭
+	pending [][]byte      // done 关闭前缓存的音频，连接完成后补发
+}
+
+func (w *warmConn) finished() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Prewarmer 带连接预热的识别器，实现 Recognizer 接口
+type Prewarmer struct {
+	newClient func(param *StartParam) (*AliyunClient, error)
+	refresh   time.Duration
+	onDiscard func(taskID string) // 见 SetOnDiscard，受 mutex 保护
+
+	resultChan   chan string
+	completeChan chan string
+	errorChan    chan error
+
+	mutex     sync.Mutex
+	param     StartParam
+	active    *warmConn       // 当前识别使用的连接
+	taskID    string          // 上一次识别的任务ID
+	sentences []Sentence      // 上一次识别的分句结果
+	warm      *warmConn       // 预热的连接，可能还在连接中
+	free      []*AliyunClient // 空闲的客户端，每个客户端同时只能有一个连接
+	kick      chan struct{}   // 唤醒预热 goroutine
+	ctx       context.Context // Close 时取消
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewPrewarmer 创建带连接预热的阿里云识别器，refresh 为 0 时使用 DefaultPrewarmRefresh
+func NewPrewarmer(cfg *AliyunConfig, startParam StartParam, timeouts Timeouts, refresh time.Duration) (*Prewarmer, error) {
+	p := newPrewarmer(func(param *StartParam) (*AliyunClient, error) {
+		c, err := NewAliyunClient(cfg, param)
+		if err != nil {
+			return nil, err
+		}
+		c.SetTimeouts(timeouts)
+		return c, nil
+	}, startParam, refresh)
+
+	// 先创建一个客户端，让配置错误在启动时暴露
+	c, err := p.acquire()
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.mutex.Lock()
+	p.free = append(p.free, c)
+	p.mutex.Unlock()
+
+	p.wg.Add(1)
+	go p.warmLoop()
+	return p, nil
+}
+
+func newPrewarmer(newClient func(param *StartParam) (*AliyunClient, error), startParam StartParam, refresh time.Duration) *Prewarmer {
+	if refresh <= 0 {
+		refresh = DefaultPrewarmRefresh
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Prewarmer{
+		newClient:    newClient,
+		refresh:      refresh,
+		resultChan:   make(chan string, 10),
+		completeChan: make(chan string, 10),
+		errorChan:    make(chan error, 10),
+		param:        startParam,
+		kick:         make(chan struct{}, 1),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// SetStartParam 修改识别参数，参数不同的预热连接会被丢弃
+func (p *Prewarmer) SetStartParam(param StartParam) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.param = param
+	p.kickLocked()
+}
+
+// SetOnDiscard 设置预热连接没有被使用就关闭时的回调，参数为该连接的任务ID
+// 这些连接也是计费的识别请求，调用方可以在回调中记录用量
+func (p *Prewarmer) SetOnDiscard(f func(taskID string)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.onDiscard = f
+}
+
+// StartRecognitionContext 开始识别，不等待连接完成
+// 有预热连接时直接使用，否则在后台连接，连接完成前的音频缓存在本地；连接失败通过错误通道报告
+func (p *Prewarmer) StartRecognitionContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("StartRecognition 已取消: %w", err)
+	}
+	if p.ctx.Err() != nil {
+		return errors.New("识别器已关闭")
+	}
+
+	p.mutex.Lock()
+	if p.active != nil {
+		p.mutex.Unlock()
+		return errors.New("StartRecognition 重复启动")
+	}
+	w := p.warm
+	p.warm = nil
+	var stale *warmConn
+	if w != nil && (w.err != nil || w.param != p.param || (w.ready && time.Since(w.readyAt) >= p.refresh)) {
+		stale, w = w, nil
+	}
+	if w != nil {
+		w.used = true
+		p.active = w
+		p.kickLocked()
+		p.mutex.Unlock()
+		return nil
+	}
+	owned := stale != nil && stale.finished()
+	p.mutex.Unlock()
+	if stale != nil {
+		p.release(stale, owned)
+	}
+
+	// 没有可用的预热连接
+	c, err := p.acquire()
+	if err != nil {
+		return fmt.Errorf("StartRecognition 创建客户端失败: %w", err)
+	}
+	p.mutex.Lock()
+	p.active = p.connect(c, p.param)
+	p.active.used = true
+	p.kickLocked()
+	p.mutex.Unlock()
+	return nil
+}
+
+// SendAudioData 发送音频数据，连接完成并补发完缓存的音频之前缓存在本地
+// 只在持有 mutex 时缓存，发送不持有 mutex，不会因为网络阻塞 TaskID 等调用
+func (p *Prewarmer) SendAudioData(data []byte) error {
+	p.mutex.Lock()
+	w := p.active
+	if w == nil {
+		p.mutex.Unlock()
+		return fmt.Errorf("语音识别未连接")
+	}
+	if w.err != nil {
+		p.mutex.Unlock()
+		return w.err
+	}
+	if !w.finished() {
+		w.pending = append(w.pending, append([]byte(nil), data...))
+		p.mutex.Unlock()
+		return nil
+	}
+	p.mutex.Unlock()
+	return w.client.SendAudioData(data)
+}
+
+// StopRecognitionContext 停止识别，等待连接完成、缓存的音频发送完毕后再停止
+func (p *Prewarmer) StopRecognitionContext(ctx context.Context) error {
+	p.mutex.Lock()
+	w := p.active
+	p.mutex.Unlock()
+	if w == nil {
+		return nil
+	}
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		p.ShutdownRecognition()
+		return fmt.Errorf("等待连接完成失败: %w", ctx.Err())
+	}
+	if w.err != nil {
+		// 连接失败已经通过错误通道报告
+		return nil
+	}
+	return w.client.StopRecognitionContext(ctx)
+}
+
+// ShutdownRecognition 关闭当前识别的连接，不影响预热连接
+func (p *Prewarmer) ShutdownRecognition() {
+	p.mutex.Lock()
+	w := p.active
+	p.active = nil
+	owned := w != nil && w.finished()
+	if w != nil {
+		p.taskID = w.client.TaskID()
+		p.sentences = w.client.Sentences()
+	}
+	p.mutex.Unlock()
+	if w != nil {
+		p.release(w, owned)
+	}
+}
+
+// TaskID 返回当前识别的任务ID，识别结束后返回上一次识别的任务ID
+func (p *Prewarmer) TaskID() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.active == nil {
+		return p.taskID
+	}
+	return p.active.client.TaskID()
+}
+
+// Sentences 当前识别的分句结果，识别结束后返回上一次识别的结果
+func (p *Prewarmer) Sentences() []Sentence {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.active == nil {
+		return p.sentences
+	}
+	return p.active.client.Sentences()
+}
+
+// GetResultChannel 获取结果通道
+func (p *Prewarmer) GetResultChannel() <-chan string {
+	return p.resultChan
+}
+
+// GetCompleteChannel 获取完成通道
+func (p *Prewarmer) GetCompleteChannel() <-chan string {
+	return p.completeChan
+}
+
+// GetErrorChannel 获取错误通道
+func (p *Prewarmer) GetErrorChannel() <-chan error {
+	return p.errorChan
+}
+
+// Close 关闭所有连接并停止预热
+func (p *Prewarmer) Close() {
+	p.cancel()
+	p.ShutdownRecognition()
+	p.mutex.Lock()
+	w := p.warm
+	p.warm = nil
+	owned := w != nil && w.finished()
+	p.mutex.Unlock()
+	if w != nil {
+		p.release(w, owned)
+	}
+	p.wg.Wait()
+}
+
+// acquire 取一个空闲的客户端，没有时创建新的
+func (p *Prewarmer) acquire() (*AliyunClient, error) {
+	p.mutex.Lock()
+	if n := len(p.free); n > 0 {
+		c := p.free[n-1]
+		p.free = p.free[:n-1]
+		p.mutex.Unlock()
+		return c, nil
+	}
+	p.mutex.Unlock()
+
+	c, err := p.newClient(&StartParam{})
+	if err != nil {
+		return nil, err
+	}
+	p.wg.Add(1)
+	go p.forward(c)
+	return c, nil
+}
+
+// connect 在后台开始识别，调用方需持有 mutex，并在释放 mutex 前把返回值放到 active 或 warm
+func (p *Prewarmer) connect(c *AliyunClient, param StartParam) *warmConn {
+	*c.startParam = param
+	w := &warmConn{client: c, param: param, done: make(chan struct{})}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		err := c.StartRecognitionContext(p.ctx)
+
+		p.mutex.Lock()
+		w.err = err
+		if err == nil {
+			w.ready = true
+			w.readyAt = time.Now()
+		}
+		if err == nil {
+			p.flush(w)
+		}
+		active, warm := w == p.active, w == p.warm
+		if warm && err != nil {
+			p.warm = nil
+			warm = false
+			p.kickLocked()
+		}
+		close(w.done)
+		p.mutex.Unlock()
+
+		switch {
+		case active:
+			if err != nil {
+				p.sendError(fmt.Errorf("连接识别服务失败: %w", err))
+			}
+		case warm:
+		default:
+			// 连接期间已被丢弃，由这里负责释放
+			c.ShutdownRecognition()
+			if err == nil {
+				p.discarded(w)
+			}
+			p.putFree(c)
+		}
+	}()
+	return w
+}
+
+// flush 补发连接期间缓存的音频，调用方需持有 mutex，发送期间释放 mutex
+// done 关闭前 SendAudioData 继续把新的音频追加到 pending，由这里按顺序发送，直到没有剩余
+func (p *Prewarmer) flush(w *warmConn) {
+	for len(w.pending) > 0 && w == p.active {
+		pending := w.pending
+		w.pending = nil
+		p.mutex.Unlock()
+		var err error
+		for _, data := range pending {
+			if err = w.client.SendAudioData(data); err != nil {
+				break
+			}
+		}
+		p.mutex.Lock()
+		if err != nil {
+			logger.Warn("发送缓存的音频数据失败", "task_id", w.client.TaskID(), "error", err)
+			break
+		}
+	}
+	w.pending = nil
+}
+
+// discarded 报告一个没有被使用就关闭的预热连接
+func (p *Prewarmer) discarded(w *warmConn) {
+	if w.used {
+		return
+	}
+	p.mutex.Lock()
+	f := p.onDiscard
+	p.mutex.Unlock()
+	if f != nil {
+		f(w.client.TaskID())
+	}
+}
+
+// release 关闭已从 active/warm 中移除的连接
+// owned 表示移除时连接已经完成（需在移除的同一次持有 mutex 时判断），由这里回收客户端；
+// 仍在连接中的由 connect 的 goroutine 负责回收
+func (p *Prewarmer) release(w *warmConn, owned bool) {
+	w.client.ShutdownRecognition()
+	if owned {
+		if w.ready {
+			p.discarded(w)
+		}
+		p.putFree(w.client)
+	}
+}
+
+func (p *Prewarmer) putFree(c *AliyunClient) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.free = append(p.free, c)
+}
+
+// warmLoop 保持一个预热连接，过期前重建
+func (p *Prewarmer) warmLoop() {
+	defer p.wg.Done()
+	for {
+		wait := p.refresh
+		p.mutex.Lock()
+		need := p.warm == nil
+		if w := p.warm; w != nil && w.ready {
+			wait = p.refresh - time.Since(w.readyAt)
+		}
+		p.mutex.Unlock()
+
+		if need {
+			if c, err := p.acquire(); err != nil {
+				logger.Warn("预热连接失败", "error", err)
+			} else {
+				p.mutex.Lock()
+				if p.warm == nil && p.ctx.Err() == nil {
+					p.warm = p.connect(c, p.param)
+				} else {
+					p.free = append(p.free, c)
+				}
+				p.mutex.Unlock()
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-p.kick:
+		case <-p.ctx.Done():
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+
+		// 过期或参数已修改的预热连接需要重建
+		p.mutex.Lock()
+		w := p.warm
+		if w != nil && w.ready && (time.Since(w.readyAt) >= p.refresh || w.param != p.param) {
+			p.warm = nil
+		} else {
+			w = nil
+		}
+		p.mutex.Unlock()
+		if w != nil {
+			// ready 的连接一定已经完成
+			p.release(w, true)
+		}
+	}
+}
+
+// forward 把客户端的结果转发给当前识别，预热连接上的完成和错误说明连接已失效
+func (p *Prewarmer) forward(c *AliyunClient) {
+	defer p.wg.Done()
+	for {
+		select {
+		case text := <-c.GetResultChannel():
+			if p.isActive(c) {
+				p.send(p.resultChan, text)
+			}
+		case text := <-c.GetCompleteChannel():
+			if p.isActive(c) {
+				p.send(p.completeChan, text)
+			} else {
+				p.dropWarm(c)
+			}
+		case err := <-c.GetErrorChannel():
+			if p.isActive(c) {
+				p.sendError(err)
+			} else {
+				p.dropWarm(c)
+			}
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Prewarmer) isActive(c *AliyunClient) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.active != nil && p.active.client == c
+}
+
+// dropWarm 丢弃已失效的预热连接
+func (p *Prewarmer) dropWarm(c *AliyunClient) {
+	p.mutex.Lock()
+	w := p.warm
+	if w == nil || w.client != c || !w.ready {
+		p.mutex.Unlock()
+		return
+	}
+	p.warm = nil
+	p.kickLocked()
+	p.mutex.Unlock()
+	p.release(w, true)
+}
+
+// kickLocked 唤醒预热 goroutine，调用方需持有 mutex
+func (p *Prewarmer) kickLocked() {
+	select {
+	case p.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Prewarmer) send(ch chan<- string, v string) {
+	select {
+	case ch <- v:
+	case <-p.ctx.Done():
+	}
+}
+
+func (p *Prewarmer) sendError(err error) {
+	select {
+	case p.errorChan <- err:
+	case <-p.ctx.Done():
+	}
+}
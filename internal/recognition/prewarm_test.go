@@ -0,0 +1,245 @@
+package recognition
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testPool 记录 Prewarmer 创建的所有假连接
+type testPool struct {
+	mutex     sync.Mutex
+	autoReady bool
+	fakes     []*fakeRecognizer
+	params    []*StartParam
+}
+
+func (tp *testPool) newClient(param *StartParam) (*AliyunClient, error) {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	f := &fakeRecognizer{autoReady: tp.autoReady}
+	c := newTestClient(f)
+	c.startParam = param
+	tp.fakes = append(tp.fakes, f)
+	tp.params = append(tp.params, param)
+	return c, nil
+}
+
+// total 所有假连接的 Start、发送和 Shutdown 次数
+func (tp *testPool) total() (starts, sent, shutdowns int) {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	for _, f := range tp.fakes {
+		a, b, c := f.counts()
+		starts, sent, shutdowns = starts+a, sent+b, shutdowns+c
+	}
+	return
+}
+
+// readyAll 让所有等待中的 Start 就绪
+func (tp *testPool) readyAll() int {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	n := 0
+	for _, f := range tp.fakes {
+		if f.ready() {
+			n++
+		}
+	}
+	return n
+}
+
+func startPrewarmer(tp *testPool, refresh time.Duration) *Prewarmer {
+	p := newPrewarmer(tp.newClient, *DefaultStartParam(), refresh)
+	p.wg.Add(1)
+	go p.warmLoop()
+	return p
+}
+
+// waitWarm 等待预热连接就绪
+func waitWarm(t *testing.T, p *Prewarmer) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p.mutex.Lock()
+		ready := p.warm != nil && p.warm.ready
+		p.mutex.Unlock()
+		if ready {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("等待预热连接超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPrewarmer_HandOff(t *testing.T) {
+	tp := &testPool{autoReady: true}
+	p := startPrewarmer(tp, time.Minute)
+	defer p.Close()
+	var discards atomic.Int32
+	p.SetOnDiscard(func(string) { discards.Add(1) })
+
+	waitWarm(t, p)
+	starts, _, _ := tp.total()
+
+	if err := p.StartRecognitionContext(context.Background()); err != nil {
+		t.Fatalf("启动识别失败: %v", err)
+	}
+	if err := p.SendAudioData([]byte{1}); err != nil {
+		t.Fatalf("发送音频失败: %v", err)
+	}
+	// 预热连接已经就绪，音频应直接发送，不需要新的连接
+	if s, sent, _ := tp.total(); sent != 1 || s != starts {
+		t.Errorf("期望直接使用预热连接，实际 Start %d 次，发送 %d 次", s-starts, sent)
+	}
+
+	// 用掉后应在后台预热下一个连接
+	waitWarm(t, p)
+	if err := p.StopRecognitionContext(context.Background()); err != nil {
+		t.Errorf("停止识别失败: %v", err)
+	}
+	p.ShutdownRecognition()
+	if err := p.StartRecognitionContext(context.Background()); err != nil {
+		t.Fatalf("第二次启动识别失败: %v", err)
+	}
+	p.ShutdownRecognition()
+	if n := discards.Load(); n != 0 {
+		t.Errorf("用于识别的连接不应报告为未使用，实际 %d 次", n)
+	}
+}
+
+func TestPrewarmer_BufferWhileConnecting(t *testing.T) {
+	tp := &testPool{}
+	p := startPrewarmer(tp, time.Minute)
+	defer p.Close()
+
+	if err := p.StartRecognitionContext(context.Background()); err != nil {
+		t.Fatalf("启动识别失败: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := p.SendAudioData([]byte{byte(i)}); err != nil {
+			t.Fatalf("连接期间发送音频失败: %v", err)
+		}
+	}
+	if _, sent, _ := tp.total(); sent != 0 {
+		t.Fatalf("连接完成前不应发送音频，实际发送 %d 次", sent)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- p.StopRecognitionContext(context.Background()) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for tp.readyAll() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("等待连接超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("停止识别失败: %v", err)
+	}
+	if _, sent, _ := tp.total(); sent != 3 {
+		t.Errorf("连接完成后应补发缓存的 3 段音频，实际发送 %d 次", sent)
+	}
+}
+
+func TestPrewarmer_Refresh(t *testing.T) {
+	tp := &testPool{autoReady: true}
+	p := startPrewarmer(tp, 30*time.Millisecond)
+	defer p.Close()
+	var discards atomic.Int32
+	p.SetOnDiscard(func(string) { discards.Add(1) })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		starts, _, shutdowns := tp.total()
+		if starts >= 3 && shutdowns >= 2 && discards.Load() >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("预热连接没有定期重建: Start %d 次，Shutdown %d 次，报告未使用 %d 次", starts, shutdowns, discards.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 过期的连接关闭后会被复用，不应不断创建新的客户端
+	tp.mutex.Lock()
+	clients := len(tp.fakes)
+	tp.mutex.Unlock()
+	if clients > 2 {
+		t.Errorf("刷新预热连接时应复用客户端，实际创建了 %d 个", clients)
+	}
+}
+
+func TestPrewarmer_StartParamChanged(t *testing.T) {
+	tp := &testPool{autoReady: true}
+	p := startPrewarmer(tp, time.Minute)
+	defer p.Close()
+	waitWarm(t, p)
+
+	param := *DefaultStartParam()
+	param.MaxEndSilence = 800
+	p.SetStartParam(param)
+
+	if err := p.StartRecognitionContext(context.Background()); err != nil {
+		t.Fatalf("启动识别失败: %v", err)
+	}
+	p.mutex.Lock()
+	got := p.active.param
+	p.mutex.Unlock()
+	if got != param {
+		t.Errorf("参数修改后不应使用旧的预热连接，实际参数 %+v", got)
+	}
+	p.ShutdownRecognition()
+}
+
+func TestPrewarmer_WarmFailed(t *testing.T) {
+	tp := &testPool{autoReady: true}
+	p := startPrewarmer(tp, time.Minute)
+	defer p.Close()
+	waitWarm(t, p)
+
+	// 预热连接上的错误（如 IDLE_TIMEOUT）不应报告给识别，而是重建连接
+	p.mutex.Lock()
+	c := p.warm.client
+	p.mutex.Unlock()
+	c.onTaskFailed(`{"header":{"status":40000004,"status_text":"IDLE_TIMEOUT"}}`, nil)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p.mutex.Lock()
+		rebuilt := p.warm != nil && p.warm.ready && p.warm.client.State() == StateStreaming
+		p.mutex.Unlock()
+		if rebuilt {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("预热连接失效后没有重建")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-p.GetErrorChannel():
+		t.Errorf("预热连接的错误不应报告: %v", err)
+	default:
+	}
+}
+
+func TestPrewarmer_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+	tp := &testPool{}
+	p := startPrewarmer(tp, 10*time.Millisecond)
+	p.StartRecognitionContext(context.Background())
+	p.SendAudioData([]byte{1})
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+
+	if err := p.StartRecognitionContext(context.Background()); err == nil {
+		t.Error("关闭后不应再开始识别")
+	}
+	checkGoroutines(t, before)
+}
@@ -0,0 +1,81 @@
+package recognition
+
+import (
+	"context"
+
+	"github.com/shellus/voiceWin/internal/logging"
+)
+
+var logger = logging.Component(logging.Recognition)
+
+// Recognizer 流式识别器，一次 Start -> SendAudioData -> Stop 识别一句话
+// AliyunClient、Prewarmer、LocalClient 和 OpenAIClient 都实现了该接口，引擎只依赖该接口
+type Recognizer interface {
+	StartRecognitionContext(ctx context.Context) error
+	SendAudioData(data []byte) error
+	StopRecognitionContext(ctx context.Context) error
+	ShutdownRecognition()
+	// TaskID 最近一次识别任务的ID，没有时为空
+	TaskID() string
+
+	GetResultChannel() <-chan string   // 中间结果
+	GetCompleteChannel() <-chan string // 最终结果，空文本表示没有识别到声音
+	GetErrorChannel() <-chan error     // 识别失败
+}
+
+// Word 词的时间信息，时间为相对本次识别音频开始的毫秒数
+type Word struct {
+	Text       string  `json:"text"`
+	BeginTime  int     `json:"begin_time"`
+	EndTime    int     `json:"end_time"`
+	Confidence float64 `json:"confidence,omitempty"` // 置信度 0~1，识别服务不提供时为 0
+}
+
+// Sentence 识别结果中的一句话，时间为相对本次识别音频开始的毫秒数
+type Sentence struct {
+	Index      int     `json:"index"`                // 句子编号，从1开始
+	BeginTime  int     `json:"begin_time"`           // 句子开始时间
+	EndTime    int     `json:"end_time"`             // 句子结束时间，句子开始事件中为 0
+	Text       string  `json:"text,omitempty"`       // 句子文本，句子开始事件中为空
+	Confidence float64 `json:"confidence,omitempty"` // 句子置信度 0~1，识别服务不提供时为 0
+	Words      []Word  `json:"words,omitempty"`      // 词的时间信息，需开启 StartParam.EnableWords
+	Speaker    string  `json:"speaker,omitempty"`    // 说话人，多声道识别时为声道标签（见 Speaker）
+}
+
+// SegmentRecognizer 能返回分句和词时间信息的识别器
+type SegmentRecognizer interface {
+	Recognizer
+	// Sentences 最近一次识别的分句结果，在完成通道返回后调用，没有时间信息时为空
+	Sentences() []Sentence
+}
+
+// 识别服务的音频格式（StartParam.Format）
+const (
+	FormatPCM  = "pcm"
+	FormatOpus = "opus" // Ogg 封装的 Opus
+	FormatMP3  = "mp3"
+)
+
+// FormatRecognizer 能直接识别压缩音频的识别器，文件转写时可以不解码、原样发送文件中的音频
+type FormatRecognizer interface {
+	Recognizer
+	// NativeFormats 识别服务能直接识别的压缩格式
+	NativeFormats() []string
+	// SetFormat 设置之后识别任务的音频格式，为空时恢复为 StartParam.Format
+	SetFormat(format string)
+}
+
+var (
+	_ Recognizer = (*AliyunClient)(nil)
+	_ Recognizer = (*Prewarmer)(nil)
+	_ Recognizer = (*LocalClient)(nil)
+	_ Recognizer = (*OpenAIClient)(nil)
+
+	_ SegmentRecognizer = (*AliyunClient)(nil)
+	_ SegmentRecognizer = (*Prewarmer)(nil)
+	_ SegmentRecognizer = (*MultiChannel)(nil)
+
+	_ SentenceRecognizer = (*MultiChannel)(nil)
+
+	_ FormatRecognizer = (*AliyunClient)(nil)
+)
@@ -0,0 +1,159 @@
+package recognition
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 一次识别会话的状态机：
+//
+//	Idle ──Start──> Connecting ──ready──> Streaming ──Stop/识别完成──> Finishing ──> Closed
+//	                    │                    │                          │
+//	                    └──────失败──────────┴──────────失败────────────┴──> Failed
+//
+// Connecting、Streaming、Finishing、Failed 都可以被 Shutdown 直接转为 Closed；
+// Closed 和 Failed 可以再次 Start 开始下一次识别。
+
+// SessionState 识别会话状态
+type SessionState int
+
+const (
+	StateIdle       SessionState = iota // 初始状态，从未连接
+	StateConnecting                     // 正在建立WS连接，等待识别开始
+	StateStreaming                      // 识别已开始，可以发送音频
+	StateFinishing                      // 已停止发送或识别已完成，等待连接关闭
+	StateClosed                         // 连接已关闭，可以再次开始
+	StateFailed                         // 连接或识别失败，可以再次开始
+)
+
+var stateNames = map[SessionState]string{
+	StateIdle:       "Idle",
+	StateConnecting: "Connecting",
+	StateStreaming:  "Streaming",
+	StateFinishing:  "Finishing",
+	StateClosed:     "Closed",
+	StateFailed:     "Failed",
+}
+
+func (s SessionState) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("SessionState(%d)", int(s))
+}
+
+// validTransitions 合法的状态转换
+var validTransitions = map[SessionState][]SessionState{
+	StateIdle:       {StateConnecting},
+	StateConnecting: {StateStreaming, StateFailed, StateClosed},
+	StateStreaming:  {StateFinishing, StateFailed, StateClosed},
+	StateFinishing:  {StateClosed, StateFailed},
+	StateClosed:     {StateConnecting},
+	StateFailed:     {StateConnecting, StateClosed},
+}
+
+// CanTransition 判断状态转换是否合法
+func CanTransition(from, to SessionState) bool {
+	for _, s := range validTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidTransitionError 非法的状态转换
+type InvalidTransitionError struct {
+	From, To SessionState
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("非法的状态转换: %s -> %s", e.From, e.To)
+}
+
+// Transition 一次状态转换
+type Transition struct {
+	From SessionState
+	To   SessionState
+	Time time.Time
+}
+
+// transitionBuffer 每个订阅者的缓冲区长度，订阅者处理过慢时会丢失转换通知
+const transitionBuffer = 32
+
+// stateMachine 并发安全的会话状态机
+type stateMachine struct {
+	mutex sync.Mutex
+	state SessionState
+	subs  map[chan Transition]struct{}
+}
+
+func newStateMachine() *stateMachine {
+	return &stateMachine{
+		state: StateIdle,
+		subs:  make(map[chan Transition]struct{}),
+	}
+}
+
+// Current 返回当前状态
+func (m *stateMachine) Current() SessionState {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.state
+}
+
+// Transition 转换到目标状态，非法转换返回 *InvalidTransitionError
+func (m *stateMachine) Transition(to SessionState) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !CanTransition(m.state, to) {
+		return &InvalidTransitionError{From: m.state, To: to}
+	}
+	m.set(to)
+	return nil
+}
+
+// TransitionFrom 仅当当前状态属于 from 之一时转换到目标状态，返回是否转换成功
+// 用于回调与主动操作并发时的"比较并交换"
+func (m *stateMachine) TransitionFrom(to SessionState, from ...SessionState) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, s := range from {
+		if m.state == s && CanTransition(s, to) {
+			m.set(to)
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe 订阅状态转换，返回通知通道和取消订阅函数
+func (m *stateMachine) Subscribe() (<-chan Transition, func()) {
+	ch := make(chan Transition, transitionBuffer)
+	m.mutex.Lock()
+	m.subs[ch] = struct{}{}
+	m.mutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mutex.Lock()
+			delete(m.subs, ch)
+			m.mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// set 修改状态并通知订阅者，调用方需持有 mutex
+func (m *stateMachine) set(to SessionState) {
+	t := Transition{From: m.state, To: to, Time: time.Now()}
+	m.state = to
+	for ch := range m.subs {
+		select {
+		case ch <- t:
+		default:
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package recognition
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	nls "github.com/aliyun/alibabacloud-nls-go-sdk"
+)
+
+// fakeRecognizer 模拟 nls.SpeechRecognition 的 ready 通道行为：
+// Shutdown 会向尚未返回的 start/stop 通道发送 false
+type fakeRecognizer struct {
+	mutex     sync.Mutex
+	startCh   chan bool
+	stopCh    chan bool
+	autoReady bool // Start 后立即就绪
+	stopHang  bool // Stop 后不返回结果，直到 Shutdown
+	startErr  error
+	sent      int
+	starts    int
+	shutdowns int
+}
+
+func (f *fakeRecognizer) Start(param nls.SpeechRecognitionStartParam, extra map[string]interface{}) (chan bool, error) {
+	if f.startErr != nil {
+		return nil, f.startErr
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.starts++
+	ch := make(chan bool, 1)
+	if f.autoReady {
+		ch <- true
+	} else {
+		f.startCh = ch
+	}
+	return ch, nil
+}
+
+func (f *fakeRecognizer) Stop() (chan bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	ch := make(chan bool, 1)
+	if f.stopHang {
+		f.stopCh = ch
+	} else {
+		ch <- true
+	}
+	return ch, nil
+}
+
+func (f *fakeRecognizer) Shutdown() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.shutdowns++
+	if f.startCh != nil {
+		f.startCh <- false
+		f.startCh = nil
+	}
+	if f.stopCh != nil {
+		f.stopCh <- false
+		f.stopCh = nil
+	}
+}
+
+// ready 让等待中的 Start 就绪，返回是否有等待中的 Start
+func (f *fakeRecognizer) ready() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.startCh == nil {
+		return false
+	}
+	f.startCh <- true
+	f.startCh = nil
+	return true
+}
+
+func (f *fakeRecognizer) counts() (starts, sent, shutdowns int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.starts, f.sent, f.shutdowns
+}
+
+func (f *fakeRecognizer) SendAudioData(data []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.sent++
+	return nil
+}
+
+func newTestClient(sr speechRecognizer) *AliyunClient {
+	return &AliyunClient{
+		startParam:   DefaultStartParam(),
+		resultChan:   make(chan string, 10),
+		completeChan: make(chan string, 10),
+		errorChan:    make(chan error, 10),
+		timeouts:     DefaultTimeouts(),
+		watch:        true,
+		state:        newStateMachine(),
+		shutdown:     make(chan struct{}),
+		sr:           sr,
+	}
+}
+
+var allStates = []SessionState{StateIdle, StateConnecting, StateStreaming, StateFinishing, StateClosed, StateFailed}
+
+func TestStateMachine_Transitions(t *testing.T) {
+	legal := map[[2]SessionState]bool{
+		{StateIdle, StateConnecting}:      true,
+		{StateConnecting, StateStreaming}: true,
+		{StateConnecting, StateFailed}:    true,
+		{StateConnecting, StateClosed}:    true,
+		{StateStreaming, StateFinishing}:  true,
+		{StateStreaming, StateFailed}:     true,
+		{StateStreaming, StateClosed}:     true,
+		{StateFinishing, StateClosed}:     true,
+		{StateFinishing, StateFailed}:     true,
+		{StateClosed, StateConnecting}:    true,
+		{StateFailed, StateConnecting}:    true,
+		{StateFailed, StateClosed}:        true,
+	}
+
+	for _, from := range allStates {
+		for _, to := range allStates {
+			m := newStateMachine()
+			m.state = from
+			err := m.Transition(to)
+			if legal[[2]SessionState{from, to}] {
+				if err != nil {
+					t.Errorf("%s -> %s 应为合法转换，实际返回 %v", from, to, err)
+				}
+				continue
+			}
+			var invalid *InvalidTransitionError
+			if !errors.As(err, &invalid) {
+				t.Errorf("%s -> %s 应为非法转换，实际返回 %v", from, to, err)
+				continue
+			}
+			if invalid.From != from || invalid.To != to || m.Current() != from {
+				t.Errorf("%s -> %s 非法转换后状态被修改: %v, 当前 %s", from, to, invalid, m.Current())
+			}
+		}
+	}
+}
+
+func TestStateMachine_Subscribe(t *testing.T) {
+	m := newStateMachine()
+	ch, cancel := m.Subscribe()
+
+	m.Transition(StateConnecting)
+	m.Transition(StateStreaming)
+	if m.TransitionFrom(StateConnecting, StateIdle) {
+		t.Error("当前状态不匹配时 TransitionFrom 不应转换")
+	}
+	cancel()
+
+	var got []Transition
+	for tr := range ch {
+		got = append(got, tr)
+	}
+	if len(got) != 2 || got[0].From != StateIdle || got[0].To != StateConnecting || got[1].To != StateStreaming {
+		t.Errorf("状态转换通知不符合预期: %+v", got)
+	}
+}
+
+func TestAliyunClient_Lifecycle(t *testing.T) {
+	sr := &fakeRecognizer{autoReady: true}
+	ac := newTestClient(sr)
+
+	// Idle 状态下的非法操作
+	if err := ac.SendAudioData([]byte{1}); err == nil {
+		t.Error("未连接时发送音频应返回错误")
+	}
+	if err := ac.StopRecognition(); err != nil || ac.State() != StateIdle {
+		t.Errorf("未连接时停止应直接返回，实际 %v, %s", err, ac.State())
+	}
+	ac.ShutdownRecognition()
+	if ac.State() != StateIdle {
+		t.Errorf("未连接时关闭不应改变状态，实际为 %s", ac.State())
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ac.StartRecognition(); err != nil {
+			t.Fatalf("第%d次启动识别失败: %v", i+1, err)
+		}
+		if ac.State() != StateStreaming {
+			t.Fatalf("启动后期望 Streaming，实际为 %s", ac.State())
+		}
+		if err := ac.StartRecognition(); err == nil {
+			t.Error("重复启动应返回错误")
+		}
+		if err := ac.SendAudioData([]byte{1}); err != nil {
+			t.Errorf("发送音频失败: %v", err)
+		}
+		if err := ac.StopRecognition(); err != nil {
+			t.Errorf("停止识别失败: %v", err)
+		}
+		if ac.State() != StateClosed {
+			t.Errorf("停止后期望 Closed，实际为 %s", ac.State())
+		}
+		if err := ac.SendAudioData([]byte{1}); err == nil {
+			t.Error("停止后发送音频应返回错误")
+		}
+	}
+}
+
+func TestAliyunClient_StartFailed(t *testing.T) {
+	ac := newTestClient(&fakeRecognizer{startErr: errors.New("dial failed")})
+	if err := ac.StartRecognition(); err == nil {
+		t.Fatal("连接失败时应返回错误")
+	}
+	if ac.State() != StateFailed {
+		t.Errorf("连接失败后期望 Failed，实际为 %s", ac.State())
+	}
+}
+
+func TestAliyunClient_TaskFailedCallback(t *testing.T) {
+	ac := newTestClient(&fakeRecognizer{autoReady: true})
+	ac.StartRecognition()
+
+	ac.onTaskFailed(`{"header":{"status":40000004,"status_text":"IDLE_TIMEOUT"}}`, nil)
+	if ac.State() != StateFailed {
+		t.Errorf("任务失败后期望 Failed，实际为 %s", ac.State())
+	}
+	if err := <-ac.GetErrorChannel(); StatusCode(fmt.Errorf("转写失败: %w", err)) != 40000004 {
+		t.Errorf("任务失败应发送带错误码的错误，实际 %v", err)
+	}
+	// 失败后可以再次开始
+	if err := ac.StartRecognition(); err != nil {
+		t.Errorf("失败后再次启动失败: %v", err)
+	}
+}
+
+func TestAliyunClient_Words(t *testing.T) {
+	ac := newTestClient(&fakeRecognizer{autoReady: true})
+
+	// 没有开启 enable_words 时没有时间信息
+	ac.StartRecognition()
+	ac.onCompleted(`{"header":{"name":"RecognitionCompleted","status":20000000},"payload":{"result":"你好"}}`, nil)
+	<-ac.GetCompleteChannel()
+	if s := ac.Sentences(); len(s) != 0 {
+		t.Errorf("没有词信息时不应有分句结果，实际 %+v", s)
+	}
+	ac.ShutdownRecognition()
+
+	ac.StartRecognition()
+	ac.onCompleted(`{"header":{"name":"RecognitionCompleted","status":20000000},"payload":{"result":"你好世界","words":[{"text":"你好","startTime":480,"endTime":900},{"text":"世界","startTime":900,"endTime":1350}]}}`, nil)
+	<-ac.GetCompleteChannel()
+	want := []Sentence{{Index: 1, BeginTime: 480, EndTime: 1350, Text: "你好世界", Words: []Word{
+		{Text: "你好", BeginTime: 480, EndTime: 900},
+		{Text: "世界", BeginTime: 900, EndTime: 1350},
+	}}}
+	if got := ac.Sentences(); !reflect.DeepEqual(got, want) {
+		t.Errorf("分句结果期望 %+v，实际 %+v", want, got)
+	}
+	ac.ShutdownRecognition()
+
+	// 下次识别开始时清空
+	ac.StartRecognition()
+	if s := ac.Sentences(); len(s) != 0 {
+		t.Errorf("开始识别后应清空分句结果，实际 %+v", s)
+	}
+	ac.ShutdownRecognition()
+}
+
+func TestAliyunClient_ShutdownWhileConnecting(t *testing.T) {
+	ac := newTestClient(&fakeRecognizer{})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- ac.StartRecognition() }()
+
+	waitState(t, ac, StateConnecting)
+	ac.ShutdownRecognition()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("连接期间被关闭时 Start 应返回错误")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("连接期间被关闭时 Start 没有返回")
+	}
+	if ac.State() != StateClosed {
+		t.Errorf("期望 Closed，实际为 %s", ac.State())
+	}
+}
+
+func TestAliyunClient_ConcurrentStopShutdown(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		ac := newTestClient(&fakeRecognizer{autoReady: true, stopHang: true})
+		if err := ac.StartRecognition(); err != nil {
+			t.Fatalf("启动识别失败: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() { defer wg.Done(); ac.StopRecognition() }()
+		go func() { defer wg.Done(); ac.ShutdownRecognition() }()
+		go func() { defer wg.Done(); ac.SendAudioData([]byte{1}) }()
+
+		done := make(chan struct{})
+		go func() { wg.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("第%d轮: Stop 与 Shutdown 并发时发生死锁，当前状态 %s", i, ac.State())
+		}
+		if ac.State() != StateClosed {
+			t.Fatalf("第%d轮: 期望 Closed，实际为 %s", i, ac.State())
+		}
+	}
+}
+
+func waitState(t *testing.T, ac *AliyunClient, want SessionState) {
+	deadline := time.Now().Add(2 * time.Second)
+	for ac.State() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待状态 %s 超时，当前为 %s", want, ac.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+	"github.com/shellus/voiceWin/internal/capture"
+	"github.com/shellus/voiceWin/internal/engine"
+	"github.com/shellus/voiceWin/internal/history"
+	"github.com/shellus/voiceWin/internal/logging"
+	"github.com/shellus/voiceWin/internal/metrics"
+	"github.com/shellus/voiceWin/internal/recognition"
+)
+
+var logger = logging.Component(logging.Server)
+
+// 本地 HTTP API，供编辑器插件和界面控制、观察识别引擎。
+// 只应监听 localhost，WebSocket 和修改类请求都会拒绝跨域来源，防止任意网页控制麦克风。
+//
+// 接口：
+//
+//	GET  /             内置的 Web 界面
+//	GET  /api/state    当前状态 {"state":"idle"}
+//	POST /api/start    开始识别
+//	POST /api/stop     停止识别，最终结果通过 WebSocket 推送
+//	GET  /api/config   当前识别参数（StartParam）
+//	PUT  /api/config   修改识别参数，只能在空闲时修改
+//	GET  /api/devices  采集设备列表 {"current":"...","devices":[...]}
+//	PUT  /api/device   选择采集设备 {"name":"..."}，name 为空表示系统默认设备
+//	GET  /api/history  识别历史，参数 from、to、q、limit
+//	GET  /metrics      Prometheus 文本格式的运行指标，需调用 EnableMetrics 开启
+//	GET  /ws           WebSocket，推送 engine.Event JSON（state、volume、partial、final、error），volume 事件的 levels 为每个声道的 dBFS 电平
+
+// Engine 服务所需的引擎能力，engine.Engine 实现了该接口
+type Engine interface {
+	State() engine.State
+	Start() error
+	Stop() error
+	Config() recognition.StartParam
+	SetConfig(p recognition.StartParam) error
+	Devices() ([]capture.Device, error)
+	DeviceName() string
+	SetDevice(name string) error
+	History() *history.Store
+	Subscribe() (<-chan engine.Event, func())
+}
+
+//go:embed web
+var webFS embed.FS
+
+// Server 本地 HTTP 服务
+type Server struct {
+	engine   Engine
+	mux      *http.ServeMux
+	upgrader websocket.Upgrader
+}
+
+// New 创建本地 HTTP 服务
+func New(eng Engine) *Server {
+	s := &Server{
+		engine: eng,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /api/state", s.handleState)
+	s.mux.HandleFunc("POST /api/start", s.handleStart)
+	s.mux.HandleFunc("POST /api/stop", s.handleStop)
+	s.mux.HandleFunc("GET /api/config", s.handleGetConfig)
+	s.mux.HandleFunc("PUT /api/config", s.handlePutConfig)
+	s.mux.HandleFunc("GET /api/devices", s.handleDevices)
+	s.mux.HandleFunc("PUT /api/device", s.handleSetDevice)
+	s.mux.HandleFunc("GET /api/history", s.handleHistory)
+	s.mux.HandleFunc("GET /ws", s.handleWebSocket)
+
+	web, _ := fs.Sub(webFS, "web")
+	s.mux.Handle("GET /", http.FileServer(http.FS(web)))
+	return s
+}
+
+// EnableMetrics 开启 /metrics 接口，输出 reg 中的指标
+func (s *Server) EnableMetrics(reg *metrics.Registry) {
+	s.mux.Handle("GET /metrics", reg.Handler())
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && !sameOrigin(r) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "拒绝跨域请求"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// sameOrigin 检查请求是否来自同源页面，没有 Origin 头的请求（命令行、编辑器插件）视为同源
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]engine.State{"state": s.engine.State()})
+}
+
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	if err := s.engine.Start(); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]engine.State{"state": s.engine.State()})
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	// Stop 会等待识别服务返回最终结果，放到后台执行，结果通过 WebSocket 推送
+	go func() {
+		if err := s.engine.Stop(); err != nil {
+			logger.Warn("停止识别失败", "error", err)
+		}
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "stopping"})
+}
+
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.engine.Config())
+}
+
+func (s *Server) handlePutConfig(w http.ResponseWriter, r *http.Request) {
+	// 以当前参数为基础，只覆盖请求中给出的字段
+	p := s.engine.Config()
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.engine.SetConfig(p); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := s.engine.Devices()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"current": s.engine.DeviceName(),
+		"devices": devices,
+	})
+}
+
+func (s *Server) handleSetDevice(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.engine.SetDevice(req.Name); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"current": s.engine.DeviceName()})
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	store := s.engine.History()
+	if store == nil {
+		writeJSON(w, http.StatusOK, []history.Utterance{})
+		return
+	}
+
+	q := r.URL.Query()
+	filter := history.Filter{Query: q.Get("q")}
+	var err error
+	if filter.From, err = history.ParseDate(q.Get("from"), false); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if filter.To, err = history.ParseDate(q.Get("to"), true); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	utterances, err := store.Query(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if utterances == nil {
+		utterances = []history.Utterance{}
+	}
+	writeJSON(w, http.StatusOK, utterances)
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	events, cancel := s.engine.Subscribe()
+	defer cancel()
+
+	// 读取客户端消息只为了感知连接关闭
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// 连接建立后先推送一次当前状态
+	if err := conn.WriteJSON(engine.Event{Type: engine.EventState, State: s.engine.State()}); err != nil {
+		return
+	}
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
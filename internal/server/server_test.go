@@ -0,0 +1,258 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shellus/voiceWin/internal/capture"
+	"github.com/shellus/voiceWin/internal/engine"
+	"github.com/shellus/voiceWin/internal/history"
+	"github.com/shellus/voiceWin/internal/recognition"
+)
+
+// fakeEngine 用于测试的引擎
+type fakeEngine struct {
+	mutex  sync.Mutex
+	state  engine.State
+	param  recognition.StartParam
+	store  *history.Store
+	events chan engine.Event
+	device string
+}
+
+func newFakeEngine(t *testing.T) *fakeEngine {
+	return &fakeEngine{
+		state:  engine.StateIdle,
+		param:  *recognition.DefaultStartParam(),
+		store:  history.Open(filepath.Join(t.TempDir(), "history.jsonl")),
+		events: make(chan engine.Event, 10),
+	}
+}
+
+func (f *fakeEngine) State() engine.State {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.state
+}
+
+func (f *fakeEngine) Start() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.state != engine.StateIdle {
+		return fmt.Errorf("当前状态 %s 不能开始识别", f.state)
+	}
+	f.state = engine.StateListening
+	return nil
+}
+
+func (f *fakeEngine) Stop() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.state = engine.StateIdle
+	return nil
+}
+
+func (f *fakeEngine) Config() recognition.StartParam {
+	return f.param
+}
+
+func (f *fakeEngine) SetConfig(p recognition.StartParam) error {
+	f.param = p
+	return nil
+}
+
+func (f *fakeEngine) Devices() ([]capture.Device, error) {
+	return []capture.Device{{Name: "麦克风", Default: true}, {Name: "耳机"}}, nil
+}
+
+func (f *fakeEngine) DeviceName() string {
+	if f.device == "" {
+		return "麦克风"
+	}
+	return f.device
+}
+
+func (f *fakeEngine) SetDevice(name string) error {
+	if name != "" && name != "麦克风" && name != "耳机" {
+		return fmt.Errorf("找不到采集设备: %s", name)
+	}
+	f.device = name
+	return nil
+}
+
+func (f *fakeEngine) History() *history.Store {
+	return f.store
+}
+
+func (f *fakeEngine) Subscribe() (<-chan engine.Event, func()) {
+	return f.events, func() {}
+}
+
+func TestServer_StartAndState(t *testing.T) {
+	eng := newFakeEngine(t)
+	srv := httptest.NewServer(New(eng))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/start", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("期望200，实际为%d", resp.StatusCode)
+	}
+
+	// 重复开始应返回冲突
+	resp, _ = http.Post(srv.URL+"/api/start", "application/json", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("重复开始期望409，实际为%d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/state")
+	var state map[string]string
+	json.NewDecoder(resp.Body).Decode(&state)
+	resp.Body.Close()
+	if state["state"] != "listening" {
+		t.Errorf("期望状态为listening，实际为%v", state)
+	}
+}
+
+func TestServer_Config(t *testing.T) {
+	eng := newFakeEngine(t)
+	srv := httptest.NewServer(New(eng))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/config", strings.NewReader(`{"max_end_silence":800}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("期望200，实际为%d", resp.StatusCode)
+	}
+	if eng.param.MaxEndSilence != 800 || eng.param.SampleRate != 16000 {
+		t.Errorf("只应修改请求中给出的字段，实际为%+v", eng.param)
+	}
+}
+
+func TestServer_Devices(t *testing.T) {
+	eng := newFakeEngine(t)
+	srv := httptest.NewServer(New(eng))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/device", strings.NewReader(`{"name":"耳机"}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || eng.device != "耳机" {
+		t.Errorf("切换设备失败: %d %s", resp.StatusCode, eng.device)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/devices")
+	var data struct {
+		Current string           `json:"current"`
+		Devices []capture.Device `json:"devices"`
+	}
+	json.NewDecoder(resp.Body).Decode(&data)
+	resp.Body.Close()
+	if data.Current != "耳机" || len(data.Devices) != 2 {
+		t.Errorf("设备列表不符合预期: %+v", data)
+	}
+}
+
+func TestServer_CrossOrigin(t *testing.T) {
+	eng := newFakeEngine(t)
+	srv := httptest.NewServer(New(eng))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/start", nil)
+	req.Header.Set("Origin", "http://evil.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || eng.State() != engine.StateIdle {
+		t.Errorf("跨域请求应被拒绝，实际状态码%d", resp.StatusCode)
+	}
+}
+
+func TestServer_WebUI(t *testing.T) {
+	srv := httptest.NewServer(New(newFakeEngine(t)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("Web 界面加载失败: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestServer_History(t *testing.T) {
+	eng := newFakeEngine(t)
+	eng.store.Append(history.Utterance{Time: time.Now(), Text: "你好世界"})
+	eng.store.Append(history.Utterance{Time: time.Now(), Text: "再见"})
+	srv := httptest.NewServer(New(eng))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/history?q=" + "%E4%BD%A0%E5%A5%BD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var utterances []history.Utterance
+	json.NewDecoder(resp.Body).Decode(&utterances)
+	resp.Body.Close()
+	if len(utterances) != 1 || utterances[0].Text != "你好世界" {
+		t.Errorf("历史查询结果不符合预期: %+v", utterances)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/history?from=bad")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("无效日期期望400，实际为%d", resp.StatusCode)
+	}
+}
+
+func TestServer_WebSocket(t *testing.T) {
+	eng := newFakeEngine(t)
+	srv := httptest.NewServer(New(eng))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败: %v", err)
+	}
+	defer conn.Close()
+
+	var ev engine.Event
+	if err := conn.ReadJSON(&ev); err != nil || ev.Type != engine.EventState {
+		t.Fatalf("期望首先收到状态事件，实际为%+v, %v", ev, err)
+	}
+
+	eng.events <- engine.Event{Type: engine.EventPartial, Text: "你好"}
+	eng.events <- engine.Event{Type: engine.EventFinal, Text: "你好。"}
+	for _, want := range []string{"你好", "你好。"} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if err := conn.ReadJSON(&ev); err != nil {
+			t.Fatalf("读取事件失败: %v", err)
+		}
+		if ev.Text != want {
+			t.Errorf("期望事件文本为%s，实际为%+v", want, ev)
+		}
+	}
+}
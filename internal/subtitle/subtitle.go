@@ -0,0 +1,84 @@
+package subtitle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/recognition"
+)
+
+// 把分句的识别结果写成字幕：每句话一条字幕，时间为相对输入开始的毫秒数
+
+// Formats 支持的输出格式
+var Formats = []string{"srt", "vtt", "txt", "json"}
+
+// Write 将分句结果按指定格式写出
+func Write(w io.Writer, sentences []recognition.Sentence, format string) error {
+	switch format {
+	case "srt":
+		for i, s := range sentences {
+			_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n",
+				i+1, Timestamp(s.BeginTime, ","), Timestamp(s.EndTime, ","), cueText(s.Text))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "vtt":
+		if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+			return err
+		}
+		for _, s := range sentences {
+			_, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n",
+				Timestamp(s.BeginTime, "."), Timestamp(s.EndTime, "."), cueText(s.Text))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "txt":
+		for _, s := range sentences {
+			if _, err := fmt.Fprintf(w, "[%s] %s\n", Timestamp(s.BeginTime, "."), s.Text); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if sentences == nil {
+			sentences = []recognition.Sentence{}
+		}
+		return enc.Encode(sentences)
+	default:
+		return fmt.Errorf("不支持的输出格式: %s", format)
+	}
+}
+
+// Timestamp 把毫秒数格式化为 HH:MM:SS<sep>mmm，SRT 的分隔符为逗号，WebVTT 为点
+func Timestamp(ms int, sep string) string {
+	if ms < 0 {
+		ms = 0
+	}
+	d := time.Duration(ms) * time.Millisecond
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	s := int(d % time.Minute / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, ms%1000)
+}
+
+// cueText 字幕文本中的空行会结束当前字幕，需要去掉
+func cueText(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			kept = append(kept, l)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
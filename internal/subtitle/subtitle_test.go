@@ -0,0 +1,43 @@
+package subtitle
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shellus/voiceWin/internal/recognition"
+)
+
+var sentences = []recognition.Sentence{
+	{Index: 1, BeginTime: 320, EndTime: 1820, Text: "今天天气不错。"},
+	{Index: 2, BeginTime: 3723004, EndTime: 3725100, Text: "出去走走。\n\n好吗？"},
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"srt", "1\n00:00:00,320 --> 00:00:01,820\n今天天气不错。\n\n" +
+			"2\n01:02:03,004 --> 01:02:05,100\n出去走走。\n好吗？\n\n"},
+		{"vtt", "WEBVTT\n\n00:00:00.320 --> 00:00:01.820\n今天天气不错。\n\n" +
+			"01:02:03.004 --> 01:02:05.100\n出去走走。\n好吗？\n\n"},
+		{"txt", "[00:00:00.320] 今天天气不错。\n[01:02:03.004] 出去走走。\n\n好吗？\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, sentences, tt.format); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s 输出错误:\n%s\n期望:\n%s", tt.format, buf.String(), tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, nil, "json"); err != nil || buf.String() != "[]\n" {
+		t.Errorf("空结果的 json 输出应为 []，实际 %q, %v", buf.String(), err)
+	}
+	if err := Write(&buf, sentences, "ass"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
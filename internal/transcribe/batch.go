@@ -0,0 +1,347 @@
+package transcribe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/recognition"
+	"github.com/shellus/voiceWin/internal/subtitle"
+)
+
+// 批量转写：遍历目录下的音频文件，用多个识别器并发转写，每个文件的结果写为一个字幕文件。
+// 每个文件处理完后追加一行到清单（JSON Lines），再次运行时跳过已完成的文件，
+// 中断的运行可以从停下的地方继续；失败的文件记录识别服务的错误码，不影响其他文件，
+// 默认不重试，需要重试时使用 BatchOptions.RetryFailed。
+
+// ManifestName 默认的清单文件名，位于输出目录下
+const ManifestName = ".voicewin-batch.jsonl"
+
+// 清单中的文件状态
+const (
+	StatusDone   = "done"
+	StatusFailed = "failed"
+)
+
+// ManifestEntry 清单中的一条记录，同一文件以最后一条为准
+type ManifestEntry struct {
+	File      string    `json:"file"`             // 相对输入目录的路径
+	Output    string    `json:"output,omitempty"` // 输出文件路径
+	Status    string    `json:"status"`
+	Code      int       `json:"code,omitempty"`  // 识别服务的 status 错误码，其他错误为 0
+	Error     string    `json:"error,omitempty"` // 失败原因
+	Sentences int       `json:"sentences"`       // 识别出的句子数
+	Time      time.Time `json:"time"`            // 处理完成的时间
+}
+
+// BatchOptions 批量转写参数
+type BatchOptions struct {
+	Options
+	Dir         string  // 输入目录
+	OutDir      string  // 输出目录，保持输入的子目录结构；为空时输出到输入文件旁边
+	Format      string  // 输出格式，见 subtitle.Formats
+	Manifest    string  // 清单文件，为空时使用输出目录（或输入目录）下的 ManifestName
+	Concurrency int     // 同时进行的识别任务数，为 0 时为 1
+	QPS         float64 // 每秒最多开始的识别任务数，需不超过账号的并发/频率限制，0 表示不限制
+	RetryFailed bool    // 重试清单中失败的文件
+	// NewRecognizer 为每个并发任务创建一个识别器
+	NewRecognizer func() (recognition.Recognizer, error)
+	// OnFile 每个文件处理完后调用，可为 nil
+	OnFile func(e ManifestEntry)
+}
+
+// BatchResult 批量转写的统计
+type BatchResult struct {
+	Done    int // 本次完成的文件数
+	Failed  int // 本次失败的文件数
+	Skipped int // 清单中已处理而跳过的文件数
+}
+
+// Batch 批量转写目录下的音频文件
+// ctx 取消时正在处理的文件不会写入清单，下次运行时重新处理
+func Batch(ctx context.Context, opts BatchOptions) (BatchResult, error) {
+	var result BatchResult
+	if err := subtitle.Write(&bytes.Buffer{}, nil, opts.Format); err != nil {
+		return result, err
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Manifest == "" {
+		dir := opts.OutDir
+		if dir == "" {
+			dir = opts.Dir
+		}
+		opts.Manifest = filepath.Join(dir, ManifestName)
+	}
+
+	files, err := listInputs(opts.Dir)
+	if err != nil {
+		return result, err
+	}
+	previous, err := ReadManifest(opts.Manifest)
+	if err != nil {
+		return result, err
+	}
+	var todo []string
+	for _, f := range files {
+		e, ok := previous[f]
+		if ok && (e.Status == StatusDone || !opts.RetryFailed) {
+			result.Skipped++
+			continue
+		}
+		todo = append(todo, f)
+	}
+	if len(todo) == 0 {
+		return result, nil
+	}
+
+	if opts.OutDir != "" {
+		if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+			return result, fmt.Errorf("创建输出目录失败: %w", err)
+		}
+	}
+	manifest, err := openManifest(opts.Manifest)
+	if err != nil {
+		return result, err
+	}
+	defer manifest.Close()
+
+	limiter := newRateLimiter(opts.QPS)
+	opts.Wait = limiter.wait
+
+	var (
+		mutex sync.Mutex // 保护 result 和清单写入
+		wg    sync.WaitGroup
+		queue = make(chan string)
+	)
+	record := func(e ManifestEntry) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if e.Status == StatusDone {
+			result.Done++
+		} else {
+			result.Failed++
+		}
+		line, _ := json.Marshal(e)
+		if _, err := manifest.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("写入清单失败: %w", err)
+		}
+		if opts.OnFile != nil {
+			opts.OnFile(e)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	for i := 0; i < opts.Concurrency; i++ {
+		r, err := opts.NewRecognizer()
+		if err != nil {
+			cancel(err)
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c, ok := r.(interface{ Close() }); ok {
+				defer c.Close()
+			}
+			for file := range queue {
+				e := transcribeFile(ctx, r, file, opts)
+				if ctx.Err() != nil {
+					// 被中断的文件不记录，下次重新处理
+					continue
+				}
+				if err := record(e); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, f := range todo {
+		select {
+		case queue <- f:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return result, context.Cause(ctx)
+}
+
+// transcribeFile 转写一个文件并写出结果，返回清单记录
+func transcribeFile(ctx context.Context, r recognition.Recognizer, file string, opts BatchOptions) ManifestEntry {
+	e := ManifestEntry{File: file, Output: outputPath(file, opts)}
+	err := func() error {
+		in, err := ReadInput(filepath.Join(opts.Dir, file), opts.SampleRate, r)
+		if err != nil {
+			return fmt.Errorf("读取音频文件失败: %w", err)
+		}
+		sentences, err := TranscribeInput(ctx, r, in, opts.Options)
+		if err != nil {
+			return err
+		}
+		e.Sentences = len(sentences)
+		return writeOutput(e.Output, sentences, opts.Format)
+	}()
+	e.Time = time.Now()
+	if err != nil {
+		e.Status = StatusFailed
+		e.Code = recognition.StatusCode(err)
+		e.Error = err.Error()
+		e.Output = ""
+	} else {
+		e.Status = StatusDone
+	}
+	return e
+}
+
+// writeOutput 先写临时文件再改名，中断时不会留下不完整的结果
+func writeOutput(path string, sentences []recognition.Sentence, format string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := subtitle.Write(&buf, sentences, format); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("写出结果失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写出结果失败: %w", err)
+	}
+	return nil
+}
+
+// outputPath 输出文件路径：输入文件名换成输出格式的扩展名
+func outputPath(file string, opts BatchOptions) string {
+	name := strings.TrimSuffix(file, filepath.Ext(file)) + "." + opts.Format
+	if opts.OutDir != "" {
+		return filepath.Join(opts.OutDir, name)
+	}
+	return filepath.Join(opts.Dir, name)
+}
+
+// listInputs 列出目录下所有支持的音频文件，返回相对路径，按路径排序
+func listInputs(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !IsInput(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("遍历输入目录失败: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ReadManifest 读取清单，返回每个文件最后一条记录，文件不存在时返回空
+func ReadManifest(path string) (map[string]ManifestEntry, error) {
+	entries := make(map[string]ManifestEntry)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开清单文件失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// 中断时可能留下写了一半的最后一行
+			continue
+		}
+		entries[e.File] = e
+	}
+	return entries, scanner.Err()
+}
+
+// openManifest 以追加方式打开清单
+// 上次中断时可能留下写了一半、没有换行的最后一行，截掉它，否则新的记录会接在后面，两条都无法解析
+func openManifest(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开清单文件失败: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("读取清单文件失败: %w", err)
+	}
+	if n := len(data); n > 0 && data[n-1] != '\n' {
+		if err := f.Truncate(int64(bytes.LastIndexByte(data, '\n') + 1)); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("截断清单文件失败: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// rateLimiter 限制识别任务的开始频率，qps 为 0 时不限制
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(qps float64) *rateLimiter {
+	l := &rateLimiter{}
+	if qps > 0 {
+		l.interval = time.Duration(float64(time.Second) / qps)
+	}
+	return l
+}
+
+// wait 等待到下一个可以开始的时间
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return ctx.Err()
+	}
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,144 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/audio"
+	"github.com/shellus/voiceWin/internal/recognition"
+)
+
+func writeInputs(t *testing.T, dir string) {
+	t.Helper()
+	files := map[string][]byte{
+		"a.wav":       audio.EncodeWAV(tone(time.Second), testRate, 1),
+		"sub/b.pcm":   tone(2 * time.Second),
+		"sub/bad.pcm": tone(3 * time.Second), // fakeRecognizer 对 3 秒的音频返回错误
+		"notes.txt":   []byte("不是音频"),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir, out := t.TempDir(), t.TempDir()
+	writeInputs(t, dir)
+
+	var mutex sync.Mutex
+	var recognizers int
+	opts := BatchOptions{
+		Options:     Options{SampleRate: testRate},
+		Dir:         dir,
+		OutDir:      out,
+		Format:      "srt",
+		Concurrency: 2,
+		NewRecognizer: func() (recognition.Recognizer, error) {
+			mutex.Lock()
+			recognizers++
+			mutex.Unlock()
+			r := newFakeRecognizer()
+			r.failSize = bytesOf(3*time.Second, testRate)
+			return r, nil
+		},
+	}
+
+	result, err := Batch(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("批量转写失败: %v", err)
+	}
+	if result != (BatchResult{Done: 2, Failed: 1}) || recognizers != 2 {
+		t.Errorf("统计错误: %+v，识别器 %d 个", result, recognizers)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "sub", "b.srt"))
+	if err != nil || !strings.Contains(string(data), "00:00:00,100 --> 00:00:02,000") {
+		t.Errorf("输出文件错误: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "a.srt")); err != nil {
+		t.Errorf("缺少输出文件: %v", err)
+	}
+
+	entries, err := ReadManifest(filepath.Join(out, ManifestName))
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("清单错误: %+v, %v", entries, err)
+	}
+	bad := entries[filepath.Join("sub", "bad.pcm")]
+	if bad.Status != StatusFailed || bad.Code != 40270002 || bad.Output != "" {
+		t.Errorf("失败记录错误: %+v", bad)
+	}
+
+	// 再次运行跳过已处理的文件
+	if result, err := Batch(context.Background(), opts); err != nil || result != (BatchResult{Skipped: 3}) {
+		t.Errorf("再次运行应全部跳过，实际 %+v, %v", result, err)
+	}
+	// 上次中断时留下写了一半的最后一行
+	manifest := filepath.Join(out, ManifestName)
+	f, err := os.OpenFile(manifest, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"file":"sub/b.p`)
+	f.Close()
+
+	// 重试失败的文件
+	opts.RetryFailed = true
+	if result, err := Batch(context.Background(), opts); err != nil || result != (BatchResult{Failed: 1, Skipped: 2}) {
+		t.Errorf("重试应只处理失败的文件，实际 %+v, %v", result, err)
+	}
+	data, err = os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for _, line := range lines {
+		var e ManifestEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Errorf("清单中有无法解析的行 %q: %v", line, err)
+		}
+	}
+	if len(lines) != 4 {
+		t.Errorf("期望清单有 4 条记录，实际 %d 条", len(lines))
+	}
+}
+
+func TestBatch_Canceled(t *testing.T) {
+	dir := t.TempDir()
+	writeInputs(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Batch(ctx, BatchOptions{
+		Options:       Options{SampleRate: testRate},
+		Dir:           dir,
+		Format:        "txt",
+		NewRecognizer: func() (recognition.Recognizer, error) { return newFakeRecognizer(), nil },
+	})
+	if err == nil {
+		t.Error("取消后应返回错误")
+	}
+	// 中断的文件不记录，下次重新处理
+	if entries, _ := ReadManifest(filepath.Join(dir, ManifestName)); len(entries) != 0 {
+		t.Errorf("中断的文件不应写入清单: %+v", entries)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(20)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		l.wait(context.Background())
+	}
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("每秒 20 次时开始 4 个任务应至少间隔 150ms，实际 %s", elapsed)
+	}
+}
@@ -0,0 +1,150 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/audio"
+	"github.com/shellus/voiceWin/internal/recognition"
+)
+
+// 压缩音频直接发送：识别服务能直接识别 Ogg Opus、MP3 时，不解码文件，
+// 按页（Ogg）或帧（MP3）原样发送，按每页、每帧的结束时间限速，长文件在页、帧的边界切分为多个识别任务。
+// Ogg Opus 的每个识别任务都先发送文件开头的头信息页，识别服务才能从中间的页开始解码。
+
+// Compressed 可以直接发送给识别服务的压缩音频
+type Compressed struct {
+	Format   string        // 识别服务的音频格式，见 recognition.FormatOpus、recognition.FormatMP3
+	Duration time.Duration // 音频时长
+	header   []byte        // 每个识别任务开始时先发送的数据
+	frames   []frame       // end 为相对文件开始的时长
+}
+
+// compress 文件是 formats 中的格式、声道数和采样率符合识别要求时返回可以直接发送的音频，否则返回 nil
+// 识别服务按 StartParam.SampleRate 识别单声道音频：MP3 需要采样率相同，Opus 可以按任意采样率解码
+func compress(data []byte, sampleRate int, formats []string) *Compressed {
+	switch audio.DetectFormat(data) {
+	case audio.FormatOgg:
+		if !slices.Contains(formats, recognition.FormatOpus) {
+			return nil
+		}
+		o, err := audio.ParseOggOpus(data)
+		if err != nil || o.Channels != 1 {
+			return nil
+		}
+		c := &Compressed{Format: recognition.FormatOpus, Duration: o.Duration}
+		for _, p := range o.Pages[:o.HeaderPages] {
+			c.header = append(c.header, p.Data...)
+		}
+		for _, p := range o.Pages[o.HeaderPages:] {
+			// 没有完整包结束的页 granule 为 -1，沿用上一页的时间
+			end := time.Duration(p.Granule-int64(o.PreSkip)) * time.Second / 48000
+			if p.Granule < 0 && len(c.frames) > 0 {
+				end = c.frames[len(c.frames)-1].end
+			}
+			c.frames = append(c.frames, frame{data: p.Data, end: max(end, 0)})
+		}
+		return c
+	case audio.FormatMP3:
+		if !slices.Contains(formats, recognition.FormatMP3) {
+			return nil
+		}
+		m, err := audio.ParseMP3(data)
+		if err != nil || m.Channels != 1 || m.SampleRate != sampleRate || len(m.Frames) == 0 {
+			return nil
+		}
+		c := &Compressed{Format: recognition.FormatMP3, Duration: m.Duration}
+		// 一帧只有几十毫秒，合并到至少 frameDuration 再发送
+		var f frame
+		var sent time.Duration
+		for i, data := range m.Frames {
+			f.data = append(f.data, data...)
+			f.end = time.Duration((i+1)*m.FrameSamples) * time.Second / time.Duration(m.SampleRate)
+			if f.end-sent >= frameDuration {
+				c.frames = append(c.frames, f)
+				f, sent = frame{}, f.end
+			}
+		}
+		if len(f.data) > 0 {
+			c.frames = append(c.frames, f)
+		}
+		return c
+	}
+	return nil
+}
+
+// chunks 在页、帧的边界把音频切分为不超过 chunkDuration 的识别任务
+// 页比 chunkDuration 长时一页作为一个任务
+func (c *Compressed) chunks(chunkDuration time.Duration) []chunk {
+	var chunks []chunk
+	var cur chunk
+	for _, f := range c.frames {
+		if len(cur.frames) > 0 && f.end-cur.offset > chunkDuration {
+			chunks = append(chunks, cur)
+			cur = chunk{offset: cur.offset + cur.duration()}
+		}
+		if len(cur.frames) == 0 && len(c.header) > 0 {
+			cur.frames = append(cur.frames, frame{data: c.header})
+		}
+		cur.frames = append(cur.frames, frame{data: f.data, end: f.end - cur.offset})
+	}
+	if len(cur.frames) > 0 {
+		chunks = append(chunks, cur)
+	}
+	return chunks
+}
+
+// TranscribeCompressed 不解码直接发送压缩音频进行转写，返回相对音频开始的分句结果
+// r 需要实现 recognition.FormatRecognizer，转写期间使用 c.Format，结束后恢复
+func TranscribeCompressed(ctx context.Context, r recognition.Recognizer, c *Compressed, opts Options) ([]recognition.Sentence, error) {
+	fr, ok := r.(recognition.FormatRecognizer)
+	if !ok {
+		return nil, errors.New("识别器不支持直接识别压缩音频")
+	}
+	opts, err := opts.withChunk()
+	if err != nil {
+		return nil, err
+	}
+	fr.SetFormat(c.Format)
+	defer fr.SetFormat("")
+	return transcribeChunks(ctx, r, c.chunks(opts.Chunk), opts)
+}
+
+// Input 转写的输入，PCM 和 Compressed 只有一个不为空
+type Input struct {
+	PCM        []byte
+	Compressed *Compressed
+}
+
+// ReadInput 读取音频文件：r 能直接识别文件的压缩格式时返回压缩音频，否则与 ReadFile 相同解码为 PCM
+func ReadInput(path string, sampleRate int, r recognition.Recognizer) (Input, error) {
+	if fr, ok := r.(recognition.FormatRecognizer); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Input{}, err
+		}
+		if c := compress(data, sampleRate, fr.NativeFormats()); c != nil {
+			return Input{Compressed: c}, nil
+		}
+		pcm, err := decode(path, data, sampleRate)
+		return Input{PCM: pcm}, err
+	}
+	pcm, err := ReadFile(path, sampleRate)
+	return Input{PCM: pcm}, err
+}
+
+// TranscribeInput 转写 ReadInput 读取的音频
+func TranscribeInput(ctx context.Context, r recognition.Recognizer, in Input, opts Options) ([]recognition.Sentence, error) {
+	if in.Compressed != nil {
+		sentences, err := TranscribeCompressed(ctx, r, in.Compressed, opts)
+		if err != nil {
+			return sentences, fmt.Errorf("直接识别 %s 音频: %w", in.Compressed.Format, err)
+		}
+		return sentences, nil
+	}
+	return Transcribe(ctx, r, in.PCM, opts)
+}
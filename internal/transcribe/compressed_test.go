@@ -0,0 +1,112 @@
+package transcribe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/audio"
+	"github.com/shellus/voiceWin/internal/recognition"
+)
+
+// formatRecognizer 能直接识别压缩音频的 fakeRecognizer，记录每次设置的格式
+type formatRecognizer struct {
+	*fakeRecognizer
+	formats []string
+}
+
+func (f *formatRecognizer) NativeFormats() []string {
+	return []string{recognition.FormatOpus, recognition.FormatMP3}
+}
+
+func (f *formatRecognizer) SetFormat(format string) {
+	f.formats = append(f.formats, format)
+}
+
+// mp3File 生成 d 时长的 MPEG-2 Layer III 32kbps 16kHz 单声道帧，每帧 144 字节、36ms
+func mp3File(d time.Duration) []byte {
+	var data []byte
+	for i := 0; i < int(d/(36*time.Millisecond)); i++ {
+		frame := make([]byte, 144)
+		copy(frame, []byte{0xFF, 0xF3, 0x48, 0xC0})
+		data = append(data, frame...)
+	}
+	return data
+}
+
+func TestReadInput(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	mp3 := write("a.mp3", mp3File(10*time.Second))
+	stereo := mp3File(time.Second)
+	for i := 3; i < len(stereo); i += 144 {
+		stereo[i] = 0x00
+	}
+	stereoPath := write("b.mp3", stereo)
+	wav := write("c.wav", audio.EncodeWAV(tone(time.Second), testRate, 1))
+
+	r := &formatRecognizer{fakeRecognizer: newFakeRecognizer()}
+	in, err := ReadInput(mp3, testRate, r)
+	if err != nil || in.Compressed == nil || in.Compressed.Format != recognition.FormatMP3 {
+		t.Fatalf("单声道 16kHz MP3 应直接发送: %+v %v", in, err)
+	}
+	if in, err := ReadInput(wav, testRate, r); err != nil || in.Compressed != nil || len(in.PCM) != bytesOf(time.Second, testRate) {
+		t.Errorf("WAV 应解码为 PCM: %v", err)
+	}
+	// 立体声不能直接发送，回退到解码
+	if in, err := ReadInput(stereoPath, testRate, r); err != nil || in.Compressed != nil || len(in.PCM) == 0 {
+		t.Errorf("立体声 MP3 应回退到解码: %v", err)
+	}
+	// 识别器不支持压缩音频时回退到解码
+	if in, err := ReadInput(mp3, testRate, newFakeRecognizer()); err != nil || in.Compressed != nil || len(in.PCM) == 0 {
+		t.Errorf("识别器不支持时应解码为 PCM: %v", err)
+	}
+
+	sentences, err := TranscribeInput(context.Background(), r, in, Options{SampleRate: testRate, Chunk: 4 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.formats, []string{recognition.FormatMP3, ""}) {
+		t.Errorf("应在转写期间设置格式并在结束后恢复，实际 %q", r.formats)
+	}
+	// 每段不超过 4 秒，在帧边界切分
+	var begins []int
+	for _, s := range sentences {
+		begins = append(begins, s.BeginTime)
+	}
+	if want := []int{100, 100 + 3996, 100 + 7992}; !reflect.DeepEqual(begins, want) {
+		t.Errorf("各段的时间偏移期望 %v，实际 %v", want, begins)
+	}
+}
+
+func TestCompressed_Chunks(t *testing.T) {
+	c := &Compressed{Format: recognition.FormatOpus, header: []byte("head")}
+	for i := 1; i <= 5; i++ {
+		c.frames = append(c.frames, frame{data: []byte{byte(i)}, end: time.Duration(i) * time.Second})
+	}
+	chunks := c.chunks(2 * time.Second)
+	if len(chunks) != 3 {
+		t.Fatalf("期望 3 段，实际 %d", len(chunks))
+	}
+	for i, ch := range chunks {
+		// 每段都先发送头信息
+		if string(ch.frames[0].data) != "head" {
+			t.Errorf("第 %d 段没有先发送头信息", i+1)
+		}
+		if ch.offset != time.Duration(i*2)*time.Second {
+			t.Errorf("第 %d 段的偏移错误: %v", i+1, ch.offset)
+		}
+	}
+	if d := chunks[2].duration(); d != time.Second {
+		t.Errorf("最后一段的时长错误: %v", d)
+	}
+}
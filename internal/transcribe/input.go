@@ -0,0 +1,40 @@
+package transcribe
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shellus/voiceWin/internal/audio"
+)
+
+// InputExts 支持转写的文件扩展名
+var InputExts = []string{".wav", ".pcm", ".ogg", ".opus", ".mp3"}
+
+// IsInput 判断文件扩展名是否支持转写
+func IsInput(path string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range InputExts {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadFile 读取音频文件，返回指定采样率的 16位单声道 PCM
+// WAV、MP3 和 Ogg Opus 会按需混为单声道并转换采样率；裸 PCM（.pcm）无法校验格式，需要是识别采样率的单声道音频
+func ReadFile(path string, sampleRate int) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decode(path, data, sampleRate)
+}
+
+func decode(path string, data []byte, sampleRate int) ([]byte, error) {
+	if strings.EqualFold(filepath.Ext(path), ".pcm") {
+		return data, nil
+	}
+	return audio.Decode(data, sampleRate)
+}
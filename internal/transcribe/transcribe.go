@@ -0,0 +1,317 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/logging"
+	"github.com/shellus/voiceWin/internal/recognition"
+	"github.com/shellus/voiceWin/internal/usage"
+)
+
+var logger = logging.Component(logging.Transcribe)
+
+// 文件转写：把一段 16位单声道 PCM 按 Options.Chunk 切分为多个识别任务，依次送入识别器，
+// 最后把每段结果的时间加上该段在输入中的偏移，合并为相对输入开始的分句结果。
+// 切分点选在预定位置之前 splitWindow 内最安静的地方，尽量不切断词语。
+// 识别器不提供分句时间时（一句话识别未开启 enable_words、本地和 OpenAI 兼容后端），每段结果作为一句话。
+// 识别服务能直接识别的压缩音频不解码，按页、帧切分后原样发送，见 TranscribeCompressed。
+
+// DefaultChunk 默认每个识别任务的音频时长
+const DefaultChunk = 5 * time.Minute
+
+// MinChunk 每个识别任务最短的音频时长，Options.Chunk 不能小于该值
+const MinChunk = time.Second
+
+const (
+	frameDuration = 100 * time.Millisecond // 每次发送的音频时长
+	splitWindow   = 3 * time.Second        // 在切分点之前多长的范围内寻找最安静的位置
+	splitFrame    = 20 * time.Millisecond  // 寻找切分点时计算音量的帧长
+)
+
+// Options 转写参数
+type Options struct {
+	SampleRate int           // PCM 采样率
+	Chunk      time.Duration // 每个识别任务最长的音频时长，为 0 时使用 DefaultChunk
+	// Speed 发送速度，实时速率的倍数；阿里云要求接近实时速率发送，0 表示不限速
+	Speed float64
+	// Progress 每段识别完成后调用，done 和 total 为音频时长，可为 nil
+	Progress func(done, total time.Duration)
+	// Wait 每个识别任务开始前调用，用于限制请求频率，可为 nil
+	Wait func(ctx context.Context) error
+	// Usage 用量统计和限额，为 nil 时不统计；UsageKind 为计费类型
+	Usage     *usage.Tracker
+	UsageKind string
+}
+
+// Transcribe 转写一段 PCM 音频，返回相对音频开始的分句结果
+func Transcribe(ctx context.Context, r recognition.Recognizer, pcm []byte, opts Options) ([]recognition.Sentence, error) {
+	if opts.SampleRate <= 0 {
+		return nil, fmt.Errorf("无效的采样率: %d", opts.SampleRate)
+	}
+	opts, err := opts.withChunk()
+	if err != nil {
+		return nil, err
+	}
+	return transcribeChunks(ctx, r, pcmChunks(pcm, opts.SampleRate, opts.Chunk), opts)
+}
+
+// withChunk 为 0 的 Chunk 使用 DefaultChunk，小于 MinChunk 时返回错误
+func (o Options) withChunk() (Options, error) {
+	if o.Chunk <= 0 {
+		o.Chunk = DefaultChunk
+	}
+	if o.Chunk < MinChunk {
+		return o, fmt.Errorf("每段音频时长不能小于 %s，当前为 %s", MinChunk, o.Chunk)
+	}
+	return o, nil
+}
+
+// chunk 一个识别任务发送的音频
+type chunk struct {
+	offset time.Duration // 相对输入开始的时间
+	frames []frame       // 依次发送的数据
+}
+
+// frame 一次发送的数据，end 为发送完后相对该段开始的音频时长，用于限速
+type frame struct {
+	data []byte
+	end  time.Duration
+}
+
+func (c chunk) duration() time.Duration {
+	if len(c.frames) == 0 {
+		return 0
+	}
+	return c.frames[len(c.frames)-1].end
+}
+
+// pcmChunks 按 Split 的切分点把 PCM 分为识别任务，每次发送 frameDuration
+func pcmChunks(pcm []byte, sampleRate int, chunkDuration time.Duration) []chunk {
+	bounds := Split(pcm, sampleRate, chunkDuration)
+	frameBytes := bytesOf(frameDuration, sampleRate)
+	chunks := make([]chunk, len(bounds))
+	for i, start := range bounds {
+		end := len(pcm)
+		if i+1 < len(bounds) {
+			end = bounds[i+1]
+		}
+		c := chunk{offset: duration(start, sampleRate)}
+		for off := start; off < end; off += frameBytes {
+			stop := min(off+frameBytes, end)
+			c.frames = append(c.frames, frame{data: pcm[off:stop], end: duration(stop-start, sampleRate)})
+		}
+		chunks[i] = c
+	}
+	return chunks
+}
+
+// transcribeChunks 依次识别每段音频，合并为相对输入开始的分句结果
+func transcribeChunks(ctx context.Context, r recognition.Recognizer, chunks []chunk, opts Options) ([]recognition.Sentence, error) {
+	var total time.Duration
+	if len(chunks) > 0 {
+		last := chunks[len(chunks)-1]
+		total = last.offset + last.duration()
+	}
+
+	var result []recognition.Sentence
+	for i, c := range chunks {
+		sentences, err := recognizeChunk(ctx, r, c, opts)
+		if err != nil {
+			return result, fmt.Errorf("识别第 %d 段（%s 起）失败: %w", i+1, c.offset, err)
+		}
+		offset := int(c.offset / time.Millisecond)
+		for _, s := range sentences {
+			s = shift(s, offset)
+			s.Index = len(result) + 1
+			result = append(result, s)
+		}
+		if opts.Progress != nil {
+			opts.Progress(c.offset+c.duration(), total)
+		}
+	}
+	return result, nil
+}
+
+// Split 返回每段音频在 pcm 中的起始字节偏移，第一段从 0 开始
+// 每段不超过 chunk，切分点在预定位置之前 splitWindow 内最安静的 splitFrame 帧的开头
+// chunk 不足一个采样时不切分
+func Split(pcm []byte, sampleRate int, chunk time.Duration) []int {
+	chunkBytes := bytesOf(chunk, sampleRate)
+	if chunkBytes <= 0 {
+		return []int{0}
+	}
+	windowBytes := bytesOf(splitWindow, sampleRate)
+	frameBytes := bytesOf(splitFrame, sampleRate)
+	if windowBytes > chunkBytes/2 {
+		windowBytes = chunkBytes / 2
+	}
+
+	bounds := []int{0}
+	for start := 0; len(pcm)-start > chunkBytes; {
+		target := start + chunkBytes
+		cut, quietest := target, -1.0
+		for pos := target - frameBytes; pos >= target-windowBytes; pos -= frameBytes {
+			if level := meanAbs(pcm[pos : pos+frameBytes]); quietest < 0 || level < quietest {
+				cut, quietest = pos, level
+			}
+		}
+		bounds = append(bounds, cut)
+		start = cut
+	}
+	return bounds
+}
+
+// recognizeChunk 识别一段音频，返回相对该段开始的分句结果
+func recognizeChunk(ctx context.Context, r recognition.Recognizer, c chunk, opts Options) (_ []recognition.Sentence, err error) {
+	if opts.Wait != nil {
+		if err := opts.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Usage != nil {
+		warning, err := opts.Usage.Check(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if warning != "" {
+			logger.Warn(warning)
+		}
+	}
+	if err := r.StartRecognitionContext(ctx); err != nil {
+		recordUsage(opts, 0, true)
+		return nil, fmt.Errorf("启动识别失败: %w", err)
+	}
+	defer r.ShutdownRecognition()
+	defer func() { recordUsage(opts, c.duration(), err != nil) }()
+
+	sendCtx, cancel := context.WithCancel(ctx)
+	sent := make(chan error, 1)
+	go func() { sent <- send(sendCtx, r, c.frames, opts.Speed) }()
+	// 提前返回时停止发送，并等待发送 goroutine 退出
+	defer func() {
+		cancel()
+		if sent != nil {
+			<-sent
+		}
+	}()
+
+	// 句子通道需要读取，否则回调会阻塞
+	var begins, ends <-chan recognition.Sentence
+	if sc, ok := r.(recognition.SentenceRecognizer); ok {
+		begins, ends = sc.GetSentenceBeginChannel(), sc.GetSentenceEndChannel()
+	}
+	for {
+		select {
+		case <-r.GetResultChannel():
+		case <-begins:
+		case <-ends:
+		case err := <-sent:
+			sent = nil
+			if err != nil {
+				return nil, err
+			}
+		case text := <-r.GetCompleteChannel():
+			var sentences []recognition.Sentence
+			if sc, ok := r.(recognition.SegmentRecognizer); ok {
+				sentences = sc.Sentences()
+			}
+			if len(sentences) == 0 && strings.TrimSpace(text) != "" {
+				sentences = []recognition.Sentence{{
+					Index:   1,
+					EndTime: int(c.duration() / time.Millisecond),
+					Text:    text,
+				}}
+			}
+			return sentences, nil
+		case err := <-r.GetErrorChannel():
+			return nil, err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// recordUsage 记录一个识别任务的用量
+func recordUsage(opts Options, audio time.Duration, failed bool) {
+	if opts.Usage == nil {
+		return
+	}
+	err := opts.Usage.Record(usage.Record{Kind: opts.UsageKind, AudioMs: audio.Milliseconds(), Failed: failed})
+	if err != nil {
+		logger.Warn("记录用量失败", "error", err)
+	}
+}
+
+// send 按指定速度发送音频，发送完后停止识别
+func send(ctx context.Context, r recognition.Recognizer, frames []frame, speed float64) error {
+	start := time.Now()
+	for _, f := range frames {
+		if err := r.SendAudioData(f.data); err != nil {
+			return fmt.Errorf("发送音频失败: %w", err)
+		}
+		if speed <= 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+		// 按已发送的音频时长计算下一帧的发送时间，避免误差累积
+		due := start.Add(time.Duration(float64(f.end) / speed))
+		select {
+		case <-time.After(time.Until(due)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := r.StopRecognitionContext(ctx); err != nil {
+		return fmt.Errorf("停止识别失败: %w", err)
+	}
+	return nil
+}
+
+// shift 把句子和词的时间加上 offset 毫秒
+func shift(s recognition.Sentence, offset int) recognition.Sentence {
+	s.BeginTime += offset
+	s.EndTime += offset
+	words := make([]recognition.Word, len(s.Words))
+	for i, w := range s.Words {
+		w.BeginTime += offset
+		w.EndTime += offset
+		words[i] = w
+	}
+	if s.Words != nil {
+		s.Words = words
+	}
+	return s
+}
+
+// meanAbs 16位 PCM 的平均绝对振幅
+func meanAbs(pcm []byte) float64 {
+	n := len(pcm) / 2
+	if n == 0 {
+		return 0
+	}
+	var sum int64
+	for i := 0; i < n; i++ {
+		v := int64(int16(binary.LittleEndian.Uint16(pcm[2*i:])))
+		if v < 0 {
+			v = -v
+		}
+		sum += v
+	}
+	return float64(sum) / float64(n)
+}
+
+// bytesOf 16位单声道 PCM 中 d 时长对应的字节数，按样本对齐
+func bytesOf(d time.Duration, sampleRate int) int {
+	return int(int64(d)*int64(sampleRate)/int64(time.Second)) * 2
+}
+
+// duration 16位单声道 PCM 中 n 字节对应的时长
+func duration(n, sampleRate int) time.Duration {
+	return time.Duration(int64(n/2) * int64(time.Second) / int64(sampleRate))
+}
@@ -0,0 +1,198 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/recognition"
+	"github.com/shellus/voiceWin/internal/usage"
+)
+
+const testRate = 16000
+
+// fakeRecognizer 每段识别返回一句话：开始于该段第 100 毫秒，结束于最后一个样本，文本为该段编号
+type fakeRecognizer struct {
+	mutex     sync.Mutex
+	task      int
+	received  int
+	sentences []recognition.Sentence
+	failTask  int // 第几段识别失败，0 表示不失败
+	failSize  int // 收到的音频为该字节数时识别失败，0 表示不失败
+
+	resultChan   chan string
+	completeChan chan string
+	errorChan    chan error
+}
+
+func newFakeRecognizer() *fakeRecognizer {
+	return &fakeRecognizer{
+		resultChan:   make(chan string, 10),
+		completeChan: make(chan string, 10),
+		errorChan:    make(chan error, 10),
+	}
+}
+
+func (f *fakeRecognizer) StartRecognitionContext(ctx context.Context) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.task++
+	f.received = 0
+	f.sentences = nil
+	return nil
+}
+
+func (f *fakeRecognizer) SendAudioData(data []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.received += len(data)
+	return nil
+}
+
+func (f *fakeRecognizer) StopRecognitionContext(ctx context.Context) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.task == f.failTask {
+		f.errorChan <- errors.New("识别失败")
+		return nil
+	}
+	if f.received == f.failSize {
+		f.errorChan <- &recognition.StatusError{Status: 40270002, StatusText: "NO_VALID_TEXT"}
+		return nil
+	}
+	text := fmt.Sprintf("第%d段", f.task)
+	end := int(duration(f.received, testRate) / time.Millisecond)
+	f.sentences = []recognition.Sentence{{
+		Index: 1, BeginTime: 100, EndTime: end, Text: text,
+		Words: []recognition.Word{{Text: text, BeginTime: 100, EndTime: end}},
+	}}
+	f.resultChan <- text
+	f.completeChan <- text
+	return nil
+}
+
+func (f *fakeRecognizer) ShutdownRecognition() {}
+func (f *fakeRecognizer) TaskID() string       { return "" }
+
+func (f *fakeRecognizer) Sentences() []recognition.Sentence {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.sentences
+}
+
+func (f *fakeRecognizer) GetResultChannel() <-chan string   { return f.resultChan }
+func (f *fakeRecognizer) GetCompleteChannel() <-chan string { return f.completeChan }
+func (f *fakeRecognizer) GetErrorChannel() <-chan error     { return f.errorChan }
+
+// tone 生成 d 时长的 PCM，安静的位置振幅为 0
+func tone(d time.Duration, quiet ...time.Duration) []byte {
+	pcm := make([]byte, bytesOf(d, testRate))
+	for i := 0; i < len(pcm)/2; i++ {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(1000+i%7*100))
+	}
+	for _, q := range quiet {
+		start := bytesOf(q, testRate)
+		for i := start; i < start+bytesOf(splitFrame, testRate); i++ {
+			pcm[i] = 0
+		}
+	}
+	return pcm
+}
+
+func TestSplit(t *testing.T) {
+	pcm := tone(25*time.Second, 8500*time.Millisecond, 17200*time.Millisecond)
+	got := Split(pcm, testRate, 10*time.Second)
+	want := []int{0, bytesOf(8500*time.Millisecond, testRate), bytesOf(17200*time.Millisecond, testRate)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("切分点期望 %v，实际 %v", want, got)
+	}
+
+	if got := Split(tone(5*time.Second), testRate, 10*time.Second); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("短音频不应切分，实际 %v", got)
+	}
+	// 不足一个采样的 chunk 不应死循环
+	if got := Split(tone(time.Second), testRate, 10*time.Microsecond); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("chunk 不足一个采样时不应切分，实际 %v", got)
+	}
+	if _, err := Transcribe(context.Background(), newFakeRecognizer(), tone(time.Second), Options{SampleRate: testRate, Chunk: 10 * time.Microsecond}); err == nil {
+		t.Error("chunk 小于 MinChunk 时应返回错误")
+	}
+}
+
+func TestTranscribe(t *testing.T) {
+	pcm := tone(25*time.Second, 8500*time.Millisecond, 17200*time.Millisecond)
+	r := newFakeRecognizer()
+	var progress []time.Duration
+	sentences, err := Transcribe(context.Background(), r, pcm, Options{
+		SampleRate: testRate,
+		Chunk:      10 * time.Second,
+		Progress:   func(done, total time.Duration) { progress = append(progress, done) },
+	})
+	if err != nil {
+		t.Fatalf("转写失败: %v", err)
+	}
+
+	word := func(text string, begin, end int) []recognition.Word {
+		return []recognition.Word{{Text: text, BeginTime: begin, EndTime: end}}
+	}
+	want := []recognition.Sentence{
+		{Index: 1, BeginTime: 100, EndTime: 8500, Text: "第1段", Words: word("第1段", 100, 8500)},
+		{Index: 2, BeginTime: 8600, EndTime: 17200, Text: "第2段", Words: word("第2段", 8600, 17200)},
+		{Index: 3, BeginTime: 17300, EndTime: 25000, Text: "第3段", Words: word("第3段", 17300, 25000)},
+	}
+	if !reflect.DeepEqual(sentences, want) {
+		t.Errorf("合并结果期望 %+v，实际 %+v", want, sentences)
+	}
+	if want := []time.Duration{8500 * time.Millisecond, 17200 * time.Millisecond, 25 * time.Second}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("进度期望 %v，实际 %v", want, progress)
+	}
+}
+
+func TestTranscribe_Failed(t *testing.T) {
+	r := newFakeRecognizer()
+	r.failTask = 2
+	sentences, err := Transcribe(context.Background(), r, tone(25*time.Second), Options{SampleRate: testRate, Chunk: 10 * time.Second})
+	if err == nil {
+		t.Fatal("识别失败时应返回错误")
+	}
+	if len(sentences) != 1 {
+		t.Errorf("应返回失败前已完成的结果，实际 %+v", sentences)
+	}
+}
+
+func TestTranscribe_Speed(t *testing.T) {
+	r := newFakeRecognizer()
+	start := time.Now()
+	_, err := Transcribe(context.Background(), r, tone(time.Second), Options{SampleRate: testRate, Speed: 4})
+	if err != nil {
+		t.Fatalf("转写失败: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("4倍速发送 1 秒音频应耗时约 250ms，实际 %s", elapsed)
+	}
+}
+
+func TestTranscribe_Usage(t *testing.T) {
+	tracker := usage.Open(filepath.Join(t.TempDir(), "usage.jsonl"), nil)
+	tracker.SetLimits(usage.Limits{}, usage.Limits{DailyRequests: 2})
+	pcm := tone(25*time.Second, 8500*time.Millisecond, 17200*time.Millisecond)
+	sentences, err := Transcribe(context.Background(), newFakeRecognizer(), pcm, Options{
+		SampleRate: testRate,
+		Chunk:      10 * time.Second,
+		Usage:      tracker,
+		UsageKind:  usage.KindTranscription,
+	})
+	if !errors.Is(err, usage.ErrQuotaExceeded) || len(sentences) != 2 {
+		t.Fatalf("超过限额后应停止，实际 %d 句, %v", len(sentences), err)
+	}
+	s, _ := tracker.Status(time.Now())
+	if s.Today != (usage.Counters{Requests: 2, AudioMs: 17200}) {
+		t.Errorf("用量记录错误: %+v", s.Today)
+	}
+}
@@ -0,0 +1,166 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Price 一种计费类型的单价，两种计费方式可以同时设置
+type Price struct {
+	PerThousandRequests float64 `json:"per_thousand_requests"` // 每千次识别的价格
+	PerHour             float64 `json:"per_hour"`              // 每小时音频的价格
+}
+
+// Prices 价格表，计费类型 -> 单价
+type Prices map[string]Price
+
+// DefaultPrices 默认价格表（元），按阿里云官网的第一档后付费价格，实际价格以账单为准
+// OpenAI 兼容接口常用于自建服务，默认不计费，使用官方接口时需在价格表中设置
+func DefaultPrices() Prices {
+	return Prices{
+		KindSentence:      {PerThousandRequests: 3.5},
+		KindTranscription: {PerHour: 3.5},
+	}
+}
+
+// LoadPrices 从 JSON 文件读取价格表，覆盖默认价格表中的同名类型
+// 文件格式：{"aliyun-sentence": {"per_thousand_requests": 3.5}, "openai": {"per_hour": 2.6}}
+func LoadPrices(path string) (Prices, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取价格表失败: %w", err)
+	}
+	var custom Prices
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("解析价格表失败: %w", err)
+	}
+	prices := DefaultPrices()
+	for k, p := range custom {
+		prices[k] = p
+	}
+	return prices, nil
+}
+
+// Cost 估算费用，失败的识别也按请求计费，估算偏保守
+func (p Prices) Cost(kind string, c Counters) float64 {
+	price := p[kind]
+	return price.PerThousandRequests*float64(c.Requests)/1000 + price.PerHour*c.Audio().Hours()
+}
+
+// Limits 用量限额，为 0 的字段不限制
+type Limits struct {
+	DailyRequests   int64         // 每天的识别任务数
+	MonthlyRequests int64         // 每月的识别任务数
+	MonthlyAudio    time.Duration // 每月的音频时长
+	MonthlyCost     float64       // 每月的估算费用
+}
+
+// IsZero 是否没有任何限额
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// ParseLimits 解析限额，格式为逗号分隔的 key=value：
+// day=每天任务数，month=每月任务数，hours=每月音频小时数，cost=每月费用，如 "day=500,cost=30"
+func ParseLimits(s string) (Limits, error) {
+	var l Limits
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return l, fmt.Errorf("无效的限额 %q，格式为 key=value", item)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || v < 0 {
+			return l, fmt.Errorf("无效的限额 %q", item)
+		}
+		switch strings.TrimSpace(key) {
+		case "day":
+			l.DailyRequests = int64(v)
+		case "month":
+			l.MonthlyRequests = int64(v)
+		case "hours":
+			l.MonthlyAudio = time.Duration(v * float64(time.Hour))
+		case "cost":
+			l.MonthlyCost = v
+		default:
+			return l, fmt.Errorf("未知的限额 %q，可用 day、month、hours、cost", key)
+		}
+	}
+	return l, nil
+}
+
+// ErrQuotaExceeded 超过硬限额
+var ErrQuotaExceeded = errors.New("超过用量限额")
+
+// Status 当天和当月的用量
+type Status struct {
+	Today     Counters
+	Month     Counters
+	MonthCost float64
+}
+
+// Status 返回 now 所在当天和当月的用量
+func (t *Tracker) Status(now time.Time) (Status, error) {
+	now = now.Local()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	days, err := t.Days(monthStart, monthStart.AddDate(0, 1, 0))
+	if err != nil {
+		return Status{}, err
+	}
+	var s Status
+	today := now.Format(time.DateOnly)
+	for _, d := range days {
+		total := d.Total()
+		s.Month.Add(total)
+		s.MonthCost += d.Cost(t.prices)
+		if d.Date == today {
+			s.Today = total
+		}
+	}
+	return s, nil
+}
+
+// Check 开始新的识别前检查限额：超过硬限额返回 ErrQuotaExceeded，超过软限额返回警告
+func (t *Tracker) Check(now time.Time) (warning string, err error) {
+	t.mutex.Lock()
+	soft, hard := t.soft, t.hard
+	t.mutex.Unlock()
+	if soft.IsZero() && hard.IsZero() {
+		return "", nil
+	}
+	s, err := t.Status(now)
+	if err != nil {
+		return "", err
+	}
+	if reason := s.exceeded(hard); reason != "" {
+		return "", fmt.Errorf("%w: %s", ErrQuotaExceeded, reason)
+	}
+	if reason := s.exceeded(soft); reason != "" {
+		return "用量接近限额: " + reason, nil
+	}
+	return "", nil
+}
+
+// exceeded 返回第一个达到的限额说明，都没有达到时为空
+func (s Status) exceeded(l Limits) string {
+	switch {
+	case l.DailyRequests > 0 && s.Today.Requests >= l.DailyRequests:
+		return fmt.Sprintf("今天已识别 %d 次，限额 %d 次", s.Today.Requests, l.DailyRequests)
+	case l.MonthlyRequests > 0 && s.Month.Requests >= l.MonthlyRequests:
+		return fmt.Sprintf("本月已识别 %d 次，限额 %d 次", s.Month.Requests, l.MonthlyRequests)
+	case l.MonthlyAudio > 0 && s.Month.Audio() >= l.MonthlyAudio:
+		return fmt.Sprintf("本月音频 %.1f 小时，限额 %.1f 小时", s.Month.Audio().Hours(), l.MonthlyAudio.Hours())
+	case l.MonthlyCost > 0 && s.MonthCost >= l.MonthlyCost:
+		return fmt.Sprintf("本月估算费用 %.2f，限额 %.2f", s.MonthCost, l.MonthlyCost)
+	}
+	return ""
+}
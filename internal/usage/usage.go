@@ -0,0 +1,222 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 用量记录使用仅追加的 JSONL 文件存储，每次识别任务一行 Record，
+// 与识别历史一样只追加不修改，多个进程（如 serve 和 transcribe）可以同时写入。
+// Tracker 记住已读取到的文件位置，每次统计只读取其他进程新追加的部分。
+
+// 计费类型，不同的识别服务计费方式不同，见 DefaultPrices
+const (
+	KindSentence      = "aliyun-sentence"      // 阿里云一句话识别，按次计费
+	KindTranscription = "aliyun-transcription" // 阿里云实时语音识别，按时长计费
+	KindLocal         = "local"                // 本地离线识别，不计费
+	KindOpenAI        = "openai"               // OpenAI 兼容接口，按时长计费
+)
+
+// Record 一次识别任务的用量
+type Record struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	AudioMs int64     `json:"audio_ms"` // 发送的音频时长
+	Failed  bool      `json:"failed,omitempty"`
+	Prewarm bool      `json:"prewarm,omitempty"` // 没有被使用就关闭的预热连接，没有音频，同样按次计费
+}
+
+// Counters 一段时间内的用量
+type Counters struct {
+	Requests int64 `json:"requests"` // 识别任务数，包括失败的
+	AudioMs  int64 `json:"audio_ms"` // 音频时长
+	Failures int64 `json:"failures"` // 失败的任务数
+}
+
+func (c *Counters) add(r Record) {
+	c.Requests++
+	c.AudioMs += r.AudioMs
+	if r.Failed {
+		c.Failures++
+	}
+}
+
+// Add 累加另一段时间的用量
+func (c *Counters) Add(o Counters) {
+	c.Requests += o.Requests
+	c.AudioMs += o.AudioMs
+	c.Failures += o.Failures
+}
+
+// Audio 音频时长
+func (c Counters) Audio() time.Duration {
+	return time.Duration(c.AudioMs) * time.Millisecond
+}
+
+// Tracker 用量统计，并发安全
+type Tracker struct {
+	path   string
+	prices Prices
+	soft   Limits
+	hard   Limits
+
+	mutex  sync.Mutex
+	offset int64                          // 已读取到的文件位置
+	days   map[string]map[string]Counters // 日期(2006-01-02) -> 计费类型 -> 用量
+}
+
+// DefaultPath 返回默认的用量文件路径
+// 优先使用环境变量 VOICEWIN_USAGE_FILE，否则放在用户配置目录下
+func DefaultPath() string {
+	if p := os.Getenv("VOICEWIN_USAGE_FILE"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "usage.jsonl"
+	}
+	return filepath.Join(dir, "voiceWin", "usage.jsonl")
+}
+
+// Open 打开用量文件，文件在第一次记录时创建，prices 为 nil 时使用 DefaultPrices
+func Open(path string, prices Prices) *Tracker {
+	if prices == nil {
+		prices = DefaultPrices()
+	}
+	return &Tracker{path: path, prices: prices, days: make(map[string]map[string]Counters)}
+}
+
+// SetLimits 设置软限额（超过后警告）和硬限额（超过后拒绝开始新的识别）
+func (t *Tracker) SetLimits(soft, hard Limits) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.soft, t.hard = soft, hard
+}
+
+// Prices 返回价格表
+func (t *Tracker) Prices() Prices {
+	return t.prices
+}
+
+// Record 记录一次识别任务
+func (t *Tracker) Record(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("创建用量目录失败: %w", err)
+	}
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开用量文件失败: %w", err)
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("序列化用量记录失败: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入用量文件失败: %w", err)
+	}
+	return nil
+}
+
+// Days 返回 from 所在日期到 to 所在日期之前（不含）每天每种计费类型的用量，按日期升序
+func (t *Tracker) Days(from, to time.Time) ([]Day, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err := t.refresh(); err != nil {
+		return nil, err
+	}
+	first, last := from.Local().Format(time.DateOnly), to.Local().Format(time.DateOnly)
+	var days []Day
+	for date, kinds := range t.days {
+		if date < first || date >= last {
+			continue
+		}
+		day := Day{Date: date, Kinds: make(map[string]Counters)}
+		for k, c := range kinds {
+			day.Kinds[k] = c
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+// Day 一天的用量
+type Day struct {
+	Date  string              `json:"date"`
+	Kinds map[string]Counters `json:"kinds"`
+}
+
+// Total 所有计费类型的用量之和
+func (d Day) Total() Counters {
+	var c Counters
+	for _, k := range d.Kinds {
+		c.Add(k)
+	}
+	return c
+}
+
+// Cost 按价格表估算的费用
+func (d Day) Cost(prices Prices) float64 {
+	var cost float64
+	for kind, c := range d.Kinds {
+		cost += prices.Cost(kind, c)
+	}
+	return cost
+}
+
+// refresh 读取文件中新追加的记录，调用方需持有 mutex
+func (t *Tracker) refresh() error {
+	f, err := os.Open(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开用量文件失败: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("读取用量文件失败: %w", err)
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// 没有换行的最后一行可能正在被其他进程写入，下次再读
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("读取用量文件失败: %w", err)
+		}
+		t.offset += int64(len(line))
+		var r Record
+		if json.Unmarshal(line, &r) != nil {
+			continue
+		}
+		date := r.Time.Local().Format(time.DateOnly)
+		kinds := t.days[date]
+		if kinds == nil {
+			kinds = make(map[string]Counters)
+			t.days[date] = kinds
+		}
+		c := kinds[r.Kind]
+		c.add(r)
+		kinds[r.Kind] = c
+	}
+}
@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	tracker := Open(path, nil)
+	day1 := time.Date(2026, 10, 1, 9, 0, 0, 0, time.Local)
+	day2 := time.Date(2026, 10, 2, 9, 0, 0, 0, time.Local)
+	records := []Record{
+		{Time: day1, Kind: KindSentence, AudioMs: 3000},
+		{Time: day1, Kind: KindSentence, AudioMs: 1000, Failed: true},
+		{Time: day2, Kind: KindTranscription, AudioMs: 1800000},
+		{Time: time.Date(2026, 9, 30, 9, 0, 0, 0, time.Local), Kind: KindSentence},
+	}
+	for _, r := range records[:2] {
+		if err := tracker.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tracker.Status(day1); err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他进程追加的记录和写了一半的行
+	other := Open(path, nil)
+	for _, r := range records[2:] {
+		other.Record(r)
+	}
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"time":"2026-10-02T10:00:00+08:00","kind":"aliyun-sen`)
+	f.Close()
+
+	s, err := tracker.Status(day2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Today != (Counters{Requests: 1, AudioMs: 1800000}) {
+		t.Errorf("当天用量错误: %+v", s.Today)
+	}
+	if s.Month != (Counters{Requests: 3, AudioMs: 1804000, Failures: 1}) {
+		t.Errorf("当月用量错误: %+v", s.Month)
+	}
+	// 2 次一句话识别 + 半小时实时语音识别
+	if want := 2*3.5/1000 + 3.5/2; math.Abs(s.MonthCost-want) > 1e-9 {
+		t.Errorf("估算费用期望 %f，实际 %f", want, s.MonthCost)
+	}
+
+	days, err := tracker.Days(day1, day2.AddDate(0, 0, 1))
+	if err != nil || len(days) != 2 || days[0].Date != "2026-10-01" || days[1].Total().Requests != 1 {
+		t.Errorf("按天统计错误: %+v, %v", days, err)
+	}
+}
+
+func TestTracker_Check(t *testing.T) {
+	tracker := Open(filepath.Join(t.TempDir(), "usage.jsonl"), nil)
+	now := time.Now()
+	if w, err := tracker.Check(now); w != "" || err != nil {
+		t.Errorf("没有限额时不应警告: %q, %v", w, err)
+	}
+
+	soft, _ := ParseLimits("day=2")
+	hard, _ := ParseLimits("day=3, cost=100")
+	tracker.SetLimits(soft, hard)
+	for i := 0; i < 3; i++ {
+		w, err := tracker.Check(now)
+		if err != nil {
+			t.Fatalf("第%d次不应超过硬限额: %v", i+1, err)
+		}
+		if (i == 2) != (w != "") {
+			t.Errorf("第%d次软限额警告错误: %q", i+1, w)
+		}
+		tracker.Record(Record{Time: now, Kind: KindSentence})
+	}
+	if _, err := tracker.Check(now); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("超过硬限额应返回 ErrQuotaExceeded，实际 %v", err)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	l, err := ParseLimits("day=500, month=10000,hours=1.5,cost=30")
+	want := Limits{DailyRequests: 500, MonthlyRequests: 10000, MonthlyAudio: 90 * time.Minute, MonthlyCost: 30}
+	if err != nil || l != want {
+		t.Errorf("期望 %+v，实际 %+v, %v", want, l, err)
+	}
+	for _, s := range []string{"day", "day=-1", "week=3", "cost=abc"} {
+		if _, err := ParseLimits(s); err == nil {
+			t.Errorf("%q 应返回错误", s)
+		}
+	}
+}
@@ -1,122 +1,305 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/shellus/voiceWin/internal/archive"
+	"github.com/shellus/voiceWin/internal/audio"
 	"github.com/shellus/voiceWin/internal/capture"
+	"github.com/shellus/voiceWin/internal/command"
+	"github.com/shellus/voiceWin/internal/dsp"
+	"github.com/shellus/voiceWin/internal/engine"
+	"github.com/shellus/voiceWin/internal/history"
+	"github.com/shellus/voiceWin/internal/hotkey"
+	"github.com/shellus/voiceWin/internal/logging"
 	"github.com/shellus/voiceWin/internal/recognition"
+	"github.com/shellus/voiceWin/internal/usage"
 )
 
 var stopChan = make(chan os.Signal, 1)
-var doneChan = make(chan struct{})
 
-func chanWait(completeChan <-chan string, errorChan <-chan error) {
-	for {
-		select {
-		case result := <-completeChan:
-			onResult(result)
-			return
-		case err := <-errorChan:
-			onError(err)
-			return
+var (
+	commandsFile = flag.String("commands", "", "语音命令规则文件，为空时使用内置规则")
+	dryRun       = flag.Bool("dry-run", false, "只打印语音命令解释出的动作列表，不执行")
+	historyFile  = flag.String("history", history.DefaultPath(), "识别历史文件，为空时不记录")
+	archiveDir   = flag.String("archive", "", "录音归档目录，为空时不归档")
+	archiveCount = flag.Int("archive-max-count", 500, "录音归档最多保留的文件数")
+	archiveSize  = flag.Int64("archive-max-mb", 500, "录音归档最多占用的空间（MB）")
+	archiveAge   = flag.Duration("archive-max-age", 30*24*time.Hour, "录音归档最长保留时间")
+
+	connectTimeout = flag.Duration("connect-timeout", recognition.DefaultTimeouts().Connect, "等待识别服务开始识别的超时时间")
+	stopTimeout    = flag.Duration("stop-timeout", recognition.DefaultTimeouts().Stop, "停止后等待最终结果的超时时间")
+	sessionTimeout = flag.Duration("session-timeout", recognition.DefaultTimeouts().Session, "一次识别的最长时间")
+
+	backend  = flag.String("backend", "", "识别后端：aliyun、local 或 openai，为空时读取环境变量 VOICEWIN_BACKEND，默认 aliyun")
+	localCmd = flag.String("local-cmd", "", "本地识别程序及参数，为空时读取环境变量 VOICEWIN_LOCAL_COMMAND")
+	mode     = flag.String("mode", recognition.ModeSentence, "阿里云识别模式：sentence 一句话识别，transcription 实时语音识别（按句输入，没有60秒限制）")
+	channels = flag.Int("channels", 1, "采集的声道数，大于 1 时每个声道（每人一个麦克风）单独识别，结果标注说话人 A、B…，需要 -mode transcription")
+
+	source         = flag.String("source", capture.SourceMic, "采集来源：mic 麦克风，loopback 系统播放的声音（给视频会议加字幕），mix 两者混合")
+	loopbackDevice = flag.String("loopback-device", "", "采集系统声音的设备（devices list 中列出），为空时使用默认输出设备")
+
+	prewarm        = flag.Bool("prewarm", false, "连接预热：后台保持一个已就绪的识别连接，连接完成前的音频先缓存在本地")
+	prewarmRefresh = flag.Duration("prewarm-refresh", recognition.DefaultPrewarmRefresh, "预热连接的刷新间隔，需小于识别服务 10 秒的无数据超时；每次刷新都是一次计费的识别请求")
+
+	usageFile   = flag.String("usage", usage.DefaultPath(), "用量记录文件，为空时不统计")
+	usagePrices = flag.String("usage-prices", "", "价格表 JSON 文件，为空时使用内置的阿里云价格")
+	quotaSoft   = flag.String("quota-soft", "", "软限额，超过后警告，如 day=500,month=10000,hours=20,cost=30")
+	quotaHard   = flag.String("quota-hard", "", "硬限额，超过后拒绝开始新的识别，格式同 -quota-soft")
+
+	highPass       = flag.Bool("hpf", false, "高通滤波，去掉风扇、空调等低频噪声")
+	highPassCutoff = flag.Float64("hpf-cutoff", dsp.DefaultConfig().HighPassCutoff, "高通滤波的截止频率（Hz）")
+	noiseSuppress  = flag.Bool("ns", false, "降噪，从静音片段学习背景噪声并减去，会增加 32ms 延迟")
+	noiseStrength  = flag.Float64("ns-strength", dsp.DefaultConfig().NoiseStrength, "降噪强度，越大降噪越多，语音失真也越多")
+	agcEnabled     = flag.Bool("agc", false, "自动增益，把说话声调整到接近的响度")
+	agcTarget      = flag.Float64("agc-target", dsp.DefaultConfig().AGCTarget, "自动增益的目标响度（dBFS）")
+
+	silenceThreshold = flag.Float64("silence-threshold", audio.QuietPeak, "静音门限（dBFS）：电平低于该值视为没有声音，录音一直低于该值时提示音量过低；OpenAI 兼容后端据此在本地检测说话结束")
+
+	logLevel  = flag.String("log-level", "info", "日志级别：debug、info、warn、error，debug 包括识别服务 SDK 的日志")
+	logFormat = flag.String("log-format", logging.FormatText, "日志格式：text 或 json，输出到标准错误")
+)
+
+var interpreter *command.Interpreter
+var executor = command.NewExecutor(hotkey.NewKeyboardInput())
+
+// loadInterpreter 加载语音命令规则
+func loadInterpreter() {
+	rules := command.DefaultRules()
+	if *commandsFile != "" {
+		var err error
+		if rules, err = command.LoadRules(*commandsFile); err != nil {
+			log.Fatalf("加载语音命令规则失败: %v", err)
 		}
 	}
+	interpreter = command.NewInterpreter(rules)
 }
 
-func onResult(result string) {
-	fmt.Printf("\n识别结果: %s\n", result)
-	close(doneChan)
-}
-
-func onError(err error) {
-	log.Printf("\n错误: %v\n", err)
-	close(doneChan)
+func onError(err string) {
+	fmt.Printf("\n错误: %v\n", err)
 }
 
 func main() {
-	// 加载环境变量
-	if err := godotenv.Load(); err != nil {
-		log.Printf("警告: 未能加载 .env 文件: %v", err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "history":
+			runHistory(os.Args[2:])
+			return
+		case "serve":
+			flag.CommandLine.Parse(os.Args[2:])
+			setupLogging()
+			runServe()
+			return
+		case "transcribe":
+			runTranscribe(os.Args[2:])
+			return
+		case "usage":
+			runUsage(os.Args[2:])
+			return
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "devices":
+			runDevices(os.Args[2:])
+			return
+		}
 	}
+	flag.Parse()
+	setupLogging()
 
-	// 创建音频捕获器
-	audioCapture := capture.NewAudioCapture()
+	loadInterpreter()
 
-	defer audioCapture.Close()
+	sink, err := newSink()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	defer sink.Close()
 
-	// 创建阿里云配置
-	aliyunCfg := &recognition.AliyunConfig{
-		AccessKeyID:     os.Getenv("ALIYUN_ACCESS_KEY_ID"),
-		AccessKeySecret: os.Getenv("ALIYUN_ACCESS_KEY_SECRET"),
-		AppKey:          os.Getenv("ALIYUN_APP_KEY"),
-		Region:          os.Getenv("ALIYUN_REGION"),
+	eng := newEngine()
+	defer eng.Close()
+
+	events, cancel := eng.Subscribe()
+	defer cancel()
+
+	if err := eng.Start(); err != nil {
+		log.Fatalf("%v", err)
 	}
+	fmt.Println("开始录音...按 Ctrl+C 停止")
 
-	// 1. 初始化阿里云客户端
-	aliyunClient, err := recognition.NewAliyunClient(aliyunCfg, recognition.DefaultStartParam())
-	if err != nil {
-		log.Fatalf("初始化阿里云客户端失败: %v", err)
+	// 注意，退出分为3种情况：
+	// 1. 识别完成：收到final事件，输出结果；实时语音识别模式下每收到一个sentence_end事件就输出一次
+	// 2. 识别失败：收到error事件，触发onError
+	// 3. Ctrl+C：停止识别，继续等待final或error事件，最多等待 -stop-timeout；再次 Ctrl+C 直接退出
+	// 引擎在发布final或error事件前已经释放了识别连接，收到后直接关闭即可
+
+	signal.Notify(stopChan, os.Interrupt)
+	stopping := false
+	sentences := false // 实时语音识别模式下已经按句输出过结果
+	for {
+		select {
+		case ev := <-events:
+			switch ev.Type {
+			case engine.EventVolume:
+				fmt.Printf("\r%s", formatLevels(ev.Levels))
+			case engine.EventPartial, engine.EventSentenceEnd:
+				sinkEvent(sink, ev, &sentences)
+			case engine.EventFinal:
+				sinkEvent(sink, ev, &sentences)
+				fmt.Println("\n正在关闭...")
+				return
+			case engine.EventError:
+				onError(ev.Error)
+				fmt.Println("\n正在关闭...")
+				return
+			case engine.EventWarning:
+				fmt.Printf("\n警告: %s\n", ev.Text)
+			}
+		case <-stopChan:
+			if stopping {
+				fmt.Println("\n强制退出")
+				return
+			}
+			stopping = true
+			fmt.Println("\n正在停止识别...")
+			// Stop 会等待识别服务返回结果，放到后台执行，这里继续接收事件
+			go eng.Stop()
+		}
 	}
+}
 
-	// 2. 启动语音识别
-	if err := aliyunClient.StartRecognition(); err != nil {
-		log.Fatalf("启动语音识别失败: %v", err)
+// setupLogging 按命令行参数设置日志输出，需在解析参数之后调用
+func setupLogging() {
+	if err := logging.Setup(os.Stderr, *logLevel, *logFormat); err != nil {
+		log.Fatalf("%v", err)
 	}
+}
 
-	// 3. 启动音频捕获
-	audioCapture.OnVolumeChange = func(volume float64) {
-		fmt.Printf("\r音量: %f", volume)
+// newEngine 按环境变量和命令行参数创建识别引擎
+func newEngine() *engine.Engine {
+	opts := engineOptions()
+	if *historyFile != "" {
+		opts.History = history.Open(*historyFile)
 	}
-	audioCapture.OnAudioData = func() {
-		pcmData := audioCapture.GetPCMData()
-		if len(pcmData) == 0 {
-			// 当audioCapture.Start()后，采集器触发了回调，应该20ms收到一次采集数据的
-			log.Fatalf("音频数据为空")
-		}
-		if err := aliyunClient.SendAudioData(pcmData); err != nil {
-			log.Printf("发送音频数据失败: %v", err)
+	if *archiveDir != "" {
+		cfg := archive.DefaultConfig(*archiveDir)
+		cfg.MaxCount = *archiveCount
+		cfg.MaxBytes = *archiveSize * 1024 * 1024
+		cfg.MaxAge = *archiveAge
+		cfg.Channels = *channels
+		recorder, err := archive.NewRecorder(cfg)
+		if err != nil {
+			log.Fatalf("初始化录音归档失败: %v", err)
 		}
+		opts.Recorder = recorder
 	}
-	if err := audioCapture.Start(); err != nil {
-		log.Fatalf("启动音频捕获失败: %v", err)
+
+	eng, err := engine.New(opts)
+	if err != nil {
+		log.Fatalf("初始化识别引擎失败: %v", err)
 	}
+	return eng
+}
 
-	fmt.Println("开始录音...按 Ctrl+C 停止")
+// engineOptions 按环境变量和命令行参数生成识别配置，不包括识别历史和录音归档
+func engineOptions() engine.Options {
+	// 加载环境变量
+	if err := godotenv.Load(); err != nil {
+		slog.Warn("未能加载 .env 文件", "error", err)
+	}
 
-	go chanWait(aliyunClient.GetCompleteChannel(), aliyunClient.GetErrorChannel())
+	if *backend == "" {
+		*backend = os.Getenv("VOICEWIN_BACKEND")
+	}
+	if *localCmd == "" {
+		*localCmd = os.Getenv("VOICEWIN_LOCAL_COMMAND")
+	}
 
-	// 注意，退出分为3种情况：
-	// 1. 识别完成：触发onResult，关闭doneChan，执行ShutdownRecognition
-	// 2. 识别失败：触发onError，关闭doneChan，执行ShutdownRecognition
-	// 3. Ctrl+C：执行StopRecognition，等待doneChan，然后执行ShutdownRecognition
+	startParam := recognition.DefaultStartParam()
+	startParam.Mode = *mode
 
-	signal.Notify(stopChan, os.Interrupt)
-	select {
-	case <-doneChan:
-		// 识别完成或失败，直接关闭
-		fmt.Println("\n正在关闭...")
-		aliyunClient.ShutdownRecognition()
-		audioCapture.Close()
-	case <-stopChan:
-		fmt.Println("\n正在停止识别...")
-		// 先停止音频捕获
-		if err := audioCapture.Stop(); err != nil {
-			log.Printf("停止音频捕获失败: %v", err)
+	// 创建阿里云配置
+	opts := engine.Options{
+		Backend: *backend,
+		Aliyun: &recognition.AliyunConfig{
+			AccessKeyID:     os.Getenv("ALIYUN_ACCESS_KEY_ID"),
+			AccessKeySecret: os.Getenv("ALIYUN_ACCESS_KEY_SECRET"),
+			AppKey:          os.Getenv("ALIYUN_APP_KEY"),
+			Region:          os.Getenv("ALIYUN_REGION"),
+		},
+		StartParam: startParam,
+		Timeouts: recognition.Timeouts{
+			Connect: *connectTimeout,
+			Stop:    *stopTimeout,
+			Session: *sessionTimeout,
+		},
+		Prewarm:        *prewarm,
+		PrewarmRefresh: *prewarmRefresh,
+		DSP:            dspConfig(),
+		Meter:          meterConfig(),
+		Channels:       *channels,
+		Source:         *source,
+		LoopbackDevice: *loopbackDevice,
+	}
+
+	if *backend == engine.BackendOpenAI {
+		opts.OpenAI = &recognition.OpenAIConfig{
+			BaseURL:  os.Getenv("OPENAI_BASE_URL"),
+			APIKey:   os.Getenv("OPENAI_API_KEY"),
+			Model:    os.Getenv("OPENAI_MODEL"),
+			Language: os.Getenv("OPENAI_LANGUAGE"),
 		}
-		// 停止识别并等待完成
-		if err := aliyunClient.StopRecognition(); err != nil {
-			log.Printf("停止识别失败: %v", err)
+	}
+	if fields := strings.Fields(*localCmd); len(fields) > 0 {
+		opts.Local = &recognition.LocalConfig{Command: fields[0], Args: fields[1:]}
+	}
+	if *usageFile != "" {
+		opts.Usage = openUsage()
+	}
+	return opts
+}
+
+// dspConfig 按命令行参数生成采集音频的预处理配置
+func dspConfig() dsp.Config {
+	cfg := dsp.DefaultConfig()
+	cfg.HighPass, cfg.HighPassCutoff = *highPass, *highPassCutoff
+	cfg.NoiseSuppression, cfg.NoiseStrength = *noiseSuppress, *noiseStrength
+	cfg.AGC, cfg.AGCTarget = *agcEnabled, *agcTarget
+	return cfg
+}
+
+// meterConfig 按命令行参数生成电平表配置
+func meterConfig() audio.MeterConfig {
+	cfg := audio.DefaultMeterConfig()
+	cfg.SilenceThreshold = *silenceThreshold
+	return cfg
+}
+
+// openUsage 按命令行参数打开用量统计
+func openUsage() *usage.Tracker {
+	var prices usage.Prices
+	if *usagePrices != "" {
+		var err error
+		if prices, err = usage.LoadPrices(*usagePrices); err != nil {
+			log.Fatalf("%v", err)
 		}
-		// 等待识别完成或失败
-		// 因为select已经进入了case <-stopChan，所以我们这里需要手动等待doneChan
-		<-doneChan
-		fmt.Println("\n正在关闭...")
-		// 完全关闭
-		aliyunClient.ShutdownRecognition()
-		audioCapture.Close()
 	}
+	soft, err := usage.ParseLimits(*quotaSoft)
+	if err != nil {
+		log.Fatalf("无效的 -quota-soft: %v", err)
+	}
+	hard, err := usage.ParseLimits(*quotaHard)
+	if err != nil {
+		log.Fatalf("无效的 -quota-hard: %v", err)
+	}
+	tracker := usage.Open(*usageFile, prices)
+	tracker.SetLimits(soft, hard)
+	return tracker
 }
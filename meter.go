@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shellus/voiceWin/internal/audio"
+)
+
+// 控制台电平表显示的范围（dBFS），低于 meterFloor 的部分显示为空
+const (
+	meterFloor = -60.0
+	meterWidth = 30
+)
+
+// formatLevels 把每个声道的电平格式化为一行，如
+// [##########-------|------------] -32.5 dBFS 峰值 -18.0
+// # 为 RMS，| 为保持的峰值
+func formatLevels(levels []audio.Level) string {
+	parts := make([]string, 0, len(levels))
+	for _, l := range levels {
+		bar := []byte(strings.Repeat("-", meterWidth))
+		for i := 0; i < meterPos(l.RMS); i++ {
+			bar[i] = '#'
+		}
+		if p := meterPos(l.PeakHold); p > 0 {
+			bar[p-1] = '|'
+		}
+		parts = append(parts, fmt.Sprintf("[%s] %6.1f dBFS 峰值 %6.1f", bar, l.RMS, l.PeakHold))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// meterPos 电平对应的格数，0~meterWidth
+func meterPos(db float64) int {
+	pos := int((db - meterFloor) / -meterFloor * meterWidth)
+	return min(max(pos, 0), meterWidth)
+}
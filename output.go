@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/shellus/voiceWin/internal/engine"
+	"github.com/shellus/voiceWin/internal/output"
+)
+
+var (
+	outputs     = flag.String("output", "console,keyboard", "识别结果的输出，逗号分隔：console 控制台，keyboard 语音命令和键盘输入，clipboard 剪贴板，file 文件，caption 实时字幕网页")
+	outputFile  = flag.String("output-file", "voiceWin.txt", "-output 包含 file 时追加识别结果的文件")
+	captionAddr = flag.String("caption-addr", "127.0.0.1:8766", "-output 包含 caption 时字幕网页的监听地址，可作为 OBS 浏览器源")
+	captionHold = flag.Duration("caption-hold", output.DefaultCaptionConfig().Hold, "字幕最后一次更新后保持显示的时间，之后淡出")
+)
+
+// newSink 按 -output 参数创建识别结果的输出
+func newSink() (output.Sink, error) {
+	var sinks output.Multi
+	for _, kind := range strings.Split(*outputs, ",") {
+		var sink output.Sink
+		switch kind = strings.TrimSpace(kind); kind {
+		case "":
+			continue
+		case output.KindConsole:
+			sink = output.NewConsole(os.Stdout)
+		case output.KindKeyboard:
+			var dry io.Writer
+			if *dryRun {
+				dry = os.Stdout
+			}
+			sink = output.NewKeyboard(interpreter, executor, dry)
+		case output.KindClipboard:
+			clipboard, err := output.NewClipboard()
+			if err != nil {
+				sinks.Close()
+				return nil, err
+			}
+			sink = clipboard
+		case output.KindFile:
+			file, err := output.OpenFile(*outputFile)
+			if err != nil {
+				sinks.Close()
+				return nil, err
+			}
+			sink = file
+		case output.KindCaption:
+			cfg := output.DefaultCaptionConfig()
+			cfg.Hold = *captionHold
+			caption := output.NewCaption(cfg)
+			if err := caption.Listen(*captionAddr); err != nil {
+				sinks.Close()
+				return nil, err
+			}
+			fmt.Printf("实时字幕: http://%s\n", *captionAddr)
+			sink = caption
+		default:
+			sinks.Close()
+			return nil, fmt.Errorf("未知的输出: %s", kind)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// sinkEvent 把识别结果事件交给输出
+// 实时语音识别模式下每句话在 sentence_end 时输出，最终结果是所有句子拼接的全文，已经按句输出过时不再重复
+// sentences 记录本次识别是否已经按句输出过，final 事件后重置
+func sinkEvent(sink output.Sink, ev engine.Event, sentences *bool) {
+	var err error
+	switch ev.Type {
+	case engine.EventPartial:
+		sink.Partial(ev.Text)
+	case engine.EventSentenceEnd:
+		*sentences = true
+		err = sink.Final(ev.Text)
+	case engine.EventFinal:
+		if !*sentences {
+			err = sink.Final(ev.Text)
+		}
+		*sentences = false
+	}
+	if err != nil {
+		slog.Error("输出识别结果失败", "error", err)
+	}
+}
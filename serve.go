@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/metrics"
+	"github.com/shellus/voiceWin/internal/server"
+)
+
+var (
+	serveAddr    = flag.String("addr", "127.0.0.1:8765", "serve 模式的监听地址，只建议监听 localhost")
+	serveMetrics = flag.Bool("metrics", false, "serve 模式提供 /metrics 接口（Prometheus 文本格式），stats 命令读取该接口")
+)
+
+// flagPassed 命令行中是否指定了参数 name
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// runServe 执行 serve 子命令：启动本地 HTTP + WebSocket API
+func runServe() {
+	eng := newEngine()
+	defer eng.Close()
+
+	// serve 模式默认只通过 API 推送结果，明确指定 -output 时同时输出，如给界面控制的识别加上实时字幕
+	if flagPassed("output") {
+		loadInterpreter()
+		sink, err := newSink()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer sink.Close()
+		events, cancel := eng.Subscribe()
+		defer cancel()
+		go func() {
+			sentences := false
+			for ev := range events {
+				sinkEvent(sink, ev, &sentences)
+			}
+		}()
+	}
+
+	handler := server.New(eng)
+	if *serveMetrics {
+		handler.EnableMetrics(metrics.Default)
+	}
+	srv := &http.Server{
+		Addr:    *serveAddr,
+		Handler: handler,
+	}
+
+	go func() {
+		slog.Info("本地 API 已启动", "addr", "http://"+*serveAddr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("启动 HTTP 服务失败: %v", err)
+		}
+	}()
+
+	signal.Notify(stopChan, os.Interrupt)
+	<-stopChan
+	slog.Info("正在关闭...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/metrics"
+)
+
+const statsUsage = `用法:
+  voiceWin stats [-addr 127.0.0.1:8765]
+
+读取正在运行的 serve 的 /metrics 接口（serve 需开启 -metrics），
+显示计数器的值，以及延迟直方图的次数、平均值和 p50/p90/p99 估算值。`
+
+// runStats 执行 stats 子命令：显示 serve 模式的运行指标
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8765", "serve 模式的监听地址")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), statsUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + *addr + "/metrics")
+	if err != nil {
+		log.Fatalf("读取运行指标失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("读取运行指标失败: %s，serve 是否开启了 -metrics", resp.Status)
+	}
+	families, err := metrics.Parse(resp.Body)
+	if err != nil {
+		log.Fatalf("解析运行指标失败: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range families {
+		fmt.Fprintf(w, "%s\t%s\n", f.Name, f.Help)
+		switch f.Type {
+		case "counter":
+			for _, s := range f.Samples {
+				fmt.Fprintf(w, "  %s\t%g\n", labelString(s.Labels), s.Value)
+			}
+		case "histogram":
+			writeHistograms(w, f)
+		}
+	}
+	w.Flush()
+}
+
+// writeHistograms 按标签分组显示直方图的次数、平均值和分位数，单位为毫秒
+func writeHistograms(w *tabwriter.Writer, f metrics.Family) {
+	type group struct {
+		buckets    []metrics.Sample
+		sum, count float64
+	}
+	groups := make(map[string]*group)
+	for _, s := range f.Samples {
+		key := labelString(s.Labels)
+		g := groups[key]
+		if g == nil {
+			g = &group{}
+			groups[key] = g
+		}
+		switch strings.TrimPrefix(s.Name, f.Name) {
+		case "_bucket":
+			g.buckets = append(g.buckets, s)
+		case "_sum":
+			g.sum = s.Value
+		case "_count":
+			g.count = s.Value
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g := groups[k]
+		if g.count == 0 {
+			fmt.Fprintf(w, "  %s\t0 次\n", k)
+			continue
+		}
+		sort.Slice(g.buckets, func(i, j int) bool { return g.buckets[i].LE < g.buckets[j].LE })
+		fmt.Fprintf(w, "  %s\t%g 次\t平均 %s\tp50 %s\tp90 %s\tp99 %s\n", k, g.count, ms(g.sum/g.count),
+			ms(metrics.Quantile(0.5, g.buckets)), ms(metrics.Quantile(0.9, g.buckets)), ms(metrics.Quantile(0.99, g.buckets)))
+	}
+}
+
+func labelString(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func ms(seconds float64) string {
+	if math.IsNaN(seconds) {
+		return "-"
+	}
+	return fmt.Sprintf("%.0fms", seconds*1000)
+}
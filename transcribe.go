@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/engine"
+	"github.com/shellus/voiceWin/internal/recognition"
+	"github.com/shellus/voiceWin/internal/subtitle"
+	"github.com/shellus/voiceWin/internal/transcribe"
+)
+
+const transcribeUsage = `用法:
+  voiceWin transcribe [-format srt|vtt|txt|json] [-o 文件] [-chunk 5m] [-speed 倍数] 音频文件
+  voiceWin transcribe [-format ...] [-out-dir 目录] [-concurrency N] [-qps N] [-retry-failed] 目录
+
+音频文件为 WAV（任意位深、采样率和声道数，自动转换）、MP3、Ogg Opus，或与识别采样率相同的 16位单声道裸 PCM（.pcm）。
+阿里云后端可以直接识别单声道的 Ogg Opus 和与识别采样率相同的单声道 MP3，不需要解码。
+参数为目录时批量转写其中所有音频文件，处理进度记录在清单中，中断后再次运行会跳过已完成的文件。
+阿里云后端使用实时语音识别；识别后端、超时等参数与直接运行 voiceWin 时相同。`
+
+// runTranscribe 执行 transcribe 子命令：转写音频文件并输出字幕
+func runTranscribe(args []string) {
+	if code := transcribeMain(args); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// transcribeMain 执行转写并返回退出码
+// 不在这里调用 os.Exit，返回前执行延迟的 Close，输出文件和识别连接都能正常关闭
+func transcribeMain(args []string) int {
+	format := flag.String("format", "", "输出格式: "+strings.Join(subtitle.Formats, "|")+"，为空时按 -o 的扩展名，默认 srt")
+	output := flag.String("o", "", "输出到文件，默认输出到标准输出")
+	chunk := flag.Duration("chunk", transcribe.DefaultChunk, "长音频切分为多个识别任务，每个任务的最长音频时长")
+	speed := flag.Float64("speed", 1, "发送速度，实时速率的倍数，0 表示不限速；阿里云要求接近实时速率，其他后端默认不限速")
+	outDir := flag.String("out-dir", "", "批量转写的输出目录，为空时输出到音频文件旁边")
+	manifest := flag.String("manifest", "", "批量转写的清单文件，默认为输出目录下的 "+transcribe.ManifestName)
+	concurrency := flag.Int("concurrency", 2, "批量转写同时进行的识别任务数，不能超过账号的并发限制")
+	qps := flag.Float64("qps", 2, "批量转写每秒最多开始的识别任务数，0 表示不限制")
+	retryFailed := flag.Bool("retry-failed", false, "批量转写时重试清单中失败的文件")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), transcribeUsage)
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(args)
+	setupLogging()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return 2
+	}
+	input := flag.Arg(0)
+	if *chunk < transcribe.MinChunk {
+		slog.Error("-chunk 太短", "min", transcribe.MinChunk)
+		return 2
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*output), ".")
+		if *format == "" {
+			*format = "srt"
+		}
+	}
+	if err := subtitle.Write(io.Discard, nil, *format); err != nil {
+		slog.Error("无效的输出格式", "error", err)
+		return 2
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	opts := engineOptions()
+	// 一句话识别检测到说话结束就会完成，后面的音频会丢失，文件转写固定使用实时语音识别
+	opts.StartParam.Mode = recognition.ModeTranscription
+	opts.StartParam.EnableWords = true
+	opts.StartParam.EnableIntermediateResult = false
+	opts.Prewarm = false
+	aliyun := opts.Backend == "" || opts.Backend == engine.BackendAliyun
+	if !aliyun && !set["speed"] {
+		*speed = 0
+	}
+	if opts.Backend == engine.BackendOpenAI && !set["stop-timeout"] {
+		// 非流式识别在停止后才上传整段音频
+		opts.Timeouts.Stop = 2 * time.Minute
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	topts := transcribe.Options{
+		SampleRate: opts.StartParam.SampleRate,
+		Chunk:      *chunk,
+		Speed:      *speed,
+		Usage:      opts.Usage,
+		UsageKind:  engine.UsageKind(opts.Backend, opts.StartParam.Mode),
+	}
+
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		result, err := transcribe.Batch(ctx, transcribe.BatchOptions{
+			Options:     topts,
+			Dir:         input,
+			OutDir:      *outDir,
+			Format:      *format,
+			Manifest:    *manifest,
+			Concurrency: *concurrency,
+			QPS:         *qps,
+			RetryFailed: *retryFailed,
+			NewRecognizer: func() (recognition.Recognizer, error) {
+				return engine.NewRecognizer(opts, opts.StartParam.Mode)
+			},
+			OnFile: func(e transcribe.ManifestEntry) {
+				if e.Status == transcribe.StatusDone {
+					fmt.Printf("完成 %s -> %s（%d 句）\n", e.File, e.Output, e.Sentences)
+				} else {
+					fmt.Printf("失败 %s: %s\n", e.File, e.Error)
+				}
+			},
+		})
+		fmt.Printf("本次完成 %d 个，失败 %d 个，跳过已处理的 %d 个\n", result.Done, result.Failed, result.Skipped)
+		if err != nil {
+			slog.Error("批量转写中断", "error", err)
+			return 1
+		}
+		if result.Failed > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	r, err := engine.NewRecognizer(opts, opts.StartParam.Mode)
+	if err != nil {
+		slog.Error("初始化识别客户端失败", "error", err)
+		return 1
+	}
+	if c, ok := r.(interface{ Close() }); ok {
+		defer c.Close()
+	}
+	in, err := transcribe.ReadInput(input, opts.StartParam.SampleRate, r)
+	if err != nil {
+		slog.Error("读取音频文件失败", "error", err)
+		return 1
+	}
+	if in.Compressed != nil {
+		fmt.Fprintf(os.Stderr, "直接发送 %s 音频，不解码\n", in.Compressed.Format)
+	}
+
+	topts.Progress = func(done, total time.Duration) {
+		fmt.Fprintf(os.Stderr, "\r已识别 %s / %s", done.Round(time.Second), total.Round(time.Second))
+	}
+	sentences, err := transcribe.TranscribeInput(ctx, r, in, topts)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		// 已完成部分的结果仍然输出
+		slog.Error("转写失败", "error", err)
+	}
+
+	if werr := writeSubtitle(*output, sentences, *format); werr != nil {
+		slog.Error("写出结果失败", "error", werr)
+		return 1
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// writeSubtitle 把字幕写到 path，path 为空时写到标准输出；关闭文件失败（如磁盘已满）也返回错误
+func writeSubtitle(path string, sentences []recognition.Sentence, format string) error {
+	if path == "" {
+		return subtitle.Write(os.Stdout, sentences, format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	err = subtitle.Write(f, sentences, format)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
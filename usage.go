@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shellus/voiceWin/internal/usage"
+)
+
+const usageUsage = `用法:
+  voiceWin usage [-month 2006-01] [-usage 文件] [-usage-prices 文件] [-quota-soft ...] [-quota-hard ...]
+
+按天列出识别次数、失败次数、音频时长和按价格表估算的费用，并检查限额。`
+
+// runUsage 执行 usage 子命令：查看用量和估算费用
+func runUsage(args []string) {
+	month := flag.String("month", time.Now().Format("2006-01"), "统计的月份")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usageUsage)
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(args)
+	if *usageFile == "" {
+		log.Fatalf("未指定用量记录文件")
+	}
+	start, err := time.ParseInLocation("2006-01", *month, time.Local)
+	if err != nil {
+		log.Fatalf("无效的 -month: %v", err)
+	}
+
+	tracker := openUsage()
+	days, err := tracker.Days(start, start.AddDate(0, 1, 0))
+	if err != nil {
+		log.Fatalf("读取用量失败: %v", err)
+	}
+	prices := tracker.Prices()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "日期\t类型\t次数\t失败\t音频\t估算费用")
+	var total usage.Counters
+	var cost float64
+	for _, d := range days {
+		kinds := make([]string, 0, len(d.Kinds))
+		for k := range d.Kinds {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		for _, k := range kinds {
+			c := d.Kinds[k]
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%.2f\n", d.Date, k, c.Requests, c.Failures, c.Audio().Round(time.Second), prices.Cost(k, c))
+		}
+		total.Add(d.Total())
+		cost += d.Cost(prices)
+	}
+	fmt.Fprintf(w, "合计\t\t%d\t%d\t%s\t%.2f\n", total.Requests, total.Failures, total.Audio().Round(time.Second), cost)
+	w.Flush()
+
+	if *month == time.Now().Format("2006-01") {
+		warning, err := tracker.Check(time.Now())
+		switch {
+		case err != nil:
+			fmt.Printf("\n%v\n", err)
+		case warning != "":
+			fmt.Printf("\n%s\n", warning)
+		}
+	}
+}